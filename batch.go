@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+)
+
+// maxBatchRequestSize is the longest line the batch command accepts
+const maxBatchRequestSize = 1024 * 1024
+
+// errInvalidBatchRequest is the kind of a batch request s3cli cannot execute
+var errInvalidBatchRequest = errors.New("invalid batch request")
+
+// batchRequest is one line of the input of the batch command
+type batchRequest struct {
+	ID      string       `json:"id"`
+	Op      string       `json:"op"`
+	Src     string       `json:"src"`
+	Dst     string       `json:"dst"`
+	Options batchOptions `json:"options"`
+}
+
+// batchOptions are the flags of the command a batch request executes
+type batchOptions struct {
+	VersionID          string            `json:"version_id"`
+	Digest             string            `json:"digest"`
+	StoreDigest        bool              `json:"store_digest"`
+	NoOverwrite        bool              `json:"no_overwrite"`
+	IfMatch            string            `json:"if_match"`
+	ContentType        string            `json:"content_type"`
+	CacheControl       string            `json:"cache_control"`
+	ContentDisposition string            `json:"content_disposition"`
+	ContentEncoding    string            `json:"content_encoding"`
+	Metadata           map[string]string `json:"metadata"`
+	Tags               map[string]string `json:"tags"`
+	Range              string            `json:"range"`
+	IfNoneMatch        string            `json:"if_none_match"`
+	IfModifiedSince    string            `json:"if_modified_since"`
+	Resume             bool              `json:"resume"`
+	Raw                bool              `json:"raw"`
+	Purge              bool              `json:"purge"`
+	DestBucket         string            `json:"dest_bucket"`
+	Action             string            `json:"action"`
+	Expiration         string            `json:"expiration"`
+	StorageClass       string            `json:"storage_class"`
+	// Days is a pointer to tell an explicit 0 from the default of 1
+	Days             *int   `json:"days"`
+	Tier             string `json:"tier"`
+	LockMode         string `json:"lock_mode"`
+	RetainUntil      string `json:"retain_until"`
+	BypassGovernance bool   `json:"bypass_governance"`
+	// Clear removes the retention of retention
+	Clear bool `json:"clear"`
+	// LegalHold, on or off, sets the legal hold instead of reporting it
+	LegalHold string `json:"legal_hold"`
+	// TagNames are the tags untag removes
+	TagNames   []string `json:"tag_names"`
+	Delimiter  string   `json:"delimiter"`
+	PageSize   int      `json:"page_size"`
+	Max        int      `json:"max"`
+	StartAfter string   `json:"start_after"`
+}
+
+// batchResult is one line of the output of the batch command, the document
+// the command of the request prints with -output json plus its exit status
+type batchResult struct {
+	ID string `json:"id,omitempty"`
+	*commandResult
+	ExitCode int `json:"exit_code"`
+}
+
+// runBatch executes the JSON-lines requests read from in, up to parallelism
+// at once, and writes a JSON-lines result for each of them to out in the
+// order they complete. It returns the exit status of s3cli: 0 unless a
+// request failed or the batch was interrupted.
+func runBatch(ctx context.Context, blobstoreClient client.S3CompatibleClient, bucket string, logger *slog.Logger, in io.Reader, out io.Writer, parallelism int) int {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var outMutex sync.Mutex
+	encoder := json.NewEncoder(out)
+	// Keep the query strings of signed URLs readable
+	encoder.SetEscapeHTML(false)
+
+	failed := false
+	write := func(result batchResult, err error) {
+		outMutex.Lock()
+		defer outMutex.Unlock()
+
+		if err != nil {
+			failed = true
+			logger.Error("Operation failed", "command", result.Command, "id", result.ID, "error", err)
+		}
+		if encodeErr := encoder.Encode(result); encodeErr != nil {
+			logger.Error("Writing batch result failed", "id", result.ID, "error", encodeErr)
+		}
+	}
+
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchRequestSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var request batchRequest
+		if err := json.Unmarshal(line, &request); err != nil {
+			err = fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			result := newCommandResult("")
+			write(batchResult{commandResult: result, ExitCode: result.finish(err, 0)}, err)
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, code, err := runBatchRequest(ctx, blobstoreClient, bucket, logger, request)
+			if err != nil && ctx.Err() != nil {
+				code = interruptedExitCode(ctx)
+			}
+			write(batchResult{ID: request.ID, commandResult: result, ExitCode: code}, err)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		logger.Error("Operation interrupted", "command", "batch", "error", ctx.Err())
+		return interruptedExitCode(ctx)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("Reading batch requests failed", "error", err)
+		return exitCodeFailure
+	}
+	if failed {
+		return exitCodeFailure
+	}
+
+	return 0
+}
+
+// runBatchRequest executes request like the command of the same name and
+// returns its result and exit status
+func runBatchRequest(ctx context.Context, blobstoreClient client.S3CompatibleClient, bucket string, logger *slog.Logger, request batchRequest) (*commandResult, int, error) {
+	runner := &commandRunner{blobstoreClient: blobstoreClient, bucket: bucket, logger: logger, result: newCommandResult(request.Op)}
+	opts := request.Options
+
+	err := func() error {
+		switch request.Op {
+		case "list":
+			// src is the optional prefix
+		case "put", "get", "copy", "move":
+			if request.Src == "" || request.Dst == "" {
+				return fmt.Errorf("%w: %s expects src and dst", errInvalidBatchRequest, request.Op)
+			}
+		default:
+			if request.Src == "" {
+				return fmt.Errorf("%w: %s expects src", errInvalidBatchRequest, request.Op)
+			}
+		}
+
+		var digests []client.Digest
+		if opts.Digest != "" {
+			var err error
+			if digests, err = client.ParseDigests(opts.Digest); err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			}
+		}
+
+		switch request.Op {
+		case "put":
+			// stdin carries the requests
+			if request.Src == "-" {
+				return fmt.Errorf("%w: put cannot read the blob from stdin", errInvalidBatchRequest)
+			}
+			retainUntil, err := parseRetainUntil(opts.RetainUntil)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			}
+
+			return runner.put(ctx, putOptions{
+				Src:                request.Src,
+				Dst:                request.Dst,
+				Digests:            digests,
+				StoreDigest:        opts.StoreDigest,
+				NoOverwrite:        opts.NoOverwrite,
+				IfMatch:            opts.IfMatch,
+				ContentType:        opts.ContentType,
+				CacheControl:       opts.CacheControl,
+				ContentDisposition: opts.ContentDisposition,
+				ContentEncoding:    opts.ContentEncoding,
+				Metadata:           opts.Metadata,
+				Tags:               opts.Tags,
+				StorageClass:       opts.StorageClass,
+				Retention:          client.Retention{Mode: opts.LockMode, RetainUntilDate: retainUntil},
+			})
+		case "get":
+			// stdout carries the results
+			if request.Dst == "-" {
+				return fmt.Errorf("%w: get cannot write the blob to stdout", errInvalidBatchRequest)
+			}
+			rangeHeader, err := parseRange(opts.Range)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			}
+			modifiedSince, err := parseModifiedSince(opts.IfModifiedSince)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			}
+
+			return runner.get(ctx, getOptions{
+				Src:             request.Src,
+				Dst:             request.Dst,
+				Digests:         digests,
+				Resume:          opts.Resume,
+				VersionID:       opts.VersionID,
+				Range:           rangeHeader,
+				IfNoneMatch:     opts.IfNoneMatch,
+				IfModifiedSince: modifiedSince,
+				Raw:             opts.Raw,
+			})
+		case "delete":
+			if opts.Purge && opts.VersionID != "" {
+				return fmt.Errorf("%w: delete accepts either version_id or purge", errInvalidBatchRequest)
+			}
+
+			return runner.delete(ctx, deleteOptions{
+				Key:              request.Src,
+				VersionID:        opts.VersionID,
+				Purge:            opts.Purge,
+				BypassGovernance: opts.BypassGovernance,
+			})
+		case "exists":
+			return runner.exists(ctx, blobOptions{Key: request.Src, VersionID: opts.VersionID})
+		case "stat":
+			return runner.stat(ctx, blobOptions{Key: request.Src, VersionID: opts.VersionID})
+		case "tags":
+			return runner.tags(ctx, blobOptions{Key: request.Src, VersionID: opts.VersionID})
+		case "tag":
+			if len(opts.Tags) == 0 {
+				return fmt.Errorf("%w: tag expects tags", errInvalidBatchRequest)
+			}
+			return runner.tag(ctx, tagOptions{Key: request.Src, VersionID: opts.VersionID, Tags: opts.Tags})
+		case "untag":
+			return runner.untag(ctx, tagOptions{Key: request.Src, VersionID: opts.VersionID, Names: opts.TagNames})
+		case "sign":
+			action := opts.Action
+			if action == "" {
+				action = "get"
+			}
+			if action != "get" && action != "put" {
+				return fmt.Errorf("%w: action not implemented: %s. Available actions are 'get' and 'put'", errInvalidBatchRequest, action)
+			}
+			expiration, err := time.ParseDuration(opts.Expiration)
+			if err != nil {
+				return fmt.Errorf("%w: expiration should be in the format of a duration i.e. 1h, 60m, 3600s. Got: %s", errInvalidBatchRequest, opts.Expiration)
+			}
+
+			return runner.sign(ctx, signOptions{Key: request.Src, Action: action, Expiration: expiration, VersionID: opts.VersionID})
+		case "copy", "move":
+			return runner.copy(ctx, copyOptions{
+				Src:          request.Src,
+				Dst:          request.Dst,
+				DestBucket:   opts.DestBucket,
+				StorageClass: opts.StorageClass,
+				Move:         request.Op == "move",
+			})
+		case "versions":
+			return runner.versions(ctx, blobOptions{Key: request.Src})
+		case "restore-version":
+			if opts.VersionID == "" {
+				return fmt.Errorf("%w: restore-version expects version_id", errInvalidBatchRequest)
+			}
+			return runner.restoreVersion(ctx, blobOptions{Key: request.Src, VersionID: opts.VersionID})
+		case "restore":
+			days := 1
+			if opts.Days != nil {
+				days = *opts.Days
+			}
+
+			return runner.restore(ctx, restoreOptions{Key: request.Src, VersionID: opts.VersionID, Days: int32(days), Tier: opts.Tier})
+		case "restore-status":
+			return runner.restoreStatus(ctx, blobOptions{Key: request.Src, VersionID: opts.VersionID})
+		case "retention":
+			if opts.Clear && (opts.LockMode != "" || opts.RetainUntil != "") {
+				return fmt.Errorf("%w: retention accepts either clear or lock_mode and retain_until", errInvalidBatchRequest)
+			}
+			retainUntil, err := parseRetainUntil(opts.RetainUntil)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+			}
+
+			return runner.retention(ctx, retentionOptions{
+				Key:              request.Src,
+				VersionID:        opts.VersionID,
+				Set:              opts.Clear || opts.LockMode != "" || opts.RetainUntil != "",
+				Retention:        client.Retention{Mode: opts.LockMode, RetainUntilDate: retainUntil},
+				BypassGovernance: opts.BypassGovernance,
+			})
+		case "legal-hold":
+			var on bool
+			if opts.LegalHold != "" {
+				var err error
+				if on, err = parseOnOff(opts.LegalHold); err != nil {
+					return fmt.Errorf("%w: %w", errInvalidBatchRequest, err)
+				}
+			}
+
+			return runner.legalHold(ctx, legalHoldOptions{Key: request.Src, VersionID: opts.VersionID, Set: opts.LegalHold != "", On: on})
+		case "list":
+			return runner.list(ctx, listOptions{
+				Prefix:     request.Src,
+				Delimiter:  opts.Delimiter,
+				PageSize:   int32(opts.PageSize),
+				MaxEntries: opts.Max,
+				StartAfter: opts.StartAfter,
+			})
+		default:
+			return fmt.Errorf("%w: unknown op: '%s'", errInvalidBatchRequest, request.Op)
+		}
+	}()
+
+	return runner.result, runner.result.finish(err, runner.successCode), err
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("batch", func() {
+	type tagging struct {
+		TagSet []struct {
+			Key   string
+			Value string
+		} `xml:"TagSet>Tag"`
+	}
+
+	type result struct {
+		ID        string            `json:"id"`
+		Command   string            `json:"command"`
+		Key       string            `json:"key"`
+		VersionID string            `json:"version_id"`
+		Exists    *bool             `json:"exists"`
+		Tags      map[string]string `json:"tags"`
+		Versions  []versionEntry    `json:"versions"`
+		Entries   []listEntry       `json:"entries"`
+		Error     string            `json:"error"`
+		ExitCode  int               `json:"exit_code"`
+	}
+
+	var s3Server *httptest.Server
+	var objects map[string]string
+	var tags map[string]map[string]string
+	var copySources []string
+	var requestsMutex sync.Mutex
+	var ctx context.Context
+	var localDir string
+
+	runRequests := func(lines ...string) ([]result, int) {
+		var out bytes.Buffer
+		// One request at a time prints the results in the order of the requests
+		code := runBatch(ctx, newTestClient(s3Server.URL), "some-bucket", newTestLogger(), strings.NewReader(strings.Join(lines, "\n")), &out, 1)
+
+		var results []result
+		decoder := json.NewDecoder(&out)
+		for decoder.More() {
+			var r result
+			Expect(decoder.Decode(&r)).To(Succeed())
+			results = append(results, r)
+		}
+		return results, code
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		localDir = GinkgoT().TempDir()
+		objects = map[string]string{"some-key": "some content"}
+		tags = map[string]map[string]string{}
+		copySources = nil
+
+		s3Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			key := strings.TrimPrefix(r.URL.Path, "/some-bucket/")
+			query := r.URL.Query()
+			body, _ := io.ReadAll(r.Body) //nolint:errcheck
+
+			switch {
+			case query.Get("list-type") == "2":
+				fmt.Fprint(w, `<ListBucketResult>`) //nolint:errcheck
+				for _, key := range slices.Sorted(maps.Keys(objects)) {
+					fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><ETag>"some-etag"</ETag></Contents>`, key, len(objects[key])) //nolint:errcheck
+				}
+				fmt.Fprint(w, `</ListBucketResult>`) //nolint:errcheck
+				return
+			case query.Has("versions"):
+				fmt.Fprint(w, `<ListVersionsResult>`) //nolint:errcheck
+				if object, ok := objects[query.Get("prefix")]; ok {
+					fmt.Fprintf(w, `<Version><Key>%s</Key><VersionId>some-version</VersionId><IsLatest>true</IsLatest><Size>%d</Size><ETag>"some-etag"</ETag><LastModified>2030-01-02T03:04:05.000Z</LastModified></Version>`, query.Get("prefix"), len(object)) //nolint:errcheck
+				}
+				fmt.Fprint(w, `</ListVersionsResult>`) //nolint:errcheck
+				return
+			case query.Has("tagging"):
+				switch r.Method {
+				case http.MethodGet:
+					fmt.Fprint(w, `<Tagging><TagSet>`) //nolint:errcheck
+					for name, value := range tags[key] {
+						fmt.Fprintf(w, `<Tag><Key>%s</Key><Value>%s</Value></Tag>`, name, value) //nolint:errcheck
+					}
+					fmt.Fprint(w, `</TagSet></Tagging>`) //nolint:errcheck
+				case http.MethodPut:
+					var tagSet tagging
+					xml.Unmarshal(body, &tagSet) //nolint:errcheck
+					tags[key] = map[string]string{}
+					for _, tag := range tagSet.TagSet {
+						tags[key][tag.Key] = tag.Value
+					}
+				case http.MethodDelete:
+					delete(tags, key)
+					w.WriteHeader(http.StatusNoContent)
+				}
+				return
+			case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+				copySources = append(copySources, r.Header.Get("X-Amz-Copy-Source"))
+				w.Header().Set("X-Amz-Version-Id", "some-new-version")
+				fmt.Fprint(w, `<CopyObjectResult><ETag>"some-etag"</ETag></CopyObjectResult>`) //nolint:errcheck
+				return
+			case r.Method == http.MethodPut:
+				objects[key] = string(body)
+				w.Header().Set("ETag", `"some-etag"`)
+				return
+			}
+
+			object, exists := objects[key]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				if r.Method != http.MethodHead {
+					fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>some message</Message></Error>`) //nolint:errcheck
+				}
+				return
+			}
+			w.Header().Set("ETag", `"some-etag"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+			if r.Method == http.MethodGet {
+				w.Write([]byte(object)) //nolint:errcheck
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		s3Server.Close()
+	})
+
+	It("executes each request like the command of the same name", func() {
+		sourcePath := filepath.Join(localDir, "source")
+		Expect(os.WriteFile(sourcePath, []byte("new content"), 0644)).To(Succeed())
+		destinationPath := filepath.Join(localDir, "destination")
+
+		results, code := runRequests(
+			fmt.Sprintf(`{"id": "put", "op": "put", "src": %q, "dst": "new-key"}`, sourcePath),
+			fmt.Sprintf(`{"id": "get", "op": "get", "src": "some-key", "dst": %q}`, destinationPath),
+			`{"id": "exists", "op": "exists", "src": "new-key"}`,
+			`{"id": "tag", "op": "tag", "src": "some-key", "options": {"tags": {"a": "1", "b": "2"}}}`,
+			`{"id": "untag", "op": "untag", "src": "some-key", "options": {"tag_names": ["b"]}}`,
+			`{"id": "tags", "op": "tags", "src": "some-key"}`,
+			`{"id": "versions", "op": "versions", "src": "some-key"}`,
+			`{"id": "restore-version", "op": "restore-version", "src": "some-key", "options": {"version_id": "some-version"}}`,
+			`{"id": "list", "op": "list"}`,
+		)
+		Expect(code).To(BeZero())
+
+		Expect(results).To(HaveLen(9))
+		for _, r := range results {
+			Expect(r.Command).To(Equal(r.ID))
+			Expect(r.Error).To(BeEmpty(), r.ID)
+			Expect(r.ExitCode).To(BeZero(), r.ID)
+		}
+
+		Expect(results[0].Key).To(Equal("new-key"))
+		Expect(objects).To(HaveKey("new-key"))
+		Expect(os.ReadFile(destinationPath)).To(Equal([]byte("some content")))
+		Expect(*results[2].Exists).To(BeTrue())
+		Expect(results[5].Tags).To(Equal(map[string]string{"a": "1"}))
+		Expect(results[6].Versions).To(HaveLen(1))
+		Expect(results[6].Versions[0].VersionID).To(Equal("some-version"))
+		Expect(copySources).To(Equal([]string{"some-bucket/some-key?versionId=some-version"}))
+		Expect(results[7].VersionID).To(Equal("some-new-version"))
+		Expect(results[8].Entries).To(HaveLen(2))
+		Expect(results[8].Entries[0].Key).To(Equal("new-key"))
+	})
+
+	DescribeTable("reports an invalid request with the exit code 2",
+		func(line string, expectedError string) {
+			results, code := runRequests(line)
+			Expect(code).To(Equal(exitCodeFailure))
+
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].ExitCode).To(Equal(exitCodeUsage))
+			Expect(results[0].Error).To(ContainSubstring("invalid batch request"))
+			Expect(results[0].Error).To(ContainSubstring(expectedError))
+		},
+		Entry("with invalid JSON", `{"op": "get"`, "unexpected end of JSON input"),
+		Entry("with an unknown op", `{"op": "unknown", "src": "some-key"}`, "unknown op: 'unknown'"),
+		Entry("without src", `{"op": "stat"}`, "stat expects src"),
+		Entry("without dst", `{"op": "copy", "src": "some-key"}`, "copy expects src and dst"),
+		Entry("putting stdin", `{"op": "put", "src": "-", "dst": "some-key"}`, "cannot read the blob from stdin"),
+		Entry("getting to stdout", `{"op": "get", "src": "some-key", "dst": "-"}`, "cannot write the blob to stdout"),
+		Entry("with an invalid digest", `{"op": "get", "src": "some-key", "dst": "some-path", "options": {"digest": "md5:abc"}}`, "md5"),
+		Entry("with an invalid range", `{"op": "get", "src": "some-key", "dst": "some-path", "options": {"range": "bytes=9-1"}}`, "invalid range"),
+		Entry("tagging without tags", `{"op": "tag", "src": "some-key"}`, "tag expects tags"),
+		Entry("restoring a version without version_id", `{"op": "restore-version", "src": "some-key"}`, "expects version_id"),
+		Entry("signing an unknown action", `{"op": "sign", "src": "some-key", "options": {"action": "delete", "expiration": "1h"}}`, "action not implemented"),
+		Entry("signing without expiration", `{"op": "sign", "src": "some-key"}`, "expiration should be in the format of a duration"),
+		Entry("clearing and setting a retention", `{"op": "retention", "src": "some-key", "options": {"clear": true, "lock_mode": "GOVERNANCE"}}`, "either clear or lock_mode"),
+		Entry("with an invalid legal hold", `{"op": "legal-hold", "src": "some-key", "options": {"legal_hold": "yes"}}`, "expected 'on' or 'off'"),
+	)
+
+	Describe("exit status", func() {
+		It("is 0 if no request failed, whatever their exit codes", func() {
+			results, code := runRequests(
+				`{"id": "exists", "op": "exists", "src": "some-key"}`,
+				"",
+				`{"id": "missing", "op": "exists", "src": "missing-key"}`,
+			)
+			Expect(code).To(BeZero())
+
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].ExitCode).To(BeZero())
+			Expect(*results[1].Exists).To(BeFalse())
+			Expect(results[1].ExitCode).To(Equal(exitCodeNotFound))
+			Expect(results[1].Error).To(BeEmpty())
+		})
+
+		It("is 1 if a request failed", func() {
+			results, code := runRequests(
+				fmt.Sprintf(`{"id": "missing", "op": "get", "src": "missing-key", "dst": %q}`, filepath.Join(localDir, "missing")),
+				`{"id": "exists", "op": "exists", "src": "some-key"}`,
+			)
+			Expect(code).To(Equal(exitCodeFailure))
+
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].ExitCode).To(Equal(exitCodeNotFound))
+			Expect(results[0].Error).NotTo(BeEmpty())
+			Expect(results[1].ExitCode).To(BeZero())
+		})
+
+		It("is 130 once the batch was interrupted", func() {
+			cancelledCtx, cancel := context.WithCancel(context.Background())
+			cancel()
+			ctx = cancelledCtx
+
+			results, code := runRequests(`{"id": "exists", "op": "exists", "src": "some-key"}`)
+			Expect(code).To(Equal(exitCodeInterrupted))
+			Expect(results).To(BeEmpty())
+		})
+
+		It("is 128 plus the signal which interrupted the batch", func() {
+			cancelledCtx, cancel := context.WithCancelCause(context.Background())
+			cancel(&signalError{signal: syscall.SIGTERM})
+			ctx = cancelledCtx
+
+			results, code := runRequests(`{"id": "exists", "op": "exists", "src": "some-key"}`)
+			Expect(code).To(Equal(143))
+			Expect(results).To(BeEmpty())
+		})
+	})
+})
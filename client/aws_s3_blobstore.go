@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"context"
@@ -15,6 +18,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/cloudfoundry/bosh-s3cli/config"
 )
@@ -30,17 +34,184 @@ const (
 	defaultTransferPartSize    = int64(5 * 1024 * 1024) // 5 MB
 )
 
+// CopyObject refuses sources above 5 GB, larger objects are copied part by part
+// with UploadPartCopy. Copy parts are not transferred through the client, so they
+// can be much larger than upload parts; they grow further if an object would
+// otherwise need more than the 10000 parts S3 allows.
+const (
+	maxSingleCopySize   = int64(5 * 1024 * 1024 * 1024) // 5 GB
+	defaultCopyPartSize = int64(512 * 1024 * 1024)      // 512 MB
+	maxUploadParts      = int64(10000)
+)
+
+// abortTimeout bounds the cleanup of an interrupted multipart upload
+const abortTimeout = 30 * time.Second
+
 // awsS3Client encapsulates AWS S3 blobstore interactions
 type awsS3Client struct {
 	s3Client    *s3.Client
 	s3cliConfig *config.S3Cli
+	logger      *slog.Logger
+
+	// The key wrapper of client-side encryption, set up on first use
+	encryptionOnce sync.Once
+	encryption     keyWrapper
+	encryptionErr  error
 }
 
 // Get fetches a blob, destination will be overwritten if exists
-func (b *awsS3Client) Get(src string, dest io.WriterAt) error {
+func (b *awsS3Client) Get(ctx context.Context, src string, dest io.WriterAt, optFns ...func(*GetOptions)) error {
+	opts := getOptions(optFns)
+	if err := validateGetOptions(opts); err != nil {
+		return err
+	}
+	startObjectInfo(opts.Result, b.s3cliConfig.BucketName, src)
+	if decrypted, err := b.getDecrypted(ctx, src, io.NewOffsetWriter(dest, 0), opts); decrypted || err != nil {
+		return err
+	}
+	if err := b.headCompressed(ctx, src, opts); err != nil {
+		return b.decompressStopped(ctx, src, io.NewOffsetWriter(dest, 0), opts, err)
+	}
+	getParams := b.getObjectInput(src, opts)
+
+	if len(opts.Digests) > 0 {
+		err := b.getStreamVerified(ctx, getParams, io.NewOffsetWriter(dest, 0), opts.Digests, opts.Result, !opts.Raw)
+		return b.decompressStopped(ctx, src, io.NewOffsetWriter(dest, 0), opts, err)
+	}
+
+	downloader := b.newDownloader(b.downloadClient(opts.Result, getParams, !opts.Raw))
+
+	_, err := downloader.Download(ctx, dest, getParams) //nolint:staticcheck
+
+	if err != nil {
+		return b.decompressStopped(ctx, src, io.NewOffsetWriter(dest, 0), opts, err)
+	}
+
+	return nil
+}
+
+// GetStream fetches a blob into a sequential writer such as stdout. Parts are
+// still downloaded in parallel and reassembled in order in memory. On a digest
+// mismatch the content has already been written to dest.
+func (b *awsS3Client) GetStream(ctx context.Context, src string, dest io.Writer, optFns ...func(*GetOptions)) error {
+	opts := getOptions(optFns)
+	if err := validateGetOptions(opts); err != nil {
+		return err
+	}
+	startObjectInfo(opts.Result, b.s3cliConfig.BucketName, src)
+	if decrypted, err := b.getDecrypted(ctx, src, dest, opts); decrypted || err != nil {
+		return err
+	}
+	if err := b.headCompressed(ctx, src, opts); err != nil {
+		return b.decompressStopped(ctx, src, dest, opts, err)
+	}
+
+	err := b.getStreamVerified(ctx, b.getObjectInput(src, opts), dest, opts.Digests, opts.Result, !opts.Raw)
+	return b.decompressStopped(ctx, src, dest, opts, err)
+}
+
+// getObjectInput is the GetObject request of a download of src. A ranged
+// request makes the downloader fetch that range in a single GetObject. The
+// downloader sends the conditions with each part, the first one fails with
+// ErrNotModified before anything is written.
+func (b *awsS3Client) getObjectInput(src string, opts GetOptions) *s3.GetObjectInput {
+	sse := b.sseCustomerKey()
+	getParams := &s3.GetObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.VersionID),
+		IfNoneMatch:          ifNoneMatchParam(opts.IfNoneMatch),
+		IfModifiedSince:      ifModifiedSinceParam(opts.IfModifiedSince),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	if opts.Range != "" {
+		getParams.Range = aws.String(opts.Range)
+	}
+
+	return getParams
+}
+
+// getStreamVerified is getStream hashing the content on its way to dest
+func (b *awsS3Client) getStreamVerified(ctx context.Context, getParams *s3.GetObjectInput, dest io.Writer, digests []Digest, result *ObjectInfo, decompress bool) error {
+	if len(digests) == 0 {
+		return b.getStream(ctx, getParams, dest, result, decompress)
+	}
+
+	verifier := newDigestVerifier(digests)
+	if err := b.getStream(ctx, getParams, io.MultiWriter(dest, verifier), result, decompress); err != nil {
+		return err
+	}
+
+	return verifier.Verify()
+}
+
+// ifNoneMatchParam quotes an ETag for the If-None-Match header, nil if unset
+func ifNoneMatchParam(etag string) *string {
+	switch etag {
+	case "":
+		return nil
+	case "*":
+		return aws.String(etag)
+	}
+
+	return aws.String(`"` + strings.Trim(etag, `"`) + `"`)
+}
+
+// ifModifiedSinceParam is nil for the zero time
+func ifModifiedSinceParam(since time.Time) *time.Time {
+	if since.IsZero() {
+		return nil
+	}
+
+	return aws.Time(since)
+}
+
+func getOptions(optFns []func(*GetOptions)) GetOptions {
+	var opts GetOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	return opts
+}
+
+// validateGetOptions rejects options which cannot be combined
+func validateGetOptions(opts GetOptions) error {
+	if opts.Range == "" {
+		return nil
+	}
+	if len(opts.Digests) > 0 {
+		return &Error{Kind: ErrInvalidArgument, Err: errors.New("digests cannot be verified for a range of the blob")}
+	}
+	if opts.Resume {
+		return &Error{Kind: ErrInvalidArgument, Err: errors.New("a ranged download cannot be resumed")}
+	}
+
+	return nil
+}
+
+// getStream downloads getParams into dest in order. With decompress set, a
+// compressed object stops the download with a compressedBlobError.
+func (b *awsS3Client) getStream(ctx context.Context, getParams *s3.GetObjectInput, dest io.Writer, result *ObjectInfo, decompress bool) error {
+	downloadClient := b.downloadClient(result, getParams, decompress)
+	downloader := b.newDownloader(downloadClient)
+
+	orderedDest := newOrderedWriterAt(dest, int64(downloader.Concurrency)*downloader.PartSize)
+	downloader.S3 = &orderedDownloadClient{DownloadAPIClient: downloadClient, writer: orderedDest}
+	// A retried part would be rewritten from its beginning, which the ordered
+	// writer cannot take back once it reached dest
+	downloader.PartBodyMaxRetries = 0
+
+	_, err := downloader.Download(ctx, orderedDest, getParams) //nolint:staticcheck
+	return err
+}
+
+func (b *awsS3Client) newDownloader(client manager.DownloadAPIClient) *manager.Downloader { //nolint:staticcheck
 	cfg := b.s3cliConfig
 
-	downloader := manager.NewDownloader(b.s3Client, func(d *manager.Downloader) { //nolint:staticcheck
+	return manager.NewDownloader(client, func(d *manager.Downloader) { //nolint:staticcheck
 		d.Concurrency = defaultTransferConcurrency
 		if cfg.DownloadConcurrency > 0 {
 			d.Concurrency = cfg.DownloadConcurrency
@@ -51,28 +222,107 @@ func (b *awsS3Client) Get(src string, dest io.WriterAt) error {
 			d.PartSize = cfg.DownloadPartSize
 		}
 	})
+}
 
-	_, err := downloader.Download(context.TODO(), dest, &s3.GetObjectInput{ //nolint:staticcheck
-		Bucket: aws.String(b.s3cliConfig.BucketName),
-		Key:    b.key(src),
-	})
+// Put uploads a blob. A src that cannot seek, e.g. stdin, is streamed through
+// a multipart upload holding at most concurrency + 1 parts in memory.
+//
+// Expected digests of a seekable src are verified by reading it once before
+// the upload, those of a stream while it is uploaded.
+func (b *awsS3Client) Put(ctx context.Context, src io.Reader, dest string, optFns ...func(*PutOptions)) error {
+	var opts PutOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
 
+	cfg := b.s3cliConfig
+	if cfg.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+	startObjectInfo(opts.Result, cfg.BucketName, dest)
+
+	conditions, err := b.writeConditions(ctx, dest, opts)
+	if err != nil {
+		return err
+	}
+	headers, err := b.objectHeaders(src, dest, opts)
+	if err != nil {
+		return err
+	}
+	encryption, err := b.clientSideEncryption()
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	size := int64(-1)
+	if len(opts.Digests) > 0 {
+		if seeker, ok := src.(io.ReadSeeker); ok {
+			var err error
+			if size, err = verifySeekable(seeker, opts.Digests); err != nil {
+				return err
+			}
+		} else {
+			src = &verifyingReader{r: src, verifier: newDigestVerifier(opts.Digests)}
+		}
+	}
 
-// Put uploads a blob
-func (b *awsS3Client) Put(src io.ReadSeeker, dest string) error {
-	cfg := b.s3cliConfig
-	if cfg.CredentialsSource == config.NoneCredentialsSource {
-		return errorInvalidCredentialsSourceValue
+	var counter *countingReader
+	if opts.Result != nil {
+		if seeker, ok := src.(io.Seeker); !ok {
+			counter = &countingReader{r: src}
+			src = counter
+		} else if size < 0 {
+			var err error
+			if size, err = seekerSize(seeker); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only the parts of the file itself are recorded, not those of its
+	// compressed or sealed content
+	if _, _, ok := b.resumableSource(src); ok && (cfg.Compression != "" || encryption != nil) {
+		b.logger.WarnContext(ctx, "Upload cannot be resumed, upload_state_dir does not apply to compressed or client-side encrypted blobs", "key", dest)
+	}
+
+	// The content is compressed and sealed after it was verified and
+	// counted, so both refer to the blob as it is read back
+	if cfg.Compression != "" {
+		compressed, done, err := compressUpload(src, cfg.Compression, &headers)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		src = compressed
+	}
+	if encryption != nil {
+		if src, err = encryptUpload(ctx, encryption, src, &headers); err != nil {
+			return err
+		}
+	}
+
+	if file, info, ok := b.resumableSource(src); ok {
+		return b.putResumable(ctx, file, info, dest, opts, conditions)
+	}
+
+	if _, seekable := src.(io.ReadSeeker); !seekable && !cfg.MultipartUpload {
+		// A single PutObject needs the full length up front, spool the stream
+		// to disk rather than buffering it in memory
+		spooled, err := spoolToTempFile(src)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spooled.Name()) //nolint:errcheck
+		defer spooled.Close()           //nolint:errcheck
+
+		src = spooled
 	}
 
 	uploader := manager.NewUploader(b.s3Client, func(u *manager.Uploader) { //nolint:staticcheck
-		u.LeavePartsOnError = false
+		// The uploader aborts with the context of the upload, which fails once
+		// it was cancelled, abortMultipartUpload does not
+		u.LeavePartsOnError = true
 
 		u.Concurrency = defaultTransferConcurrency
 		if cfg.UploadConcurrency > 0 {
@@ -98,10 +348,27 @@ func (b *awsS3Client) Put(src io.ReadSeeker, dest string) error {
 			u.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
 		}
 	})
+	// The uploader copies the conditions onto CompleteMultipartUpload, and
+	// the SSE-C key onto the parts
+	sse := b.sseCustomerKey()
 	uploadInput := &s3.PutObjectInput{
-		Body:   src,
-		Bucket: aws.String(cfg.BucketName),
-		Key:    b.key(dest),
+		Body:                      src,
+		Bucket:                    aws.String(cfg.BucketName),
+		Key:                       b.key(dest),
+		IfNoneMatch:               conditions.ifNoneMatch,
+		IfMatch:                   conditions.ifMatch,
+		ContentType:               headers.contentType,
+		CacheControl:              headers.cacheControl,
+		ContentDisposition:        headers.contentDisposition,
+		ContentEncoding:           headers.contentEncoding,
+		Metadata:                  headers.metadata,
+		Tagging:                   headers.tagging,
+		StorageClass:              headers.storageClass,
+		ObjectLockMode:            headers.objectLockMode,
+		ObjectLockRetainUntilDate: headers.objectLockRetainUntil,
+		SSECustomerAlgorithm:      sse.algorithm,
+		SSECustomerKey:            sse.key,
+		SSECustomerKeyMD5:         sse.keyMD5,
 	}
 	if cfg.ServerSideEncryption != "" {
 		uploadInput.ServerSideEncryption = types.ServerSideEncryption(cfg.ServerSideEncryption)
@@ -109,42 +376,100 @@ func (b *awsS3Client) Put(src io.ReadSeeker, dest string) error {
 	if cfg.SSEKMSKeyID != "" {
 		uploadInput.SSEKMSKeyId = aws.String(cfg.SSEKMSKeyID)
 	}
+	// S3 verifies a full object checksum only for single part uploads, the
+	// parts of multipart uploads carry checksums of their own. The digests of
+	// compressed or encrypted content are not known up front.
+	singlePart := !cfg.MultipartUpload || (size >= 0 && size <= uploader.PartSize)
+	if digest, ok := strongestDigest(opts.Digests); ok && singlePart && cfg.Compression == "" && encryption == nil && b.additionalChecksumsEnabled() {
+		switch digest.Algorithm {
+		case "sha256":
+			uploadInput.ChecksumSHA256 = aws.String(digest.base64Value())
+		case "sha1":
+			uploadInput.ChecksumSHA1 = aws.String(digest.base64Value())
+		}
+	}
+	// S3 requires a checksum of uploads with a retention, which is left out
+	// when request checksums are disabled unless an algorithm is asked for
+	if headers.objectLockMode != "" && uploadInput.ChecksumSHA256 == nil && uploadInput.ChecksumSHA1 == nil {
+		uploadInput.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32
+	}
 
-	retry := 0
-	maxRetries := 3
-	for {
-		putResult, err := uploader.Upload(context.TODO(), uploadInput) //nolint:staticcheck
-		if err != nil {
-			if _, ok := err.(manager.MultiUploadFailure); ok {
-				if retry == maxRetries {
-					log.Println("Upload retry limit exceeded:", err.Error())
-					return fmt.Errorf("upload retry limit exceeded: %s", err.Error())
-				}
-				retry++
-				time.Sleep(time.Second * time.Duration(retry))
-				continue
-			}
-			log.Println("Upload failed:", err.Error())
-			return fmt.Errorf("upload failure: %s", err.Error())
+	// Parts and single uploads are retried request by request according to
+	// the retry policy, a failure here is final
+	putResult, err := uploader.Upload(ctx, uploadInput) //nolint:staticcheck
+	if err != nil {
+		var multipartErr manager.MultiUploadFailure //nolint:staticcheck
+		if errors.As(err, &multipartErr) {
+			b.abortMultipartUpload(ctx, cfg.BucketName, uploadInput.Key, aws.String(multipartErr.UploadID()))
 		}
+		b.logger.ErrorContext(ctx, "Upload failed", "key", dest, "error", err)
+		return fmt.Errorf("upload failure: %w", err)
+	}
 
-		log.Println("Successfully uploaded file to", putResult.Location)
-		return nil
+	if opts.Result != nil {
+		opts.Result.Size = size
+		if counter != nil {
+			opts.Result.Size = counter.n
+		}
+		opts.Result.ETag = strings.Trim(aws.ToString(putResult.ETag), `"`)
+		opts.Result.VersionID = aws.ToString(putResult.VersionID)
+		opts.Result.Location = putResult.Location
+	}
+
+	b.logger.InfoContext(ctx, "Successfully uploaded file", "key", dest, "location", putResult.Location)
+	return nil
+}
+
+// additionalChecksumsEnabled reports whether the provider supports the
+// x-amz-checksum-* headers of uploads
+func (b *awsS3Client) additionalChecksumsEnabled() bool {
+	cfg := b.s3cliConfig
+	return !cfg.ShouldDisableRequestChecksumCalculation() && !cfg.ShouldDisableUploaderRequestChecksumCalculation()
+}
+
+func spoolToTempFile(src io.Reader) (*os.File, error) {
+	spooled, err := os.CreateTemp("", "s3cli-upload")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(spooled, src); err == nil {
+		_, err = spooled.Seek(0, io.SeekStart)
 	}
+	if err != nil {
+		spooled.Close()           //nolint:errcheck
+		os.Remove(spooled.Name()) //nolint:errcheck
+		return nil, err
+	}
+
+	return spooled, nil
 }
 
-// Delete removes a blob - no error is returned if the object does not exist
-func (b *awsS3Client) Delete(dest string) error {
+// Delete removes a blob - no error is returned if the object does not exist.
+// Deleting a version protected by Object Lock fails with ErrObjectLocked.
+func (b *awsS3Client) Delete(ctx context.Context, dest string, optFns ...func(*DeleteOptions)) error {
 	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
 		return errorInvalidCredentialsSourceValue
 	}
 
+	var opts DeleteOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+	if opts.AllVersions {
+		return b.deleteAllVersions(ctx, dest, opts.BypassGovernanceRetention)
+	}
+
 	deleteParams := &s3.DeleteObjectInput{
-		Bucket: aws.String(b.s3cliConfig.BucketName),
-		Key:    b.key(dest),
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(dest),
+		VersionId: versionIDParam(opts.VersionID),
+	}
+	if opts.BypassGovernanceRetention {
+		deleteParams.BypassGovernanceRetention = aws.Bool(true)
 	}
 
-	_, err := b.s3Client.DeleteObject(context.TODO(), deleteParams)
+	_, err := b.s3Client.DeleteObject(ctx, deleteParams)
 
 	if err == nil {
 		return nil
@@ -154,42 +479,347 @@ func (b *awsS3Client) Delete(dest string) error {
 	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
 		return nil
 	}
-	return err
+	return lockedDeleteError(dest, err)
 }
 
 // Exists checks if blob exists
-func (b *awsS3Client) Exists(dest string) (bool, error) {
+func (b *awsS3Client) Exists(ctx context.Context, dest string, optFns ...func(*ExistsOptions)) (bool, error) {
+	var opts ExistsOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	sse := b.sseCustomerKey()
 	existsParams := &s3.HeadObjectInput{
-		Bucket: aws.String(b.s3cliConfig.BucketName),
-		Key:    b.key(dest),
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(dest),
+		VersionId:            versionIDParam(opts.VersionID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
 	}
 
-	_, err := b.s3Client.HeadObject(context.TODO(), existsParams)
+	_, err := b.s3Client.HeadObject(ctx, existsParams)
 
 	if err == nil {
-		log.Printf("File '%s' exists in bucket '%s'\n", dest, b.s3cliConfig.BucketName)
+		b.logger.InfoContext(ctx, "File exists in bucket", "key", dest, "bucket", b.s3cliConfig.BucketName)
 		return true, nil
 	}
 
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound" {
-		log.Printf("File '%s' does not exist in bucket '%s'\n", dest, b.s3cliConfig.BucketName)
+		b.logger.InfoContext(ctx, "File does not exist in bucket", "key", dest, "bucket", b.s3cliConfig.BucketName)
 		return false, nil
 	}
 	return false, err
 }
 
 // Sign creates a presigned URL
-func (b *awsS3Client) Sign(objectID string, action string, expiration time.Duration) (string, error) {
+func (b *awsS3Client) Sign(ctx context.Context, objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error) {
+	var opts SignOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
 	action = strings.ToUpper(action)
 	switch action {
 	case "GET":
-		return b.getSigned(objectID, expiration)
+		return b.getSigned(ctx, objectID, expiration, opts)
 	case "PUT":
-		return b.putSigned(objectID, expiration)
+		if opts.VersionID != "" {
+			return "", &Error{Kind: ErrInvalidArgument, Err: errors.New("only the GET of an object version can be signed")}
+		}
+		return b.putSigned(ctx, objectID, expiration, opts)
 	default:
-		return "", fmt.Errorf("action not implemented: %s", action)
+		return "", &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("action not implemented: %s", action)}
+	}
+}
+
+// Copy duplicates a blob server-side, keeping its metadata. The copy gets
+// the requested or configured storage class rather than that of the source.
+func (b *awsS3Client) Copy(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error {
+	cfg := b.s3cliConfig
+	if cfg.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	var opts CopyOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	destBucket := cfg.BucketName
+	if opts.DestinationBucket != "" {
+		destBucket = opts.DestinationBucket
+	}
+	if destBucket == cfg.BucketName && src == dest && opts.SourceVersionID == "" && opts.StorageClass == "" {
+		return &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("cannot copy '%s' onto itself", src)}
+	}
+	storageClass := types.StorageClass(firstNonEmpty(opts.StorageClass, cfg.StorageClass))
+
+	sse := b.sseCustomerKey()
+	headParams := &s3.HeadObjectInput{
+		Bucket:               aws.String(cfg.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.SourceVersionID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	head, err := b.s3Client.HeadObject(ctx, headParams)
+	if err != nil {
+		return err
+	}
+
+	startObjectInfo(opts.Result, destBucket, dest)
+	if opts.Result != nil {
+		opts.Result.Size = aws.ToInt64(head.ContentLength)
+	}
+
+	copySource := copySourceValue(cfg.BucketName, aws.ToString(b.key(src)))
+	if opts.SourceVersionID != "" {
+		copySource += "?versionId=" + url.QueryEscape(opts.SourceVersionID)
+	}
+	if aws.ToInt64(head.ContentLength) > maxSingleCopySize {
+		return b.multipartCopy(ctx, copySource, destBucket, b.key(dest), head, storageClass, opts.Result)
+	}
+
+	// The copy is encrypted with the same SSE-C key as its source
+	copyParams := &s3.CopyObjectInput{
+		Bucket:                         aws.String(destBucket),
+		Key:                            b.key(dest),
+		CopySource:                     aws.String(copySource),
+		StorageClass:                   storageClass,
+		SSECustomerAlgorithm:           sse.algorithm,
+		SSECustomerKey:                 sse.key,
+		SSECustomerKeyMD5:              sse.keyMD5,
+		CopySourceSSECustomerAlgorithm: sse.algorithm,
+		CopySourceSSECustomerKey:       sse.key,
+		CopySourceSSECustomerKeyMD5:    sse.keyMD5,
+	}
+	if cfg.ServerSideEncryption != "" {
+		copyParams.ServerSideEncryption = types.ServerSideEncryption(cfg.ServerSideEncryption)
+	}
+	if cfg.SSEKMSKeyID != "" {
+		copyParams.SSEKMSKeyId = aws.String(cfg.SSEKMSKeyID)
+	}
+
+	copyResult, err := b.s3Client.CopyObject(ctx, copyParams)
+	if err != nil {
+		return err
+	}
+
+	if opts.Result != nil {
+		if copyResult.CopyObjectResult != nil {
+			opts.Result.ETag = strings.Trim(aws.ToString(copyResult.CopyObjectResult.ETag), `"`)
+		}
+		opts.Result.VersionID = aws.ToString(copyResult.VersionId)
+	}
+	return nil
+}
+
+// Move copies a blob server-side and removes the source once the copy
+// succeeded. With a SourceVersionID only that version of the source is removed.
+func (b *awsS3Client) Move(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error {
+	var opts CopyOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	// Removing the source of a copy onto itself would remove the only copy
+	destBucket := firstNonEmpty(opts.DestinationBucket, b.s3cliConfig.BucketName)
+	if destBucket == b.s3cliConfig.BucketName && src == dest && opts.SourceVersionID == "" {
+		return &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("cannot move '%s' onto itself", src)}
+	}
+
+	if err := b.Copy(ctx, src, dest, optFns...); err != nil {
+		return err
+	}
+
+	return b.Delete(ctx, src, func(o *DeleteOptions) {
+		o.VersionID = opts.SourceVersionID
+	})
+}
+
+func (b *awsS3Client) multipartCopy(ctx context.Context, copySource string, destBucket string, destKey *string, head *s3.HeadObjectOutput, storageClass types.StorageClass, result *ObjectInfo) error {
+	cfg := b.s3cliConfig
+	size := aws.ToInt64(head.ContentLength)
+
+	partSize := defaultCopyPartSize
+	if minPartSize := (size + maxUploadParts - 1) / maxUploadParts; partSize < minPartSize {
+		partSize = minPartSize
+	}
+
+	// UploadPartCopy only copies data, the metadata has to be carried over by hand
+	sse := b.sseCustomerKey()
+	createParams := &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(destBucket),
+		Key:                  destKey,
+		Metadata:             head.Metadata,
+		CacheControl:         head.CacheControl,
+		ContentDisposition:   head.ContentDisposition,
+		ContentEncoding:      head.ContentEncoding,
+		ContentLanguage:      head.ContentLanguage,
+		ContentType:          head.ContentType,
+		StorageClass:         storageClass,
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	if cfg.ServerSideEncryption != "" {
+		createParams.ServerSideEncryption = types.ServerSideEncryption(cfg.ServerSideEncryption)
+	}
+	if cfg.SSEKMSKeyID != "" {
+		createParams.SSEKMSKeyId = aws.String(cfg.SSEKMSKeyID)
+	}
+
+	upload, err := b.s3Client.CreateMultipartUpload(ctx, createParams)
+	if err != nil {
+		return err
 	}
+
+	concurrency := defaultTransferConcurrency
+	if cfg.UploadConcurrency > 0 {
+		concurrency = cfg.UploadConcurrency
+	}
+
+	partCount := (size + partSize - 1) / partSize
+	completedParts := make([]types.CompletedPart, partCount)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	for i := int64(0); i < partCount; i++ {
+		partNumber := int32(i + 1)
+		firstByte := i * partSize
+		lastByte := min(firstByte+partSize, size) - 1
+
+		group.Go(func() error {
+			part, err := b.s3Client.UploadPartCopy(groupCtx, &s3.UploadPartCopyInput{
+				Bucket:                         aws.String(destBucket),
+				Key:                            destKey,
+				UploadId:                       upload.UploadId,
+				PartNumber:                     aws.Int32(partNumber),
+				CopySource:                     aws.String(copySource),
+				CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", firstByte, lastByte)),
+				SSECustomerAlgorithm:           sse.algorithm,
+				SSECustomerKey:                 sse.key,
+				SSECustomerKeyMD5:              sse.keyMD5,
+				CopySourceSSECustomerAlgorithm: sse.algorithm,
+				CopySourceSSECustomerKey:       sse.key,
+				CopySourceSSECustomerKeyMD5:    sse.keyMD5,
+			})
+			if err != nil {
+				return fmt.Errorf("copying part %d: %w", partNumber, err)
+			}
+
+			completedParts[partNumber-1] = types.CompletedPart{
+				ETag:       part.CopyPartResult.ETag,
+				PartNumber: aws.Int32(partNumber),
+			}
+			return nil
+		})
+	}
+
+	var completeResult *s3.CompleteMultipartUploadOutput
+	if err = group.Wait(); err == nil {
+		completeResult, err = b.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:               aws.String(destBucket),
+			Key:                  destKey,
+			UploadId:             upload.UploadId,
+			MultipartUpload:      &types.CompletedMultipartUpload{Parts: completedParts},
+			SSECustomerAlgorithm: sse.algorithm,
+			SSECustomerKey:       sse.key,
+			SSECustomerKeyMD5:    sse.keyMD5,
+		})
+	}
+
+	if err != nil {
+		b.abortMultipartUpload(ctx, destBucket, destKey, upload.UploadId)
+		return err
+	}
+
+	if result != nil {
+		result.ETag = strings.Trim(aws.ToString(completeResult.ETag), `"`)
+		result.VersionID = aws.ToString(completeResult.VersionId)
+	}
+	return nil
+}
+
+// abortMultipartUpload discards the parts of a failed multipart upload or
+// copy. It still runs once ctx has been cancelled, e.g. on SIGINT, so that
+// no parts are left behind.
+func (b *awsS3Client) abortMultipartUpload(ctx context.Context, bucket string, key *string, uploadID *string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	_, err := b.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      key,
+		UploadId: uploadID,
+	})
+	if err != nil {
+		b.logger.WarnContext(ctx, "Aborting multipart upload failed", "key", aws.ToString(key), "upload_id", aws.ToString(uploadID), "error", err)
+	}
+}
+
+// List pages through the objects below prefix and calls fn for each of them
+func (b *awsS3Client) List(ctx context.Context, prefix string, fn func(ListEntry) error, optFns ...func(*ListOptions)) error {
+	var opts ListOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	listParams := &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.s3cliConfig.BucketName),
+		Prefix: b.key(prefix),
+	}
+	if opts.Delimiter != "" {
+		listParams.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.PageSize > 0 {
+		listParams.MaxKeys = aws.Int32(opts.PageSize)
+	}
+	if opts.StartAfter != "" {
+		listParams.StartAfter = b.key(opts.StartAfter)
+	}
+
+	listed := 0
+	paginator := s3.NewListObjectsV2Paginator(b.s3Client, listParams)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		entries := make([]ListEntry, 0, len(page.CommonPrefixes)+len(page.Contents))
+		for _, commonPrefix := range page.CommonPrefixes {
+			entries = append(entries, ListEntry{
+				Key:      b.relativeKey(aws.ToString(commonPrefix.Prefix)),
+				IsPrefix: true,
+			})
+		}
+		for _, object := range page.Contents {
+			entries = append(entries, ListEntry{
+				Key:          b.relativeKey(aws.ToString(object.Key)),
+				Size:         aws.ToInt64(object.Size),
+				ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+				LastModified: aws.ToTime(object.LastModified),
+				StorageClass: string(object.StorageClass),
+			})
+		}
+
+		for _, entry := range entries {
+			if opts.MaxEntries > 0 && listed == opts.MaxEntries {
+				return nil
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+			listed++
+		}
+	}
+
+	return nil
 }
 
 func (b *awsS3Client) key(srcOrDest string) *string {
@@ -201,32 +831,70 @@ func (b *awsS3Client) key(srcOrDest string) *string {
 	return formattedKey
 }
 
-func (b *awsS3Client) getSigned(objectID string, expiration time.Duration) (string, error) {
+// copySourceValue builds the URL-encoded "bucket/key" value CopyObject and
+// UploadPartCopy expect, leaving the path separators intact. Spaces are sent
+// as %20 since some providers decode '+' as a literal plus sign.
+func copySourceValue(bucket string, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// relativeKey strips the configured folder from a key returned by S3
+func (b *awsS3Client) relativeKey(key string) string {
+	if len(b.s3cliConfig.FolderName) != 0 {
+		return strings.TrimPrefix(key, b.s3cliConfig.FolderName+"/")
+	}
+
+	return key
+}
+
+// getSigned and putSigned sign the SSE-C headers along with the URL, its
+// requests have to send them as they are
+func (b *awsS3Client) getSigned(ctx context.Context, objectID string, expiration time.Duration, opts SignOptions) (string, error) {
 	presignClient := s3.NewPresignClient(b.s3Client)
+	sse := b.sseCustomerKey()
 	signParams := &s3.GetObjectInput{
-		Bucket: aws.String(b.s3cliConfig.BucketName),
-		Key:    b.key(objectID),
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(objectID),
+		VersionId:            versionIDParam(opts.VersionID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
 	}
 
-	req, err := presignClient.PresignGetObject(context.TODO(), signParams, s3.WithPresignExpires(expiration))
+	req, err := presignClient.PresignGetObject(ctx, signParams, s3.WithPresignExpires(expiration))
 	if err != nil {
 		return "", err
 	}
 
+	if opts.SignedHeaders != nil {
+		*opts.SignedHeaders = sseCustomerKeyHeaders(req.SignedHeader)
+	}
 	return req.URL, nil
 }
 
-func (b *awsS3Client) putSigned(objectID string, expiration time.Duration) (string, error) {
+func (b *awsS3Client) putSigned(ctx context.Context, objectID string, expiration time.Duration, opts SignOptions) (string, error) {
 	presignClient := s3.NewPresignClient(b.s3Client)
+	sse := b.sseCustomerKey()
 	signParams := &s3.PutObjectInput{
-		Bucket: aws.String(b.s3cliConfig.BucketName),
-		Key:    b.key(objectID),
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(objectID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
 	}
 
-	req, err := presignClient.PresignPutObject(context.TODO(), signParams, s3.WithPresignExpires(expiration))
+	req, err := presignClient.PresignPutObject(ctx, signParams, s3.WithPresignExpires(expiration))
 	if err != nil {
 		return "", err
 	}
 
+	if opts.SignedHeaders != nil {
+		*opts.SignedHeaders = sseCustomerKeyHeaders(req.SignedHeader)
+	}
 	return req.URL, nil
 }
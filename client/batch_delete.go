@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// maxDeleteBatchSize is the number of keys a DeleteObjects request accepts
+const maxDeleteBatchSize = 1000
+
+// DeleteFailure is a key a batch delete could not remove
+type DeleteFailure struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// BatchDeleteError lists the keys a batch delete could not remove. The other
+// keys were removed.
+type BatchDeleteError struct {
+	Failures []DeleteFailure
+}
+
+func (e *BatchDeleteError) Error() string {
+	first := e.Failures[0]
+	return fmt.Sprintf("failed to delete %d keys, e.g. '%s': %s: %s", len(e.Failures), first.Key, first.Code, first.Message)
+}
+
+// DeletePrefix removes every blob below prefix, see DeleteKeys
+func (b *awsS3Client) DeletePrefix(ctx context.Context, prefix string, optFns ...func(*BatchDeleteOptions)) error {
+	return b.batchDelete(ctx, func(fn func(string) error) error {
+		return b.List(ctx, prefix, func(entry ListEntry) error {
+			return fn(entry.Key)
+		})
+	}, optFns)
+}
+
+// DeleteKeys removes keys with DeleteObjects requests of up to 1000 keys,
+// sending several of them concurrently. Keys which do not exist count as
+// removed. Keys failing individually are returned as BatchDeleteError once
+// all batches completed; a failing request stops the whole delete.
+func (b *awsS3Client) DeleteKeys(ctx context.Context, keys []string, optFns ...func(*BatchDeleteOptions)) error {
+	return b.batchDelete(ctx, func(fn func(string) error) error {
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, optFns)
+}
+
+// batchDelete removes the keys walk passes to its callback
+func (b *awsS3Client) batchDelete(ctx context.Context, walk func(func(string) error) error, optFns []func(*BatchDeleteOptions)) error {
+	var opts BatchDeleteOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	if !opts.DryRun && b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultTransferConcurrency
+	}
+
+	var mutex sync.Mutex
+	var failures []DeleteFailure
+	deleted := func(keys []string) {
+		if opts.Deleted == nil {
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, key := range keys {
+			opts.Deleted(key)
+		}
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	send := func(batch []string) {
+		group.Go(func() error {
+			if opts.DryRun {
+				deleted(batch)
+				return nil
+			}
+
+			batchDeleted, batchFailures, err := b.deleteBatch(groupCtx, batch)
+			if err != nil {
+				return err
+			}
+			deleted(batchDeleted)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			failures = append(failures, batchFailures...)
+			return nil
+		})
+	}
+
+	batch := make([]string, 0, maxDeleteBatchSize)
+	walkErr := walk(func(key string) error {
+		// Stop walking once a batch failed
+		if err := groupCtx.Err(); err != nil {
+			return err
+		}
+
+		batch = append(batch, key)
+		if len(batch) == maxDeleteBatchSize {
+			send(batch)
+			batch = make([]string, 0, maxDeleteBatchSize)
+		}
+		return nil
+	})
+	if walkErr == nil && len(batch) > 0 {
+		send(batch)
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+
+	if len(failures) > 0 {
+		return &BatchDeleteError{Failures: failures}
+	}
+	return nil
+}
+
+// deleteBatch removes up to 1000 keys and returns those it removed and those
+// it failed to remove
+func (b *awsS3Client) deleteBatch(ctx context.Context, keys []string) ([]string, []DeleteFailure, error) {
+	// Google's XML API has no DeleteObjects, remove the keys one by one
+	if b.s3cliConfig.IsGoogle() {
+		for _, key := range keys {
+			if err := b.Delete(ctx, key); err != nil {
+				return nil, nil, err
+			}
+		}
+		return keys, nil, nil
+	}
+
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: b.key(key)})
+	}
+
+	resp, err := b.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(b.s3cliConfig.BucketName),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	failed := map[string]struct{}{}
+	failures := make([]DeleteFailure, 0, len(resp.Errors))
+	for _, deleteErr := range resp.Errors {
+		key := b.relativeKey(aws.ToString(deleteErr.Key))
+		failed[key] = struct{}{}
+		failures = append(failures, DeleteFailure{
+			Key:     key,
+			Code:    aws.ToString(deleteErr.Code),
+			Message: aws.ToString(deleteErr.Message),
+		})
+	}
+
+	deleted := make([]string, 0, len(keys)-len(failed))
+	for _, key := range keys {
+		if _, ok := failed[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+
+	return deleted, failures, nil
+}
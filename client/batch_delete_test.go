@@ -0,0 +1,131 @@
+package client_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch delete", func() {
+	var server *httptest.Server
+	var batchSizes []int
+	var failingKey string
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		batchSizes = nil
+		failingKey = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			switch {
+			case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+				fmt.Fprint(w, `<ListBucketResult>`) //nolint:errcheck
+				for i := 0; i < 3; i++ {
+					fmt.Fprintf(w, `<Contents><Key>some-folder/some-prefix/key-%d</Key></Contents>`, i) //nolint:errcheck
+				}
+				fmt.Fprint(w, `</ListBucketResult>`) //nolint:errcheck
+			case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+				var request struct {
+					Objects []struct {
+						Key string `xml:"Key"`
+					} `xml:"Object"`
+				}
+				if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				batchSizes = append(batchSizes, len(request.Objects))
+
+				fmt.Fprint(w, `<DeleteResult>`) //nolint:errcheck
+				for _, object := range request.Objects {
+					if object.Key == failingKey {
+						fmt.Fprintf(w, `<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`, object.Key) //nolint:errcheck
+					}
+				}
+				fmt.Fprint(w, `</DeleteResult>`) //nolint:errcheck
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		blobstoreClient = newTestClient(server.URL, newTestConfig())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	keys := func(n int) []string {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("key-%04d", i)
+		}
+		return keys
+	}
+
+	It("deletes the keys in batches of 1000", func() {
+		var deleted []string
+		err := blobstoreClient.DeleteKeys(keys(2500), func(o *client.BatchDeleteOptions) {
+			o.Deleted = func(key string) { deleted = append(deleted, key) }
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		sort.Ints(batchSizes)
+		Expect(batchSizes).To(Equal([]int{500, 1000, 1000}))
+		sort.Strings(deleted)
+		Expect(deleted).To(Equal(keys(2500)))
+	})
+
+	It("reports the keys which could not be deleted", func() {
+		failingKey = "some-folder/key-0042"
+
+		var deleted []string
+		err := blobstoreClient.DeleteKeys(keys(100), func(o *client.BatchDeleteOptions) {
+			o.Deleted = func(key string) { deleted = append(deleted, key) }
+		})
+
+		var batchErr *client.BatchDeleteError
+		Expect(errors.As(err, &batchErr)).To(BeTrue())
+		Expect(batchErr.Failures).To(Equal([]client.DeleteFailure{
+			{Key: "key-0042", Code: "AccessDenied", Message: "Access Denied"},
+		}))
+		Expect(deleted).To(HaveLen(99))
+		Expect(deleted).NotTo(ContainElement("key-0042"))
+	})
+
+	It("deletes every key below a prefix", func() {
+		var deleted []string
+		err := blobstoreClient.DeletePrefix("some-prefix/", func(o *client.BatchDeleteOptions) {
+			o.Deleted = func(key string) { deleted = append(deleted, key) }
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(batchSizes).To(Equal([]int{3}))
+		Expect(strings.Join(deleted, ",")).To(Equal("some-prefix/key-0,some-prefix/key-1,some-prefix/key-2"))
+	})
+
+	It("only reports the keys in a dry run", func() {
+		var deleted []string
+		err := blobstoreClient.DeletePrefix("some-prefix/", func(o *client.BatchDeleteOptions) {
+			o.DryRun = true
+			o.Deleted = func(key string) { deleted = append(deleted, key) }
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(batchSizes).To(BeEmpty())
+		Expect(deleted).To(HaveLen(3))
+	})
+})
@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
 	"io"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -9,15 +12,367 @@ import (
 	"github.com/cloudfoundry/bosh-s3cli/config"
 )
 
+// S3CompatibleClient operates on the blobs of the configured bucket. The
+// WithContext variants stop when ctx is cancelled; a multipart upload or copy
+// interrupted that way is aborted, unless it is kept to be resumed. The other
+// methods use context.Background(). All of them are limited by
+// operation_timeout_seconds. Errors of a known kind are returned as *Error,
+// e.g. errors.Is(err, ErrNotFound) reports a missing object.
 type S3CompatibleClient interface {
-	Get(src string, dest io.WriterAt) error
-	Put(src io.ReadSeeker, dest string) error
-	Delete(dest string) error
-	Exists(dest string) (bool, error)
-	Sign(objectID string, action string, expiration time.Duration) (string, error)
+	Get(src string, dest io.WriterAt, optFns ...func(*GetOptions)) error
+	GetWithContext(ctx context.Context, src string, dest io.WriterAt, optFns ...func(*GetOptions)) error
+	GetStream(src string, dest io.Writer, optFns ...func(*GetOptions)) error
+	GetStreamWithContext(ctx context.Context, src string, dest io.Writer, optFns ...func(*GetOptions)) error
+	GetFile(src string, destPath string, optFns ...func(*GetOptions)) error
+	GetFileWithContext(ctx context.Context, src string, destPath string, optFns ...func(*GetOptions)) error
+	Put(src io.Reader, dest string, optFns ...func(*PutOptions)) error
+	PutWithContext(ctx context.Context, src io.Reader, dest string, optFns ...func(*PutOptions)) error
+	Delete(dest string, optFns ...func(*DeleteOptions)) error
+	DeleteWithContext(ctx context.Context, dest string, optFns ...func(*DeleteOptions)) error
+	DeleteKeys(keys []string, optFns ...func(*BatchDeleteOptions)) error
+	DeleteKeysWithContext(ctx context.Context, keys []string, optFns ...func(*BatchDeleteOptions)) error
+	DeletePrefix(prefix string, optFns ...func(*BatchDeleteOptions)) error
+	DeletePrefixWithContext(ctx context.Context, prefix string, optFns ...func(*BatchDeleteOptions)) error
+	Exists(dest string, optFns ...func(*ExistsOptions)) (bool, error)
+	ExistsWithContext(ctx context.Context, dest string, optFns ...func(*ExistsOptions)) (bool, error)
+	Stat(key string, optFns ...func(*StatOptions)) (ObjectMetadata, error)
+	StatWithContext(ctx context.Context, key string, optFns ...func(*StatOptions)) (ObjectMetadata, error)
+	Tags(key string, optFns ...func(*TagOptions)) (map[string]string, error)
+	TagsWithContext(ctx context.Context, key string, optFns ...func(*TagOptions)) (map[string]string, error)
+	Tag(key string, tags map[string]string, optFns ...func(*TagOptions)) error
+	TagWithContext(ctx context.Context, key string, tags map[string]string, optFns ...func(*TagOptions)) error
+	Untag(key string, names []string, optFns ...func(*TagOptions)) error
+	UntagWithContext(ctx context.Context, key string, names []string, optFns ...func(*TagOptions)) error
+	Sign(objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error)
+	SignWithContext(ctx context.Context, objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error)
+	List(prefix string, fn func(ListEntry) error, optFns ...func(*ListOptions)) error
+	ListWithContext(ctx context.Context, prefix string, fn func(ListEntry) error, optFns ...func(*ListOptions)) error
+	ListVersions(key string, fn func(ObjectVersion) error) error
+	ListVersionsWithContext(ctx context.Context, key string, fn func(ObjectVersion) error) error
+	Copy(src string, dest string, optFns ...func(*CopyOptions)) error
+	CopyWithContext(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error
+	Move(src string, dest string, optFns ...func(*CopyOptions)) error
+	MoveWithContext(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error
+	SyncUpload(localDir string, prefix string, optFns ...func(*SyncOptions)) error
+	SyncUploadWithContext(ctx context.Context, localDir string, prefix string, optFns ...func(*SyncOptions)) error
+	SyncDownload(prefix string, localDir string, optFns ...func(*SyncOptions)) error
+	SyncDownloadWithContext(ctx context.Context, prefix string, localDir string, optFns ...func(*SyncOptions)) error
+	Restore(key string, optFns ...func(*RestoreOptions)) error
+	RestoreWithContext(ctx context.Context, key string, optFns ...func(*RestoreOptions)) error
+	RestoreStatus(key string, optFns ...func(*RestoreOptions)) (RestoreStatus, error)
+	RestoreStatusWithContext(ctx context.Context, key string, optFns ...func(*RestoreOptions)) (RestoreStatus, error)
+	Retention(key string, optFns ...func(*ObjectLockOptions)) (Retention, error)
+	RetentionWithContext(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (Retention, error)
+	SetRetention(key string, retention Retention, optFns ...func(*ObjectLockOptions)) error
+	SetRetentionWithContext(ctx context.Context, key string, retention Retention, optFns ...func(*ObjectLockOptions)) error
+	LegalHold(key string, optFns ...func(*ObjectLockOptions)) (bool, error)
+	LegalHoldWithContext(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (bool, error)
+	SetLegalHold(key string, on bool, optFns ...func(*ObjectLockOptions)) error
+	SetLegalHoldWithContext(ctx context.Context, key string, on bool, optFns ...func(*ObjectLockOptions)) error
 }
 
-// New returns an S3CompatibleClient
+// GetOptions tunes Get, GetStream and GetFile
+type GetOptions struct {
+	// Resume keeps the partial file of a failed download next to the
+	// destination, so that the next GetFile of the same object only fetches
+	// the missing bytes. Only GetFile can resume.
+	Resume bool
+	// Digests are checked against the downloaded content, a mismatch fails
+	// the download. Content is hashed while it is written, which requires
+	// writing it in order instead of at parallel offsets.
+	Digests []Digest
+	// Result, if set, receives the details of the downloaded object
+	Result *ObjectInfo
+	// VersionID fetches that version of the object instead of the latest one
+	VersionID string
+	// Range fetches only part of the object, an HTTP byte range such as
+	// "bytes=0-99" or "bytes=-100". It cannot be combined with Digests nor Resume.
+	Range string
+	// IfNoneMatch skips the download with ErrNotModified if the object still
+	// has this ETag, e.g. that of a cached copy
+	IfNoneMatch string
+	// IfModifiedSince skips the download with ErrNotModified unless the object
+	// was modified after this time
+	IfModifiedSince time.Time
+	// Raw fetches a compressed object as it is stored instead of
+	// decompressing it
+	Raw bool
+}
+
+// PutOptions tunes Put
+type PutOptions struct {
+	// Digests are checked against the uploaded content. A mismatch fails the
+	// upload before the object is created.
+	Digests []Digest
+	// StoreDigests records the digests as object metadata, e.g. x-amz-meta-sha256
+	StoreDigests bool
+	// Result, if set, receives the details of the uploaded object
+	Result *ObjectInfo
+	// NoOverwrite fails the upload with ErrPreconditionFailed if the object
+	// exists, also when if_none_match is configured
+	NoOverwrite bool
+	// IfMatch fails the upload with ErrPreconditionFailed unless the object
+	// has this ETag. It cannot be combined with NoOverwrite.
+	IfMatch string
+	// ContentType, CacheControl, ContentDisposition and ContentEncoding
+	// override the configured defaults
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	// Metadata is added to the configured metadata as x-amz-meta-<name>
+	// headers, replacing entries of the same name
+	Metadata map[string]string
+	// Tags are added to the configured tags, replacing those of the same name
+	Tags map[string]string
+	// StorageClass overrides the configured storage class, e.g. GLACIER
+	StorageClass string
+	// Retention overrides the configured Object Lock retention. A retention
+	// with only a mode or only a date takes the other from the configuration.
+	Retention Retention
+}
+
+// DeleteOptions tunes Delete
+type DeleteOptions struct {
+	// VersionID permanently deletes that version of the object. Without it
+	// a versioned bucket only records a delete marker.
+	VersionID string
+	// AllVersions permanently deletes every version and delete marker of the object
+	AllVersions bool
+	// BypassGovernanceRetention deletes versions under a GOVERNANCE retention,
+	// which requires the s3:BypassGovernanceRetention permission
+	BypassGovernanceRetention bool
+}
+
+// BatchDeleteOptions tunes DeleteKeys and DeletePrefix
+type BatchDeleteOptions struct {
+	// DryRun only reports the keys which would be deleted
+	DryRun bool
+	// Concurrency is the number of DeleteObjects requests sent at once, 5 by default
+	Concurrency int
+	// Deleted, if set, is called with every deleted key, or every key which
+	// would be deleted in a dry run. Calls do not overlap.
+	Deleted func(key string)
+}
+
+// StatOptions tunes Stat
+type StatOptions struct {
+	// VersionID describes that version of the object instead of the latest one
+	VersionID string
+}
+
+// TagOptions tunes Tags, Tag and Untag
+type TagOptions struct {
+	// VersionID operates on the tags of that version of the object instead
+	// of the latest one
+	VersionID string
+}
+
+// ExistsOptions tunes Exists
+type ExistsOptions struct {
+	// VersionID checks that version of the object instead of the latest one
+	VersionID string
+}
+
+// SignOptions tunes Sign
+type SignOptions struct {
+	// VersionID signs the GET of that version of the object
+	VersionID string
+	// SignedHeaders, if set, receives the SSE-C headers the URL is signed
+	// with, which its requests have to send
+	SignedHeaders *http.Header
+}
+
+// ListOptions tunes how List walks the bucket
+type ListOptions struct {
+	// Delimiter groups keys sharing a prefix up to the delimiter into a single
+	// entry, e.g. "/" lists the "directories" directly below the prefix
+	Delimiter string
+	// PageSize is the number of keys requested per ListObjectsV2 call; zero
+	// leaves it to the server (usually 1000)
+	PageSize int32
+	// MaxEntries stops the listing after that many entries; zero means no limit
+	MaxEntries int
+	// StartAfter lists only the keys that sort after this key
+	StartAfter string
+}
+
+// ListEntry describes an object or, in delimiter mode, a common prefix.
+// Keys are relative to the configured folder_name.
+type ListEntry struct {
+	Key          string
+	IsPrefix     bool
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	StorageClass string
+}
+
+// CopyOptions tunes server-side Copy and Move
+type CopyOptions struct {
+	// DestinationBucket copies into another bucket reachable with the same
+	// credentials; the configured folder_name still applies to the destination key
+	DestinationBucket string
+	// Result, if set, receives the details of the copy
+	Result *ObjectInfo
+	// SourceVersionID copies that version of the source. Copying an old
+	// version onto its own key restores it as the latest version.
+	SourceVersionID string
+	// StorageClass of the copy, overriding the configured storage class.
+	// Copying an object onto its own key with it changes its storage class.
+	StorageClass string
+}
+
+// RestoreOptions tunes Restore and RestoreStatus
+type RestoreOptions struct {
+	// VersionID restores that version of the object instead of the latest one
+	VersionID string
+	// Days the restored copy is kept before it is removed again. Zero leaves
+	// it out, as restores from the Intelligent-Tiering archive tiers require.
+	Days int32
+	// Tier is the retrieval tier: Standard, the default, Bulk or Expedited
+	Tier string
+}
+
+// ObjectLockOptions tunes Retention, SetRetention, LegalHold and SetLegalHold
+type ObjectLockOptions struct {
+	// VersionID operates on that version of the object instead of the latest one
+	VersionID string
+	// BypassGovernanceRetention lets SetRetention shorten or remove a
+	// GOVERNANCE retention, which requires the s3:BypassGovernanceRetention
+	// permission
+	BypassGovernanceRetention bool
+}
+
+// SyncOptions tunes SyncUpload and SyncDownload
+type SyncOptions struct {
+	// Delete removes the files or keys missing on the source side
+	Delete bool
+	// DryRun only reports what would be transferred and deleted
+	DryRun bool
+	// SizeOnly compares files by size, skipping the MD5 comparison with the
+	// ETag, e.g. for objects which ETag is no MD5
+	SizeOnly bool
+	// Concurrency is the number of files transferred at once, 5 by default
+	Concurrency int
+	// Synced, if set, is called with every transfer and deletion once it is
+	// done, or would be done in a dry run. Calls do not overlap.
+	Synced func(SyncAction)
+}
+
+// ObjectVersion describes a version or a delete marker of an object in a
+// versioned bucket. The key is relative to the configured folder_name.
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	Size           int64
+	ETag           string
+	LastModified   time.Time
+	StorageClass   string
+}
+
+// ObjectInfo describes the object an operation transferred or created.
+// Fields the blobstore did not report are left empty, e.g. VersionID in
+// buckets without versioning.
+type ObjectInfo struct {
+	Key       string
+	Bucket    string
+	Size      int64
+	ETag      string
+	VersionID string
+	// Location is the URL of an uploaded object
+	Location string
+	// ContentRange is the part of the object a ranged download fetched, e.g.
+	// "bytes 0-99/1000". Size remains the size of the whole object.
+	ContentRange string
+	// ResumedFrom is the offset a resumed download continued from, the
+	// bytes before it were fetched by an earlier download
+	ResumedFrom int64
+}
+
+// States of the restore of an object reported by RestoreStatus
+const (
+	// RestoreStateNotArchived objects can be read without a restore
+	RestoreStateNotArchived = "not-archived"
+	// RestoreStateArchived objects have to be restored before they can be read
+	RestoreStateArchived = "archived"
+	// RestoreStateRestoring objects were requested to be restored, which
+	// takes minutes to hours depending on the tier
+	RestoreStateRestoring = "restoring"
+	// RestoreStateRestored objects can be read until the restored copy expires
+	RestoreStateRestored = "restored"
+)
+
+// RestoreStatus describes whether an object is archived and the state of its
+// restore
+type RestoreStatus struct {
+	Key          string
+	Bucket       string
+	VersionID    string
+	StorageClass string
+	// ArchiveStatus is the archive tier of an Intelligent-Tiering object,
+	// ARCHIVE_ACCESS or DEEP_ARCHIVE_ACCESS
+	ArchiveStatus string
+	// State is one of the RestoreState* constants
+	State string
+	// ExpiryDate is when the restored copy is removed again, zero unless
+	// the object was restored
+	ExpiryDate time.Time
+}
+
+// Retention is the Object Lock retention of an object, which can neither be
+// deleted nor overwritten before RetainUntilDate. The zero Retention is none.
+type Retention struct {
+	// Mode is GOVERNANCE, which the s3:BypassGovernanceRetention permission
+	// lifts, or COMPLIANCE, which nobody can lift
+	Mode            string
+	RetainUntilDate time.Time
+}
+
+// ObjectMetadata describes an object as HeadObject reports it. Fields the
+// blobstore did not report are left empty, e.g. StorageClass for STANDARD
+// objects on AWS.
+type ObjectMetadata struct {
+	Key          string
+	Bucket       string
+	VersionID    string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+	StorageClass string
+	// CacheControl, ContentDisposition and ContentEncoding are the content
+	// headers the object was uploaded with
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	// ServerSideEncryption is the SSE algorithm, e.g. AES256 or aws:kms
+	ServerSideEncryption string
+	SSEKMSKeyID          string
+	// Metadata is the user metadata, by name without the x-amz-meta- prefix
+	Metadata map[string]string
+	TagCount int
+	// Checksums are the additional checksums of the object by algorithm,
+	// e.g. SHA256, as base64 encoded in S3
+	Checksums map[string]string
+	// ChecksumType is FULL_OBJECT or COMPOSITE, the checksums of multipart
+	// uploads being checksums of the checksums of their parts
+	ChecksumType              string
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHold       string
+	// Compression is the algorithm the content was compressed with, gzip or
+	// zstd, and Encryption the scheme of its client-side encryption.
+	// OriginalSize is its size before either, Size the size as stored.
+	Compression  string
+	Encryption   string
+	OriginalSize int64
+}
+
+// New returns an S3CompatibleClient logging to stderr according to the
+// log_level and log_format of s3cliConfig
 func New(s3Client *s3.Client, s3cliConfig *config.S3Cli) S3CompatibleClient {
 	return &s3CompatibleClient{
 		s3cliConfig: s3cliConfig,
@@ -27,6 +382,7 @@ func New(s3Client *s3.Client, s3cliConfig *config.S3Cli) S3CompatibleClient {
 		awsS3BlobstoreClient: &awsS3Client{
 			s3Client:    s3Client,
 			s3cliConfig: s3cliConfig,
+			logger:      NewLogger(os.Stderr, s3cliConfig),
 		},
 	}
 }
@@ -37,26 +393,297 @@ type s3CompatibleClient struct {
 	openstackSwiftBlobstore *openstackSwiftS3Client
 }
 
-func (c *s3CompatibleClient) Get(src string, dest io.WriterAt) error {
-	return c.awsS3BlobstoreClient.Get(src, dest)
+func (c *s3CompatibleClient) Get(src string, dest io.WriterAt, optFns ...func(*GetOptions)) error {
+	return c.GetWithContext(context.Background(), src, dest, optFns...)
 }
 
-func (c *s3CompatibleClient) Put(src io.ReadSeeker, dest string) error {
-	return c.awsS3BlobstoreClient.Put(src, dest)
+func (c *s3CompatibleClient) GetWithContext(ctx context.Context, src string, dest io.WriterAt, optFns ...func(*GetOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Get(ctx, src, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) GetStream(src string, dest io.Writer, optFns ...func(*GetOptions)) error {
+	return c.GetStreamWithContext(context.Background(), src, dest, optFns...)
+}
+
+func (c *s3CompatibleClient) GetStreamWithContext(ctx context.Context, src string, dest io.Writer, optFns ...func(*GetOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.GetStream(ctx, src, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) GetFile(src string, destPath string, optFns ...func(*GetOptions)) error {
+	return c.GetFileWithContext(context.Background(), src, destPath, optFns...)
+}
+
+func (c *s3CompatibleClient) GetFileWithContext(ctx context.Context, src string, destPath string, optFns ...func(*GetOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.GetFile(ctx, src, destPath, optFns...))
+}
+
+func (c *s3CompatibleClient) Put(src io.Reader, dest string, optFns ...func(*PutOptions)) error {
+	return c.PutWithContext(context.Background(), src, dest, optFns...)
+}
+
+func (c *s3CompatibleClient) PutWithContext(ctx context.Context, src io.Reader, dest string, optFns ...func(*PutOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Put(ctx, src, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) Delete(dest string, optFns ...func(*DeleteOptions)) error {
+	return c.DeleteWithContext(context.Background(), dest, optFns...)
+}
+
+func (c *s3CompatibleClient) DeleteWithContext(ctx context.Context, dest string, optFns ...func(*DeleteOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Delete(ctx, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) DeleteKeys(keys []string, optFns ...func(*BatchDeleteOptions)) error {
+	return c.DeleteKeysWithContext(context.Background(), keys, optFns...)
+}
+
+func (c *s3CompatibleClient) DeleteKeysWithContext(ctx context.Context, keys []string, optFns ...func(*BatchDeleteOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.DeleteKeys(ctx, keys, optFns...))
+}
+
+func (c *s3CompatibleClient) DeletePrefix(prefix string, optFns ...func(*BatchDeleteOptions)) error {
+	return c.DeletePrefixWithContext(context.Background(), prefix, optFns...)
+}
+
+func (c *s3CompatibleClient) DeletePrefixWithContext(ctx context.Context, prefix string, optFns ...func(*BatchDeleteOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.DeletePrefix(ctx, prefix, optFns...))
+}
+
+func (c *s3CompatibleClient) Exists(dest string, optFns ...func(*ExistsOptions)) (bool, error) {
+	return c.ExistsWithContext(context.Background(), dest, optFns...)
+}
+
+func (c *s3CompatibleClient) ExistsWithContext(ctx context.Context, dest string, optFns ...func(*ExistsOptions)) (bool, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	exists, err := c.awsS3BlobstoreClient.Exists(ctx, dest, optFns...)
+	return exists, classifyError(err)
 }
 
-func (c *s3CompatibleClient) Delete(dest string) error {
-	return c.awsS3BlobstoreClient.Delete(dest)
+func (c *s3CompatibleClient) Stat(key string, optFns ...func(*StatOptions)) (ObjectMetadata, error) {
+	return c.StatWithContext(context.Background(), key, optFns...)
 }
 
-func (c *s3CompatibleClient) Exists(dest string) (bool, error) {
-	return c.awsS3BlobstoreClient.Exists(dest)
+func (c *s3CompatibleClient) StatWithContext(ctx context.Context, key string, optFns ...func(*StatOptions)) (ObjectMetadata, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	metadata, err := c.awsS3BlobstoreClient.Stat(ctx, key, optFns...)
+	return metadata, classifyError(err)
 }
 
-func (c *s3CompatibleClient) Sign(objectID string, action string, expiration time.Duration) (string, error) {
+func (c *s3CompatibleClient) Tags(key string, optFns ...func(*TagOptions)) (map[string]string, error) {
+	return c.TagsWithContext(context.Background(), key, optFns...)
+}
+
+func (c *s3CompatibleClient) TagsWithContext(ctx context.Context, key string, optFns ...func(*TagOptions)) (map[string]string, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	tags, err := c.awsS3BlobstoreClient.Tags(ctx, key, optFns...)
+	return tags, classifyError(err)
+}
+
+func (c *s3CompatibleClient) Tag(key string, tags map[string]string, optFns ...func(*TagOptions)) error {
+	return c.TagWithContext(context.Background(), key, tags, optFns...)
+}
+
+func (c *s3CompatibleClient) TagWithContext(ctx context.Context, key string, tags map[string]string, optFns ...func(*TagOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Tag(ctx, key, tags, optFns...))
+}
+
+func (c *s3CompatibleClient) Untag(key string, names []string, optFns ...func(*TagOptions)) error {
+	return c.UntagWithContext(context.Background(), key, names, optFns...)
+}
+
+func (c *s3CompatibleClient) UntagWithContext(ctx context.Context, key string, names []string, optFns ...func(*TagOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Untag(ctx, key, names, optFns...))
+}
+
+func (c *s3CompatibleClient) Sign(objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error) {
+	return c.SignWithContext(context.Background(), objectID, action, expiration, optFns...)
+}
+
+func (c *s3CompatibleClient) SignWithContext(ctx context.Context, objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error) {
 	if c.s3cliConfig.SwiftAuthAccount != "" {
-		return c.openstackSwiftBlobstore.Sign(objectID, action, expiration)
+		return c.openstackSwiftBlobstore.Sign(objectID, action, expiration, optFns...)
+	}
+
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	signedURL, err := c.awsS3BlobstoreClient.Sign(ctx, objectID, action, expiration, optFns...)
+	return signedURL, classifyError(err)
+}
+
+func (c *s3CompatibleClient) List(prefix string, fn func(ListEntry) error, optFns ...func(*ListOptions)) error {
+	return c.ListWithContext(context.Background(), prefix, fn, optFns...)
+}
+
+func (c *s3CompatibleClient) ListWithContext(ctx context.Context, prefix string, fn func(ListEntry) error, optFns ...func(*ListOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.List(ctx, prefix, fn, optFns...))
+}
+
+func (c *s3CompatibleClient) ListVersions(key string, fn func(ObjectVersion) error) error {
+	return c.ListVersionsWithContext(context.Background(), key, fn)
+}
+
+func (c *s3CompatibleClient) ListVersionsWithContext(ctx context.Context, key string, fn func(ObjectVersion) error) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.ListVersions(ctx, key, fn))
+}
+
+func (c *s3CompatibleClient) Copy(src string, dest string, optFns ...func(*CopyOptions)) error {
+	return c.CopyWithContext(context.Background(), src, dest, optFns...)
+}
+
+func (c *s3CompatibleClient) CopyWithContext(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Copy(ctx, src, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) Move(src string, dest string, optFns ...func(*CopyOptions)) error {
+	return c.MoveWithContext(context.Background(), src, dest, optFns...)
+}
+
+func (c *s3CompatibleClient) MoveWithContext(ctx context.Context, src string, dest string, optFns ...func(*CopyOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Move(ctx, src, dest, optFns...))
+}
+
+func (c *s3CompatibleClient) SyncUpload(localDir string, prefix string, optFns ...func(*SyncOptions)) error {
+	return c.SyncUploadWithContext(context.Background(), localDir, prefix, optFns...)
+}
+
+func (c *s3CompatibleClient) SyncUploadWithContext(ctx context.Context, localDir string, prefix string, optFns ...func(*SyncOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.SyncUpload(ctx, localDir, prefix, optFns...))
+}
+
+func (c *s3CompatibleClient) SyncDownload(prefix string, localDir string, optFns ...func(*SyncOptions)) error {
+	return c.SyncDownloadWithContext(context.Background(), prefix, localDir, optFns...)
+}
+
+func (c *s3CompatibleClient) SyncDownloadWithContext(ctx context.Context, prefix string, localDir string, optFns ...func(*SyncOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.SyncDownload(ctx, prefix, localDir, optFns...))
+}
+
+func (c *s3CompatibleClient) Restore(key string, optFns ...func(*RestoreOptions)) error {
+	return c.RestoreWithContext(context.Background(), key, optFns...)
+}
+
+func (c *s3CompatibleClient) RestoreWithContext(ctx context.Context, key string, optFns ...func(*RestoreOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.Restore(ctx, key, optFns...))
+}
+
+func (c *s3CompatibleClient) RestoreStatus(key string, optFns ...func(*RestoreOptions)) (RestoreStatus, error) {
+	return c.RestoreStatusWithContext(context.Background(), key, optFns...)
+}
+
+func (c *s3CompatibleClient) RestoreStatusWithContext(ctx context.Context, key string, optFns ...func(*RestoreOptions)) (RestoreStatus, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	status, err := c.awsS3BlobstoreClient.RestoreStatus(ctx, key, optFns...)
+	return status, classifyError(err)
+}
+
+func (c *s3CompatibleClient) Retention(key string, optFns ...func(*ObjectLockOptions)) (Retention, error) {
+	return c.RetentionWithContext(context.Background(), key, optFns...)
+}
+
+func (c *s3CompatibleClient) RetentionWithContext(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (Retention, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	retention, err := c.awsS3BlobstoreClient.Retention(ctx, key, optFns...)
+	return retention, classifyError(err)
+}
+
+func (c *s3CompatibleClient) SetRetention(key string, retention Retention, optFns ...func(*ObjectLockOptions)) error {
+	return c.SetRetentionWithContext(context.Background(), key, retention, optFns...)
+}
+
+func (c *s3CompatibleClient) SetRetentionWithContext(ctx context.Context, key string, retention Retention, optFns ...func(*ObjectLockOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.SetRetention(ctx, key, retention, optFns...))
+}
+
+func (c *s3CompatibleClient) LegalHold(key string, optFns ...func(*ObjectLockOptions)) (bool, error) {
+	return c.LegalHoldWithContext(context.Background(), key, optFns...)
+}
+
+func (c *s3CompatibleClient) LegalHoldWithContext(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (bool, error) {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	on, err := c.awsS3BlobstoreClient.LegalHold(ctx, key, optFns...)
+	return on, classifyError(err)
+}
+
+func (c *s3CompatibleClient) SetLegalHold(key string, on bool, optFns ...func(*ObjectLockOptions)) error {
+	return c.SetLegalHoldWithContext(context.Background(), key, on, optFns...)
+}
+
+func (c *s3CompatibleClient) SetLegalHoldWithContext(ctx context.Context, key string, on bool, optFns ...func(*ObjectLockOptions)) error {
+	ctx, cancel := c.operationContext(ctx)
+	defer cancel()
+
+	return classifyError(c.awsS3BlobstoreClient.SetLegalHold(ctx, key, on, optFns...))
+}
+
+// operationContext limits ctx to operation_timeout_seconds, if set
+func (c *s3CompatibleClient) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.s3cliConfig.OperationTimeoutSeconds > 0 {
+		return context.WithTimeout(ctx, time.Duration(c.s3cliConfig.OperationTimeoutSeconds)*time.Second)
 	}
 
-	return c.awsS3BlobstoreClient.Sign(objectID, action, expiration)
+	return context.WithCancel(ctx)
 }
@@ -3,6 +3,13 @@ package client_test
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -11,3 +18,38 @@ func TestClient(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Client Suite")
 }
+
+// newTestS3Client returns an S3 client sending path-style requests to the
+// httptest server at serverURL. optFns tune its aws.Config, e.g. the retries.
+func newTestS3Client(serverURL string, optFns ...func(*aws.Config)) *s3.Client {
+	awsConfig := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "key", ""),
+	}
+	for _, optFn := range optFns {
+		optFn(&awsConfig)
+	}
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(serverURL)
+		o.UsePathStyle = true
+	})
+}
+
+// newTestConfig returns the configuration of the specs, for objects below
+// some-folder of some-bucket, which they change before creating the client
+func newTestConfig() *config.S3Cli {
+	return &config.S3Cli{
+		AccessKeyID:     "id",
+		SecretAccessKey: "key",
+		BucketName:      "some-bucket",
+		FolderName:      "some-folder",
+		LogLevel:        config.LogLevelError,
+	}
+}
+
+// newTestClient returns a client of s3Config sending its requests to the
+// httptest server at serverURL
+func newTestClient(serverURL string, s3Config *config.S3Cli) client.S3CompatibleClient {
+	return client.New(newTestS3Client(serverURL), s3Config)
+}
@@ -1,6 +1,19 @@
 package client_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -153,4 +166,433 @@ var _ = Describe("S3CompatibleClient", func() {
 			})
 		})
 	})
+
+	Describe("Copy() and Move()", func() {
+		var server *httptest.Server
+		var requests []string
+		var objectSize int64
+		var requestsMutex sync.Mutex
+
+		BeforeEach(func() {
+			requests = []string{}
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsMutex.Lock()
+				defer requestsMutex.Unlock()
+
+				query := r.URL.Query()
+				switch {
+				case r.Method == http.MethodHead:
+					requests = append(requests, "HeadObject "+r.URL.Path)
+					w.Header().Set("Content-Length", fmt.Sprint(objectSize))
+					w.Header().Set("Content-Type", "application/x-gzip")
+					w.Header().Set("X-Amz-Meta-Sha1", "some-sha")
+				case r.Method == http.MethodPost && query.Has("uploads"):
+					requests = append(requests, fmt.Sprintf("CreateMultipartUpload %s %s %s",
+						r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Amz-Meta-Sha1")))
+					fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`) //nolint:errcheck
+				case r.Method == http.MethodPut && query.Has("partNumber"):
+					requests = append(requests, fmt.Sprintf("UploadPartCopy %s %s",
+						query.Get("partNumber"), r.Header.Get("X-Amz-Copy-Source-Range")))
+					fmt.Fprintf(w, `<CopyPartResult><ETag>"etag-%s"</ETag></CopyPartResult>`, query.Get("partNumber")) //nolint:errcheck
+				case r.Method == http.MethodPut:
+					requests = append(requests, fmt.Sprintf("CopyObject %s %s %s",
+						r.URL.Path, r.Header.Get("X-Amz-Copy-Source"), r.Header.Get("X-Amz-Server-Side-Encryption")))
+					fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`) //nolint:errcheck
+				case r.Method == http.MethodPost && query.Has("uploadId"):
+					body, _ := io.ReadAll(r.Body) //nolint:errcheck
+					requests = append(requests, fmt.Sprintf("CompleteMultipartUpload %s %d",
+						r.URL.Path, strings.Count(string(body), "<Part>")))
+					fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`) //nolint:errcheck
+				case r.Method == http.MethodDelete:
+					requests = append(requests, "DeleteObject "+r.URL.Path)
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotImplemented)
+				}
+			}))
+
+			s3Config = &config.S3Cli{
+				AccessKeyID:          "id",
+				SecretAccessKey:      "key",
+				BucketName:           "some-bucket",
+				FolderName:           "some-folder",
+				ServerSideEncryption: "AES256",
+				UploadConcurrency:    1,
+			}
+			s3Client := s3.NewFromConfig(aws.Config{
+				Region:      "us-east-1",
+				Credentials: credentials.NewStaticCredentialsProvider("id", "key", ""),
+			}, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(server.URL)
+				o.UsePathStyle = true
+			})
+
+			blobstoreClient = client.New(s3Client, s3Config)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		Context("when the object is at most 5 GB", func() {
+			BeforeEach(func() {
+				objectSize = 1024
+			})
+
+			It("copies the object with a single CopyObject call", func() {
+				err := blobstoreClient.Copy("some dir/source", "target")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requests).To(Equal([]string{
+					"HeadObject /some-bucket/some-folder/some dir/source",
+					"CopyObject /some-bucket/some-folder/target some-bucket/some-folder/some%20dir/source AES256",
+				}))
+			})
+
+			It("copies into the destination bucket", func() {
+				err := blobstoreClient.Copy("source", "target", func(o *client.CopyOptions) {
+					o.DestinationBucket = "other-bucket"
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requests).To(ContainElement(
+					"CopyObject /other-bucket/some-folder/target some-bucket/some-folder/source AES256",
+				))
+			})
+
+			It("reports the copy", func() {
+				var result client.ObjectInfo
+				err := blobstoreClient.Copy("source", "target", func(o *client.CopyOptions) {
+					o.DestinationBucket = "other-bucket"
+					o.Result = &result
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result).To(Equal(client.ObjectInfo{Key: "target", Bucket: "other-bucket", Size: 1024, ETag: "etag"}))
+			})
+
+			It("removes the source after moving it", func() {
+				err := blobstoreClient.Move("source", "target")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requests).To(Equal([]string{
+					"HeadObject /some-bucket/some-folder/source",
+					"CopyObject /some-bucket/some-folder/target some-bucket/some-folder/source AES256",
+					"DeleteObject /some-bucket/some-folder/source",
+				}))
+			})
+
+			It("refuses to copy an object onto itself", func() {
+				err := blobstoreClient.Copy("source", "source")
+				Expect(err).To(MatchError("cannot copy 'source' onto itself"))
+				Expect(requests).To(BeEmpty())
+			})
+		})
+
+		Context("when the object is larger than 5 GB", func() {
+			BeforeEach(func() {
+				objectSize = 6 * 1024 * 1024 * 1024
+			})
+
+			It("copies the object part by part and keeps its metadata", func() {
+				err := blobstoreClient.Copy("source", "target")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requests).To(HaveLen(15))
+				Expect(requests[1]).To(Equal("CreateMultipartUpload /some-bucket/some-folder/target application/x-gzip some-sha"))
+				Expect(requests[2]).To(Equal("UploadPartCopy 1 bytes=0-536870911"))
+				Expect(requests[13]).To(Equal("UploadPartCopy 12 bytes=5905580032-6442450943"))
+				Expect(requests[14]).To(Equal("CompleteMultipartUpload /some-bucket/some-folder/target 12"))
+			})
+		})
+	})
+
+	Describe("GetStream()", func() {
+		var server *httptest.Server
+		var content []byte
+
+		BeforeEach(func() {
+			content = make([]byte, 1024*1024+17)
+			for i := range content {
+				content[i] = byte(i % 251)
+			}
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/broken") && r.Header.Get("Range") != "bytes=0-262143" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Header().Set("ETag", `"some-etag"`)
+				http.ServeContent(w, r, "blob", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(content))
+			}))
+
+			s3Config = &config.S3Cli{
+				BucketName:          "some-bucket",
+				DownloadConcurrency: 3,
+				DownloadPartSize:    256 * 1024,
+			}
+			s3Client := s3.NewFromConfig(aws.Config{
+				Region:      "us-east-1",
+				Credentials: aws.AnonymousCredentials{},
+			}, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(server.URL)
+				o.UsePathStyle = true
+			})
+
+			blobstoreClient = client.New(s3Client, s3Config)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("writes the parts to the writer in order", func() {
+			content = content[:512*1024+17]
+
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("blob", &out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.Bytes()).To(Equal(content))
+		})
+
+		It("returns the error of a failed part instead of waiting for it", func() {
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("broken", &out)
+			Expect(err).To(HaveOccurred())
+			Expect(out.Len()).To(Equal(256 * 1024))
+		})
+
+		It("fetches only the requested range", func() {
+			var info client.ObjectInfo
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("blob", &out, func(o *client.GetOptions) {
+				o.Range = "bytes=300000-300099"
+				o.Result = &info
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.Bytes()).To(Equal(content[300000:300100]))
+			Expect(info.Size).To(Equal(int64(len(content))))
+			Expect(info.ContentRange).To(Equal(fmt.Sprintf("bytes 300000-300099/%d", len(content))))
+		})
+
+		It("only reports a Content-Range for ranged downloads", func() {
+			var info client.ObjectInfo
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("blob", &out, func(o *client.GetOptions) {
+				o.Result = &info
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ContentRange).To(BeEmpty())
+		})
+
+		It("skips the download of an unchanged blob", func() {
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("blob", &out, func(o *client.GetOptions) {
+				o.IfNoneMatch = "some-etag"
+			})
+			Expect(err).To(MatchError(client.ErrNotModified))
+			Expect(out.Len()).To(BeZero())
+
+			err = blobstoreClient.GetStream("blob", &out, func(o *client.GetOptions) {
+				o.IfModifiedSince = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			})
+			Expect(err).To(MatchError(client.ErrNotModified))
+			Expect(out.Len()).To(BeZero())
+		})
+
+		It("downloads a changed blob", func() {
+			var out bytes.Buffer
+			err := blobstoreClient.GetStream("blob", &out, func(o *client.GetOptions) {
+				o.IfNoneMatch = "other-etag"
+				o.IfModifiedSince = time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.Bytes()).To(Equal(content))
+		})
+
+		It("leaves the destination file of an unchanged blob alone", func() {
+			destPath := filepath.Join(GinkgoT().TempDir(), "blob")
+			Expect(os.WriteFile(destPath, []byte("cached"), 0644)).To(Succeed())
+
+			err := blobstoreClient.GetFile("blob", destPath, func(o *client.GetOptions) {
+				o.IfNoneMatch = "some-etag"
+			})
+			Expect(err).To(MatchError(client.ErrNotModified))
+			Expect(os.ReadFile(destPath)).To(Equal([]byte("cached")))
+			Expect(filepath.Glob(filepath.Join(filepath.Dir(destPath), ".*"))).To(BeEmpty())
+		})
+	})
+
+	Describe("retry policy", func() {
+		var server *httptest.Server
+		var failures []int
+		var delay, bodyDelay time.Duration
+		var attempts atomic.Int32
+
+		BeforeEach(func() {
+			failures = nil
+			delay, bodyDelay = 0, 0
+			attempts.Store(0)
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := int(attempts.Add(1))
+				if attempt == 1 && delay > 0 {
+					time.Sleep(delay)
+				}
+				if attempt <= len(failures) {
+					w.WriteHeader(failures[attempt-1])
+					return
+				}
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+
+				w.Header().Set("Content-Length", "12")
+				w.Header().Set("Content-Range", "bytes 0-11/12")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte("some ")) //nolint:errcheck
+				w.(http.Flusher).Flush()
+				time.Sleep(bodyDelay)
+				w.Write([]byte("content")) //nolint:errcheck
+			}))
+
+			serverURL, err := url.Parse(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			port, err := strconv.Atoi(serverURL.Port())
+			Expect(err).NotTo(HaveOccurred())
+
+			s3Config = &config.S3Cli{
+				AccessKeyID:       "id",
+				SecretAccessKey:   "key",
+				CredentialsSource: config.StaticCredentialsSource,
+				BucketName:        "some-bucket",
+				Host:              serverURL.Hostname(),
+				Port:              port,
+				Region:            "us-east-1",
+				RetryBaseBackoff:  1,
+				RetryMaxBackoff:   10,
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newClient := func() client.S3CompatibleClient {
+			s3Client, err := client.NewAwsS3Client(s3Config)
+			Expect(err).NotTo(HaveOccurred())
+			return client.New(s3Client, s3Config)
+		}
+
+		It("retries server errors up to retry_max_attempts", func() {
+			failures = []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusServiceUnavailable}
+			s3Config.RetryMaxAttempts = 4
+
+			exists, err := newClient().Exists("blob")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(attempts.Load()).To(Equal(int32(4)))
+		})
+
+		It("gives up after retry_max_attempts", func() {
+			failures = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+			s3Config.RetryMaxAttempts = 2
+
+			_, err := newClient().Exists("blob")
+			Expect(err).To(HaveOccurred())
+			Expect(attempts.Load()).To(Equal(int32(2)))
+		})
+
+		It("only retries the configured status codes", func() {
+			failures = []int{http.StatusTooManyRequests, http.StatusInternalServerError}
+			s3Config.RetryStatusCodes = []int{http.StatusTooManyRequests}
+
+			_, err := newClient().Exists("blob")
+			Expect(err).To(HaveOccurred())
+			Expect(attempts.Load()).To(Equal(int32(2)))
+		})
+
+		It("retries attempts exceeding attempt_timeout_seconds", func() {
+			delay = 1500 * time.Millisecond
+			s3Config.AttemptTimeoutSeconds = 1
+
+			exists, err := newClient().Exists("blob")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(attempts.Load()).To(Equal(int32(2)))
+		})
+
+		It("does not limit the download of a response body by attempt_timeout_seconds", func() {
+			bodyDelay = 1500 * time.Millisecond
+			s3Config.AttemptTimeoutSeconds = 1
+
+			var content bytes.Buffer
+			Expect(newClient().GetStream("blob", &content)).To(Succeed())
+			Expect(content.String()).To(Equal("some content"))
+			Expect(attempts.Load()).To(Equal(int32(1)))
+		})
+	})
+
+	Describe("cancellation", func() {
+		var server *httptest.Server
+		var aborted chan string
+
+		BeforeEach(func() {
+			aborted = make(chan string, 1)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				switch {
+				case r.Method == http.MethodPost && query.Has("uploads"):
+					fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`) //nolint:errcheck
+				case r.Method == http.MethodDelete && query.Has("uploadId"):
+					aborted <- query.Get("uploadId")
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					// Parts and HEAD requests hang until the client gives up
+					io.Copy(io.Discard, r.Body) //nolint:errcheck
+					<-r.Context().Done()
+				}
+			}))
+
+			s3Config = &config.S3Cli{
+				AccessKeyID:     "id",
+				SecretAccessKey: "key",
+				BucketName:      "some-bucket",
+				MultipartUpload: true,
+				UploadPartSize:  5 * 1024 * 1024,
+			}
+			s3Client := s3.NewFromConfig(aws.Config{
+				Region:      "us-east-1",
+				Credentials: credentials.NewStaticCredentialsProvider("id", "key", ""),
+			}, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(server.URL)
+				o.UsePathStyle = true
+			})
+
+			blobstoreClient = client.New(s3Client, s3Config)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("aborts the multipart upload once the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+
+			err := blobstoreClient.PutWithContext(ctx, bytes.NewReader(make([]byte, 11*1024*1024)), "blob")
+			Expect(err).To(MatchError(ContainSubstring("context canceled")))
+			Eventually(aborted).Should(Receive(Equal("upload-id")))
+		})
+
+		It("stops operations exceeding operation_timeout_seconds", func() {
+			s3Config.OperationTimeoutSeconds = 1
+
+			start := time.Now()
+			_, err := blobstoreClient.Exists("blob")
+			Expect(err).To(MatchError(ContainSubstring("deadline exceeded")))
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		})
+	})
 })
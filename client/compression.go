@@ -0,0 +1,385 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// Metadata of compressed objects, stored as x-amz-meta-<name>. The size and
+// SHA256 are those of the content before it was compressed.
+const (
+	metadataCompression    = "s3cli-compression"
+	metadataOriginalSize   = "s3cli-original-size"
+	metadataOriginalSHA256 = "s3cli-original-sha256"
+)
+
+// compressUpload compresses src on its way to the upload and records the
+// compression in the metadata of the upload, along with the size and SHA256
+// of the original content. Both have to be known before the upload starts:
+// a seekable src is read for them once and compressed while it is uploaded,
+// a stream is compressed into a temporary file which the upload reads
+// instead. done stops the compression and removes the file.
+func compressUpload(src io.Reader, algorithm string, headers *objectHeaders) (compressed io.Reader, done func(), err error) {
+	seeker, ok := src.(io.ReadSeeker)
+	if !ok {
+		return spoolCompressed(src, algorithm, headers)
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, err
+	}
+	digest := sha256.New()
+	size, err := io.Copy(digest, seeker)
+	if err == nil {
+		_, err = seeker.Seek(start, io.SeekStart)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if compressed, done, err = newCompressingReader(seeker, algorithm); err != nil {
+		return nil, nil, err
+	}
+	recordCompression(headers, algorithm, size, digest.Sum(nil))
+	return compressed, done, nil
+}
+
+// spoolCompressed compresses a stream into a temporary file, the original
+// size and digest being only known once it was read
+func spoolCompressed(src io.Reader, algorithm string, headers *objectHeaders) (io.Reader, func(), error) {
+	spooled, err := os.CreateTemp("", "s3cli-compressed")
+	if err != nil {
+		return nil, nil, err
+	}
+	remove := func() {
+		spooled.Close()           //nolint:errcheck
+		os.Remove(spooled.Name()) //nolint:errcheck
+	}
+
+	compressor, err := newCompressor(spooled, algorithm)
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	digest := sha256.New()
+	size, err := io.Copy(io.MultiWriter(compressor, digest), src)
+	if closeErr := compressor.Close(); err == nil {
+		err = closeErr
+	}
+	var compressedSize int64
+	if err == nil {
+		compressedSize, err = spooled.Seek(0, io.SeekCurrent)
+	}
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+
+	recordCompression(headers, algorithm, size, digest.Sum(nil))
+	// A section rather than the file itself, which would be taken for a
+	// resumable upload of that file
+	return io.NewSectionReader(spooled, 0, compressedSize), remove, nil
+}
+
+// newCompressingReader compresses src while it is read, a goroutine writing
+// the compressed content into a pipe. stop ends the compression if the
+// upload did not read it to the end.
+func newCompressingReader(src io.Reader, algorithm string) (compressed io.Reader, stop func(), err error) {
+	pipeReader, pipeWriter := io.Pipe()
+	compressor, err := newCompressor(pipeWriter, algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, err := io.Copy(compressor, src)
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+		pipeWriter.CloseWithError(err) //nolint:errcheck
+	}()
+
+	stop = func() {
+		pipeReader.Close() //nolint:errcheck
+		<-done
+	}
+	return pipeReader, stop, nil
+}
+
+// recordCompression stores the algorithm and the size and SHA256 of the
+// original content in the metadata of the upload
+func recordCompression(headers *objectHeaders, algorithm string, size int64, digest []byte) {
+	if headers.metadata == nil {
+		headers.metadata = map[string]string{}
+	}
+	maps.Copy(headers.metadata, map[string]string{
+		metadataCompression:    algorithm,
+		metadataOriginalSize:   strconv.FormatInt(size, 10),
+		metadataOriginalSHA256: hex.EncodeToString(digest),
+	})
+}
+
+func newCompressor(w io.Writer, algorithm string) (io.WriteCloser, error) {
+	switch algorithm {
+	case config.CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case config.CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'", algorithm)
+	}
+}
+
+func newDecompressor(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case config.CompressionGzip:
+		return gzip.NewReader(r)
+	case config.CompressionZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'", algorithm)
+	}
+}
+
+// originalSize is the size of a compressed object before compression, -1 if
+// it was not recorded
+func originalSize(metadata map[string]string) int64 {
+	size, err := strconv.ParseInt(metadata[metadataOriginalSize], 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
+
+// decompressingWriter decompresses the content written to it into dest. A
+// goroutine reads the decompressed content from a pipe.
+type decompressingWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func newDecompressingWriter(dest io.Writer, algorithm string) *decompressingWriter {
+	pipeReader, pipeWriter := io.Pipe()
+	d := &decompressingWriter{pipe: pipeWriter, done: make(chan error, 1)}
+
+	go func() {
+		decompressor, err := newDecompressor(pipeReader, algorithm)
+		if err == nil {
+			_, err = io.Copy(dest, decompressor)
+			if closeErr := decompressor.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("decompressing %s blob: %w", algorithm, err)
+		}
+		// Unblocks the writer if decompression stopped early
+		pipeReader.CloseWithError(err) //nolint:errcheck
+		d.done <- err
+	}()
+
+	return d
+}
+
+func (d *decompressingWriter) Write(p []byte) (int, error) {
+	return d.pipe.Write(p)
+}
+
+// Close ends the compressed content, with downloadErr if the download
+// failed, and waits for the decompression to finish
+func (d *decompressingWriter) Close(downloadErr error) error {
+	d.pipe.CloseWithError(downloadErr) //nolint:errcheck
+	err := <-d.done
+	if downloadErr != nil {
+		return downloadErr
+	}
+
+	return err
+}
+
+// rangeWriter passes on the bytes of a range of the content written to it
+// and drops the others
+type rangeWriter struct {
+	w         io.Writer
+	skip      int64
+	remaining int64
+}
+
+func (r *rangeWriter) Write(p []byte) (int, error) {
+	written := len(p)
+
+	skipped := min(r.skip, int64(len(p)))
+	r.skip -= skipped
+	p = p[skipped:]
+	p = p[:min(r.remaining, int64(len(p)))]
+	r.remaining -= int64(len(p))
+
+	if _, err := r.w.Write(p); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// compressedBlobError stops the download of a compressed object as it is
+// stored, which is then downloaded through a decompressor. It carries the
+// details of the object the first response described.
+type compressedBlobError struct {
+	algorithm string
+	etag      *string
+	versionID *string
+	metadata  map[string]string
+}
+
+func (e *compressedBlobError) Error() string {
+	return fmt.Sprintf("blob is %s compressed", e.algorithm)
+}
+
+// compressionGuard stops downloads of compressed objects with a
+// compressedBlobError before anything was written
+type compressionGuard struct {
+	manager.DownloadAPIClient //nolint:staticcheck
+}
+
+func (g *compressionGuard) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	resp, err := g.DownloadAPIClient.GetObject(ctx, params, optFns...)
+	if err == nil && resp.Metadata[metadataCompression] != "" {
+		resp.Body.Close() //nolint:errcheck
+		return nil, &compressedBlobError{
+			algorithm: resp.Metadata[metadataCompression],
+			etag:      resp.ETag,
+			versionID: resp.VersionId,
+			metadata:  resp.Metadata,
+		}
+	}
+
+	return resp, err
+}
+
+// headCompressed returns a compressedBlobError for a ranged download of a
+// compressed object before it starts. The range refers to the original
+// content, which the object as stored may not even cover.
+func (b *awsS3Client) headCompressed(ctx context.Context, src string, opts GetOptions) error {
+	if opts.Range == "" || opts.Raw {
+		return nil
+	}
+
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.VersionID),
+		IfNoneMatch:          ifNoneMatchParam(opts.IfNoneMatch),
+		IfModifiedSince:      ifModifiedSinceParam(opts.IfModifiedSince),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		return err
+	}
+	if algorithm := head.Metadata[metadataCompression]; algorithm != "" {
+		return &compressedBlobError{algorithm: algorithm, etag: head.ETag, versionID: head.VersionId, metadata: head.Metadata}
+	}
+
+	return nil
+}
+
+// originalContent prepares the download of a compressed object: dest is
+// wrapped to receive the requested range of the decompressed content, and
+// result is filled from the original size. The verifier, if any, checks the
+// expected digests and the recorded SHA256 of the whole content once the
+// decompressor was closed.
+func originalContent(dest io.Writer, algorithm string, metadata map[string]string, opts GetOptions) (*decompressingWriter, *digestVerifier, error) {
+	size := originalSize(metadata)
+	digests := opts.Digests
+	contentRange := ""
+	if opts.Range != "" {
+		if size < 0 {
+			return nil, nil, errors.New("blob does not record its original size, a range of it can only be read raw")
+		}
+		first, last, err := contentRangeBounds(opts.Range, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		dest = &rangeWriter{w: dest, skip: first, remaining: last - first + 1}
+		contentRange = fmt.Sprintf("bytes %d-%d/%d", first, last, size)
+	} else if recorded, err := NewDigest("sha256", metadata[metadataOriginalSHA256]); err == nil {
+		digests = append(slices.Clip(digests), recorded)
+	}
+
+	var verifier *digestVerifier
+	if len(digests) > 0 {
+		verifier = newDigestVerifier(digests)
+		dest = io.MultiWriter(dest, verifier)
+	}
+	if opts.Result != nil {
+		opts.Result.Size = size
+		opts.Result.ContentRange = contentRange
+	}
+	return newDecompressingWriter(dest, algorithm), verifier, nil
+}
+
+// decompressStopped continues a download which err stopped at a compressed
+// object by downloading its original content into dest. Other errors are
+// returned as they are.
+func (b *awsS3Client) decompressStopped(ctx context.Context, src string, dest io.Writer, opts GetOptions, err error) error {
+	var blob *compressedBlobError
+	if !errors.As(err, &blob) {
+		return err
+	}
+
+	decompressor, verifier, err := originalContent(dest, blob.algorithm, blob.metadata, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Result != nil {
+		opts.Result.ETag = strings.Trim(aws.ToString(blob.etag), `"`)
+		opts.Result.VersionID = aws.ToString(blob.versionID)
+	}
+
+	// The version and ETag pin the object the first response described
+	sse := b.sseCustomerKey()
+	getParams := &s3.GetObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            blob.versionID,
+		IfMatch:              blob.etag,
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	if err = decompressor.Close(b.getStream(ctx, getParams, decompressor, nil, false)); err != nil {
+		return err
+	}
+
+	if verifier != nil {
+		return verifier.Verify()
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package client_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	var server *httptest.Server
+	var objects map[string][]byte
+	var metadata map[string]http.Header
+	var objectsMutex sync.Mutex
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+	var content []byte
+	var tmpDir string
+	var tmpFiles []string
+
+	storedPath := "/some-bucket/some-folder/some-key"
+
+	BeforeEach(func() {
+		objects = map[string][]byte{}
+		metadata = map[string]http.Header{}
+		tmpDir, tmpFiles = "", nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			objectsMutex.Lock()
+			defer objectsMutex.Unlock()
+
+			switch r.Method {
+			case http.MethodPut:
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				objects[r.URL.Path] = body
+				if tmpDir != "" {
+					entries, _ := os.ReadDir(tmpDir) //nolint:errcheck
+					for _, entry := range entries {
+						tmpFiles = append(tmpFiles, entry.Name())
+					}
+				}
+				metadata[r.URL.Path] = http.Header{}
+				for name, values := range r.Header {
+					if strings.HasPrefix(name, "X-Amz-Meta-") {
+						metadata[r.URL.Path][name] = values
+					}
+				}
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, len(body)))
+			case http.MethodGet, http.MethodHead:
+				body, ok := objects[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				for name, values := range metadata[r.URL.Path] {
+					w.Header()[name] = values
+				}
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, len(body)))
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config = newTestConfig()
+		s3Config.Compression = config.CompressionGzip
+		blobstoreClient = newTestClient(server.URL, s3Config)
+
+		var lines strings.Builder
+		for i := range 10000 {
+			fmt.Fprintf(&lines, "line %d of the blob\n", i)
+		}
+		content = []byte(lines.String())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("round trips compressed blobs",
+		func(compression string) {
+			s3Config.Compression = compression
+			var putResult client.ObjectInfo
+			Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key", func(o *client.PutOptions) {
+				o.Result = &putResult
+			})).To(Succeed())
+
+			stored := objects[storedPath]
+			Expect(len(stored)).To(BeNumerically("<", len(content)/4))
+			Expect(putResult.Size).To(Equal(int64(len(content))))
+
+			storedMetadata := metadata[storedPath]
+			digest := sha256.Sum256(content)
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Compression")).To(Equal(compression))
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Original-Size")).To(Equal(fmt.Sprint(len(content))))
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Original-Sha256")).To(Equal(hex.EncodeToString(digest[:])))
+
+			var result client.ObjectInfo
+			dest := &bytes.Buffer{}
+			Expect(blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+				o.Result = &result
+			})).To(Succeed())
+			Expect(dest.Bytes()).To(Equal(content))
+			Expect(result.Size).To(Equal(int64(len(content))))
+			Expect(result.ETag).To(Equal(fmt.Sprintf("etag-%d", len(stored))))
+
+			destPath := filepath.Join(GinkgoT().TempDir(), "blob")
+			Expect(blobstoreClient.GetFile("some-key", destPath)).To(Succeed())
+			downloaded, err := os.ReadFile(destPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(downloaded).To(Equal(content))
+		},
+		Entry("with gzip", config.CompressionGzip),
+		Entry("with zstd", config.CompressionZstd),
+	)
+
+	Describe("temporary files", func() {
+		var keyDir string
+
+		BeforeEach(func() {
+			keyDir = GinkgoT().TempDir()
+			tmpDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("TMPDIR", tmpDir)
+		})
+
+		It("compresses a seekable source while it is uploaded", func() {
+			s3Config.MultipartUpload = true
+
+			Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+			Expect(tmpFiles).To(BeEmpty())
+
+			dest := &bytes.Buffer{}
+			Expect(blobstoreClient.GetStream("some-key", dest)).To(Succeed())
+			Expect(dest.Bytes()).To(Equal(content))
+		})
+
+		It("spools a stream once, also when it is encrypted for a single part upload", func() {
+			key := make([]byte, 32)
+			_, err := rand.Read(key)
+			Expect(err).ToNot(HaveOccurred())
+			keyFile := filepath.Join(keyDir, "key")
+			Expect(os.WriteFile(keyFile, key, 0600)).To(Succeed())
+			s3Config.ClientSideEncryptionKeyFile = keyFile
+
+			stream := io.MultiReader(bytes.NewReader(content))
+			Expect(blobstoreClient.Put(stream, "some-key")).To(Succeed())
+			Expect(tmpFiles).To(ConsistOf(HavePrefix("s3cli-compressed")))
+			Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+
+			dest := &bytes.Buffer{}
+			Expect(blobstoreClient.GetStream("some-key", dest)).To(Succeed())
+			Expect(dest.Bytes()).To(Equal(content))
+		})
+	})
+
+	It("fetches the blob as stored with raw", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+
+		var result client.ObjectInfo
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+			o.Raw = true
+			o.Result = &result
+		})).To(Succeed())
+		Expect(dest.Bytes()).To(Equal(objects[storedPath]))
+		Expect(result.Size).To(Equal(int64(len(objects[storedPath]))))
+
+		decompressor, err := gzip.NewReader(dest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(io.ReadAll(decompressor)).To(Equal(content))
+	})
+
+	It("cuts ranges from the decompressed content", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+
+		var result client.ObjectInfo
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+			o.Range = "bytes=100000-100099"
+			o.Result = &result
+		})).To(Succeed())
+		Expect(dest.Bytes()).To(Equal(content[100000:100100]))
+		Expect(result.ContentRange).To(Equal(fmt.Sprintf("bytes 100000-100099/%d", len(content))))
+	})
+
+	It("reports both sizes on stat", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+
+		stat, err := blobstoreClient.Stat("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Compression).To(Equal(config.CompressionGzip))
+		Expect(stat.Size).To(Equal(int64(len(objects[storedPath]))))
+		Expect(stat.OriginalSize).To(Equal(int64(len(content))))
+	})
+
+	It("verifies the decompressed content against the recorded digest", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+		metadata[storedPath].Set("X-Amz-Meta-S3cli-Original-Sha256", strings.Repeat("0", 64))
+
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue())
+	})
+
+	It("refuses to resume the download of a compressed blob", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+
+		err := blobstoreClient.GetFile("some-key", filepath.Join(GinkgoT().TempDir(), "blob"), func(o *client.GetOptions) {
+			o.Resume = true
+		})
+		Expect(err).To(MatchError(ContainSubstring("can only be resumed raw")))
+	})
+
+	It("downloads blobs stored uncompressed", func() {
+		objects["/some-bucket/some-folder/plain-key"] = []byte("plain content")
+
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("plain-key", dest)).To(Succeed())
+		Expect(dest.String()).To(Equal("plain content"))
+	})
+
+	It("compresses before encrypting", func() {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		Expect(err).ToNot(HaveOccurred())
+		keyFile := filepath.Join(GinkgoT().TempDir(), "key")
+		Expect(os.WriteFile(keyFile, key, 0600)).To(Succeed())
+		s3Config.Compression = config.CompressionZstd
+		s3Config.ClientSideEncryptionKeyFile = keyFile
+
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+		Expect(len(objects[storedPath])).To(BeNumerically("<", len(content)/4))
+
+		var result client.ObjectInfo
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+			o.Range = "bytes=-50"
+			o.Result = &result
+		})).To(Succeed())
+		Expect(dest.Bytes()).To(Equal(content[len(content)-50:]))
+		Expect(result.ContentRange).To(Equal(fmt.Sprintf("bytes %d-%d/%d", len(content)-50, len(content)-1, len(content))))
+	})
+})
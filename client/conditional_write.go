@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// writeConditions are the If-None-Match and If-Match headers of an upload,
+// sent with PutObject or CompleteMultipartUpload
+type writeConditions struct {
+	ifNoneMatch *string
+	ifMatch     *string
+}
+
+// writeConditions returns the conditions of an upload to dest. Providers
+// ignoring them get a HeadObject check instead, which leaves a window in
+// which another writer can still create or change the object.
+func (b *awsS3Client) writeConditions(ctx context.Context, dest string, opts PutOptions) (writeConditions, error) {
+	noOverwrite := opts.NoOverwrite || b.s3cliConfig.IfNoneMatch
+	if noOverwrite && opts.IfMatch != "" {
+		return writeConditions{}, &Error{Kind: ErrInvalidArgument, Err: errors.New("refusing to overwrite cannot be combined with an expected ETag")}
+	}
+	if !noOverwrite && opts.IfMatch == "" {
+		return writeConditions{}, nil
+	}
+
+	expectedETag := strings.Trim(opts.IfMatch, `"`)
+	if b.s3cliConfig.SupportsConditionalWrites() {
+		if noOverwrite {
+			return writeConditions{ifNoneMatch: aws.String("*")}, nil
+		}
+		return writeConditions{ifMatch: aws.String(`"` + expectedETag + `"`)}, nil
+	}
+
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(dest),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	switch {
+	case err != nil && !errors.Is(errorKind(err), ErrNotFound):
+		return writeConditions{}, err
+	case noOverwrite && err == nil:
+		return writeConditions{}, &Error{Kind: ErrPreconditionFailed, Err: fmt.Errorf("blob '%s' already exists", dest)}
+	case noOverwrite:
+		return writeConditions{}, nil
+	case err != nil:
+		return writeConditions{}, &Error{Kind: ErrPreconditionFailed, Err: fmt.Errorf("blob '%s' does not exist", dest)}
+	}
+
+	if etag := strings.Trim(aws.ToString(head.ETag), `"`); etag != expectedETag {
+		return writeConditions{}, &Error{Kind: ErrPreconditionFailed, Err: fmt.Errorf("blob '%s' has the ETag '%s', not '%s'", dest, etag, expectedETag)}
+	}
+
+	return writeConditions{}, nil
+}
@@ -0,0 +1,143 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conditional writes", func() {
+	var server *httptest.Server
+	var requests []string
+	var requestsMutex sync.Mutex
+	var exists bool
+
+	newClient := func(host string, ifNoneMatch bool) client.S3CompatibleClient {
+		s3Config := newTestConfig()
+		s3Config.Host = host
+		s3Config.IfNoneMatch = ifNoneMatch
+		return newTestClient(server.URL, s3Config)
+	}
+
+	BeforeEach(func() {
+		requests = []string{}
+		exists = true
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			switch r.Method {
+			case http.MethodHead:
+				requests = append(requests, "HeadObject "+r.URL.Path)
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("ETag", `"some-etag"`)
+			case http.MethodPut:
+				requests = append(requests, fmt.Sprintf("PutObject %s If-None-Match=%s If-Match=%s", r.URL.Path, r.Header.Get("If-None-Match"), r.Header.Get("If-Match")))
+				if exists && r.Header.Get("If-None-Match") == "*" {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`) //nolint:errcheck
+					return
+				}
+				w.Header().Set("ETag", `"new-etag"`)
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends If-None-Match and classifies the refusal", func() {
+		err := newClient("", false).Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.NoOverwrite = true
+		})
+
+		Expect(errors.Is(err, client.ErrPreconditionFailed)).To(BeTrue(), "%v", err)
+		Expect(requests).To(Equal([]string{"PutObject /some-bucket/some-folder/some-key If-None-Match=* If-Match="}))
+	})
+
+	It("sends If-None-Match when if_none_match is configured", func() {
+		exists = false
+		err := newClient("", true).Put(bytes.NewReader([]byte("content")), "some-key")
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(Equal([]string{"PutObject /some-bucket/some-folder/some-key If-None-Match=* If-Match="}))
+	})
+
+	It("sends If-Match quoted", func() {
+		err := newClient("", false).Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.IfMatch = "some-etag"
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(Equal([]string{`PutObject /some-bucket/some-folder/some-key If-None-Match= If-Match="some-etag"`}))
+	})
+
+	It("refuses to combine both conditions", func() {
+		err := newClient("", false).Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.NoOverwrite = true
+			o.IfMatch = "some-etag"
+		})
+
+		Expect(errors.Is(err, client.ErrInvalidArgument)).To(BeTrue(), "%v", err)
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("refuses an expected ETag when if_none_match is configured", func() {
+		err := newClient("", true).Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.IfMatch = "some-etag"
+		})
+
+		Expect(errors.Is(err, client.ErrInvalidArgument)).To(BeTrue(), "%v", err)
+		Expect(requests).To(BeEmpty())
+	})
+
+	Context("when the provider ignores conditional writes", func() {
+		It("checks for an existing blob first", func() {
+			err := newClient("storage.googleapis.com", false).Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+				o.NoOverwrite = true
+			})
+
+			Expect(errors.Is(err, client.ErrPreconditionFailed)).To(BeTrue(), "%v", err)
+			Expect(requests).To(Equal([]string{"HeadObject /some-bucket/some-folder/some-key"}))
+		})
+
+		It("uploads a missing blob without the header", func() {
+			exists = false
+			err := newClient("storage.googleapis.com", true).Put(bytes.NewReader([]byte("content")), "some-key")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests).To(Equal([]string{
+				"HeadObject /some-bucket/some-folder/some-key",
+				"PutObject /some-bucket/some-folder/some-key If-None-Match= If-Match=",
+			}))
+		})
+
+		It("compares the ETag of the existing blob", func() {
+			blobstoreClient := newClient("storage.googleapis.com", false)
+
+			err := blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+				o.IfMatch = "other-etag"
+			})
+			Expect(errors.Is(err, client.ErrPreconditionFailed)).To(BeTrue(), "%v", err)
+
+			err = blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+				o.IfMatch = `"some-etag"`
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Digest is the expected hex encoded digest of a blob for one algorithm
+type Digest struct {
+	Algorithm string
+	Value     string
+}
+
+func (d Digest) String() string {
+	return d.Algorithm + ":" + d.Value
+}
+
+// DigestMismatchError is returned when the content transferred does not
+// match an expected digest
+type DigestMismatchError struct {
+	Expected Digest
+	Actual   string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("expected %s digest '%s' but content has '%s'", e.Expected.Algorithm, e.Expected.Value, e.Actual)
+}
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ParseDigests parses a BOSH multiple digest such as "sha1:abc;sha256:def".
+// A digest without an algorithm prefix is a SHA1 digest.
+func ParseDigests(multipleDigest string) ([]Digest, error) {
+	var digests []Digest
+	for _, piece := range strings.Split(multipleDigest, ";") {
+		if piece == "" {
+			continue
+		}
+
+		algorithm, value, found := strings.Cut(piece, ":")
+		if !found {
+			algorithm, value = "sha1", piece
+		}
+
+		digest, err := NewDigest(algorithm, value)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+
+	if len(digests) == 0 {
+		return nil, fmt.Errorf("no digest found in '%s'", multipleDigest)
+	}
+
+	return digests, nil
+}
+
+// NewDigest validates a hex encoded digest of one of the algorithms sha1, sha256 or sha512
+func NewDigest(algorithm string, value string) (Digest, error) {
+	newHash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return Digest{}, fmt.Errorf("unsupported digest algorithm '%s', supported are sha1, sha256 and sha512", algorithm)
+	}
+
+	value = strings.ToLower(value)
+	if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != newHash().Size() {
+		return Digest{}, fmt.Errorf("invalid %s digest '%s'", algorithm, value)
+	}
+
+	return Digest{Algorithm: algorithm, Value: value}, nil
+}
+
+// base64Value returns the digest in the encoding of the S3 x-amz-checksum-* headers
+func (d Digest) base64Value() string {
+	decoded, _ := hex.DecodeString(d.Value) //nolint:errcheck
+	return base64.StdEncoding.EncodeToString(decoded)
+}
+
+// digestVerifier hashes everything written to it with the algorithms of the
+// expected digests
+type digestVerifier struct {
+	digests []Digest
+	hashes  []hash.Hash
+	writer  io.Writer
+}
+
+func newDigestVerifier(digests []Digest) *digestVerifier {
+	v := &digestVerifier{digests: digests}
+
+	writers := make([]io.Writer, 0, len(digests))
+	for _, digest := range digests {
+		h := digestAlgorithms[digest.Algorithm]()
+		v.hashes = append(v.hashes, h)
+		writers = append(writers, h)
+	}
+	v.writer = io.MultiWriter(writers...)
+
+	return v
+}
+
+func (v *digestVerifier) Write(p []byte) (int, error) {
+	return v.writer.Write(p)
+}
+
+// Verify compares the content written so far with the expected digests
+func (v *digestVerifier) Verify() error {
+	for i, digest := range v.digests {
+		if actual := hex.EncodeToString(v.hashes[i].Sum(nil)); actual != digest.Value {
+			return &DigestMismatchError{Expected: digest, Actual: actual}
+		}
+	}
+
+	return nil
+}
+
+// verifyingReader verifies the digests once the underlying reader is
+// exhausted, turning the final io.EOF into a DigestMismatchError if they
+// differ. Uploads reading from it fail before the object is committed.
+type verifyingReader struct {
+	r        io.Reader
+	verifier *digestVerifier
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.verifier.Write(p[:n]) //nolint:errcheck
+
+	if err == io.EOF {
+		if verifyErr := r.verifier.Verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
+
+// strongestDigest picks the digest S3 can verify as additional checksum
+func strongestDigest(digests []Digest) (Digest, bool) {
+	for _, algorithm := range []string{"sha256", "sha1"} {
+		for _, digest := range digests {
+			if digest.Algorithm == algorithm {
+				return digest, true
+			}
+		}
+	}
+
+	return Digest{}, false
+}
+
+// verifySeekable reads src to its end to verify the digests and rewinds it.
+// It returns the size of the content.
+func verifySeekable(src io.ReadSeeker, digests []Digest) (int64, error) {
+	verifier := newDigestVerifier(digests)
+
+	size, err := io.Copy(verifier, src)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return size, verifier.Verify()
+}
+
+// digestMetadata stores each digest under its algorithm, e.g. x-amz-meta-sha256
+func digestMetadata(digests []Digest) map[string]string {
+	metadata := map[string]string{}
+	for _, digest := range digests {
+		metadata[digest.Algorithm] = digest.Value
+	}
+
+	return metadata
+}
@@ -0,0 +1,132 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Digests", func() {
+	Describe("ParseDigests()", func() {
+		const sha1 = "07e1306432667f916639d47481edc4f2ca456454"
+		const sha256 = "4b08ba6f4a3bc6ad28fc3efe8b0b8ba8e4c4d5f7f0bd5f1f8eab3ab3b9c6d2a1"
+
+		It("parses a BOSH multiple digest", func() {
+			digests, err := client.ParseDigests("sha1:" + sha1 + ";sha256:" + sha256)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digests).To(Equal([]client.Digest{
+				{Algorithm: "sha1", Value: sha1},
+				{Algorithm: "sha256", Value: sha256},
+			}))
+		})
+
+		It("treats a digest without algorithm as SHA1", func() {
+			digests, err := client.ParseDigests(sha1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digests).To(Equal([]client.Digest{{Algorithm: "sha1", Value: sha1}}))
+		})
+
+		It("rejects unknown algorithms and malformed values", func() {
+			_, err := client.ParseDigests("md5:" + sha1)
+			Expect(err).To(MatchError(ContainSubstring("unsupported digest algorithm 'md5'")))
+
+			_, err = client.ParseDigests("sha256:" + sha1)
+			Expect(err).To(MatchError(ContainSubstring("invalid sha256 digest")))
+
+			_, err = client.ParseDigests(";")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("verification", func() {
+		const content = "some content"
+
+		var server *httptest.Server
+		var requests []string
+		var requestsMutex sync.Mutex
+		var blobstoreClient client.S3CompatibleClient
+
+		BeforeEach(func() {
+			requests = []string{}
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsMutex.Lock()
+				defer requestsMutex.Unlock()
+
+				io.Copy(io.Discard, r.Body) //nolint:errcheck
+				requests = append(requests, r.Method)
+				if r.Method == http.MethodGet {
+					w.Write([]byte(content)) //nolint:errcheck
+				}
+			}))
+
+			s3Client := newTestS3Client(server.URL, func(c *aws.Config) {
+				c.RetryMaxAttempts = 1
+			})
+			blobstoreClient = client.New(s3Client, newTestConfig())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		digestOf := func(content string) client.Digest {
+			sum := sha256.Sum256([]byte(content))
+			return client.Digest{Algorithm: "sha256", Value: hex.EncodeToString(sum[:])}
+		}
+
+		withDigest := func(digest client.Digest) func(*client.PutOptions) {
+			return func(o *client.PutOptions) {
+				o.Digests = []client.Digest{digest}
+			}
+		}
+
+		It("refuses a seekable upload before sending it", func() {
+			err := blobstoreClient.Put(bytes.NewReader([]byte(content)), "some-key", withDigest(digestOf("other content")))
+
+			Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue(), "%v", err)
+			Expect(requests).To(BeEmpty())
+		})
+
+		It("fails a streamed upload once the stream ended", func() {
+			stream := struct{ io.Reader }{strings.NewReader(content)}
+			err := blobstoreClient.Put(stream, "some-key", withDigest(digestOf("other content")))
+
+			Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue(), "%v", err)
+			Expect(requests).ToNot(ContainElement(http.MethodPut))
+		})
+
+		It("uploads content matching the digests", func() {
+			Expect(blobstoreClient.Put(bytes.NewReader([]byte(content)), "some-key", withDigest(digestOf(content)))).To(Succeed())
+			Expect(requests).To(Equal([]string{http.MethodPut}))
+		})
+
+		It("fails a download without leaving a file at the destination", func() {
+			dir := GinkgoT().TempDir()
+			destPath := filepath.Join(dir, "blob")
+
+			err := blobstoreClient.GetFile("some-key", destPath, func(o *client.GetOptions) {
+				o.Digests = []client.Digest{digestOf("other content")}
+			})
+
+			var mismatchErr *client.DigestMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue(), "%v", err)
+			Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue())
+			Expect(os.ReadDir(dir)).To(BeEmpty())
+		})
+	})
+})
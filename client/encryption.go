@@ -0,0 +1,690 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// Client-side encrypted objects are sealed before they leave the host. Each
+// object has its own random 256-bit data key. Its content is split into
+// chunks of encryptionChunkSize bytes, each sealed with AES-256-GCM under a
+// nonce holding the chunk index and a flag marking the last chunk, so that
+// chunks can neither be reordered nor the object truncated unnoticed. Ranged
+// reads fetch and open only the chunks covering the range. The data key is
+// stored in the object metadata, wrapped by a local key or by AWS KMS.
+const (
+	encryptionScheme    = "aes-256-gcm-chunked-v1"
+	encryptionChunkSize = 64 * 1024
+	encryptedChunkSize  = encryptionChunkSize + 16
+
+	// Metadata of client-side encrypted objects, stored as x-amz-meta-<name>
+	metadataEncryption        = "s3cli-encryption"
+	metadataEncryptionKey     = "s3cli-encryption-key"
+	metadataEncryptionKeyWrap = "s3cli-encryption-key-wrap"
+	metadataEncryptionKeyID   = "s3cli-encryption-key-id"
+)
+
+// keyWrapper generates and protects the data keys of client-side encrypted
+// objects
+type keyWrapper interface {
+	// newDataKey returns a random data key and its wrapped form
+	newDataKey(ctx context.Context) (dataKey []byte, wrapped []byte, err error)
+	// unwrap returns the data key of an object from its metadata
+	unwrap(ctx context.Context, wrapped []byte, metadata map[string]string) ([]byte, error)
+	// wrapMetadata names how data keys are wrapped and by which key
+	wrapMetadata() map[string]string
+}
+
+// clientSideEncryption returns the key wrapper of the configured
+// client-side encryption, nil if it is disabled. The key is loaded once.
+func (b *awsS3Client) clientSideEncryption() (keyWrapper, error) {
+	b.encryptionOnce.Do(func() {
+		cfg := b.s3cliConfig
+		switch {
+		case cfg.ClientSideEncryptionKeyFile != "":
+			var key []byte
+			if key, b.encryptionErr = config.ReadKeyFile(cfg.ClientSideEncryptionKeyFile); b.encryptionErr == nil {
+				b.encryption = newLocalKeyWrapper(key)
+			}
+		case cfg.ClientSideEncryptionKMSKeyID != "":
+			b.encryption = b.newKMSKeyWrapper(cfg.ClientSideEncryptionKMSKeyID)
+		}
+		if b.encryptionErr != nil {
+			b.encryptionErr = &Error{Kind: ErrInvalidConfig, Err: b.encryptionErr}
+		}
+	})
+
+	return b.encryption, b.encryptionErr
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// localKeyWrapper wraps data keys with AES-256-GCM under a key read from a
+// local file. Its ID, a prefix of the key's SHA256, tells which key an
+// object needs without revealing it.
+type localKeyWrapper struct {
+	key []byte
+	id  string
+}
+
+func newLocalKeyWrapper(key []byte) *localKeyWrapper {
+	digest := sha256.Sum256(key)
+	return &localKeyWrapper{key: key, id: hex.EncodeToString(digest[:8])}
+}
+
+func (l *localKeyWrapper) newDataKey(context.Context) ([]byte, []byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newGCM(l.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return dataKey, aead.Seal(nonce, nonce, dataKey, []byte(encryptionScheme)), nil
+}
+
+func (l *localKeyWrapper) unwrap(_ context.Context, wrapped []byte, metadata map[string]string) ([]byte, error) {
+	if id := metadata[metadataEncryptionKeyID]; id != l.id {
+		return nil, &Error{Kind: ErrInvalidConfig, Err: fmt.Errorf("blob was encrypted with the key '%s', not with the configured key '%s'", id, l.id)}
+	}
+
+	aead, err := newGCM(l.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is too short")
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(encryptionScheme))
+}
+
+func (l *localKeyWrapper) wrapMetadata() map[string]string {
+	return map[string]string{metadataEncryptionKeyWrap: "local", metadataEncryptionKeyID: l.id}
+}
+
+// kmsKeyWrapper has AWS KMS generate and decrypt the data keys
+type kmsKeyWrapper struct {
+	client *kms.Client
+	keyID  string
+}
+
+// newKMSKeyWrapper shares the credentials and HTTP client of the S3 client.
+// The region is that of a key ARN, the configured one otherwise. The
+// AWS_ENDPOINT_URL_KMS environment variable of the SDK points it to a
+// KMS-compatible service.
+func (b *awsS3Client) newKMSKeyWrapper(keyID string) *kmsKeyWrapper {
+	s3Options := b.s3Client.Options()
+	kmsOptions := kms.Options{
+		Region:      s3Options.Region,
+		Credentials: s3Options.Credentials,
+		HTTPClient:  s3Options.HTTPClient,
+		Retryer:     s3Options.Retryer,
+	}
+	if keyARN, err := arn.Parse(keyID); err == nil {
+		kmsOptions.Region = keyARN.Region
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_KMS"); endpoint != "" {
+		kmsOptions.BaseEndpoint = aws.String(endpoint)
+	}
+
+	return &kmsKeyWrapper{client: kms.New(kmsOptions), keyID: keyID}
+}
+
+// kmsEncryptionContext binds the data keys to their use by s3cli
+var kmsEncryptionContext = map[string]string{metadataEncryption: encryptionScheme}
+
+func (k *kmsKeyWrapper) newDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output, err := k.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(k.keyID),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (k *kmsKeyWrapper) unwrap(ctx context.Context, wrapped []byte, _ map[string]string) ([]byte, error) {
+	output, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    wrapped,
+		KeyId:             aws.String(k.keyID),
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decrypting data key: %w", err)
+	}
+
+	return output.Plaintext, nil
+}
+
+func (k *kmsKeyWrapper) wrapMetadata() map[string]string {
+	return map[string]string{metadataEncryptionKeyWrap: "kms", metadataEncryptionKeyID: k.keyID}
+}
+
+// encryptionMetadata is the metadata of an object encrypted with a data key
+// wrapped by wrapper
+func encryptionMetadata(wrapper keyWrapper, wrapped []byte) map[string]string {
+	metadata := wrapper.wrapMetadata()
+	metadata[metadataEncryption] = encryptionScheme
+	metadata[metadataEncryptionKey] = base64.StdEncoding.EncodeToString(wrapped)
+
+	return metadata
+}
+
+// isEncrypted tells whether metadata belongs to a client-side encrypted object
+func isEncrypted(metadata map[string]string) bool {
+	return metadata[metadataEncryption] != ""
+}
+
+// dataKey unwraps the data key of an object from its metadata
+func dataKey(ctx context.Context, wrapper keyWrapper, metadata map[string]string) ([]byte, error) {
+	if scheme := metadata[metadataEncryption]; scheme != encryptionScheme {
+		return nil, fmt.Errorf("unsupported client-side encryption '%s'", scheme)
+	}
+	if wrap := metadata[metadataEncryptionKeyWrap]; wrap != wrapper.wrapMetadata()[metadataEncryptionKeyWrap] {
+		return nil, &Error{Kind: ErrInvalidConfig, Err: fmt.Errorf("blob's data key is wrapped by '%s', which is not configured", wrap)}
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(metadata[metadataEncryptionKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+
+	return wrapper.unwrap(ctx, wrapped, metadata)
+}
+
+// chunkNonce is the nonce of a chunk: its index followed by a byte marking
+// the last chunk. Data keys are never reused, neither are nonces.
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(index))
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// encryptedSize is the size of an object holding size bytes of content. Empty
+// content is sealed as a single empty chunk.
+func encryptedSize(size int64) int64 {
+	chunks := max((size+encryptionChunkSize-1)/encryptionChunkSize, 1)
+	return size + chunks*(encryptedChunkSize-encryptionChunkSize)
+}
+
+// decryptedSize is the size of the content of an encrypted object
+func decryptedSize(size int64) (int64, error) {
+	chunks := (size + encryptedChunkSize - 1) / encryptedChunkSize
+	if chunks == 0 || size-chunks*(encryptedChunkSize-encryptionChunkSize) < 0 {
+		return 0, fmt.Errorf("encrypted blob of %d bytes is truncated", size)
+	}
+
+	return size - chunks*(encryptedChunkSize-encryptionChunkSize), nil
+}
+
+// encryptingReader seals the content read from r chunk by chunk
+type encryptingReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	index  int64
+	plain  []byte
+	sealed []byte
+	done   bool
+}
+
+func newEncryptingReader(r io.Reader, key []byte) (*encryptingReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptingReader{r: r, aead: aead, plain: make([]byte, 0, encryptionChunkSize+1)}, nil
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.sealed) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.sealChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, e.sealed)
+	e.sealed = e.sealed[n:]
+	return n, nil
+}
+
+// sealChunk reads the next chunk and one byte beyond it, which tells whether
+// the chunk is the last one
+func (e *encryptingReader) sealChunk() error {
+	n, err := io.ReadFull(e.r, e.plain[len(e.plain):encryptionChunkSize+1])
+	e.plain = e.plain[:len(e.plain)+n]
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	last := err != nil
+	chunk := e.plain
+	if !last {
+		chunk = e.plain[:encryptionChunkSize]
+	}
+	e.sealed = e.aead.Seal(e.sealed[:0], chunkNonce(e.index, last), chunk, nil)
+	e.index++
+
+	if last {
+		e.done = true
+		e.plain = e.plain[:0]
+	} else {
+		e.plain = append(e.plain[:0], e.plain[encryptionChunkSize])
+	}
+	return nil
+}
+
+// decryptingWriter opens the chunks written to it, from firstChunk on, and
+// writes the content from skip bytes into the first one, length bytes in
+// total, to dest
+type decryptingWriter struct {
+	dest      io.Writer
+	aead      cipher.AEAD
+	index     int64
+	lastChunk int64
+	skip      int64
+	remaining int64
+	buf       []byte
+}
+
+func newDecryptingWriter(dest io.Writer, key []byte, firstChunk int64, lastChunk int64, skip int64, length int64) (*decryptingWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingWriter{
+		dest:      dest,
+		aead:      aead,
+		index:     firstChunk,
+		lastChunk: lastChunk,
+		skip:      skip,
+		remaining: length,
+		buf:       make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (d *decryptingWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), encryptedChunkSize-len(d.buf))
+		d.buf = append(d.buf, p[:n]...)
+		p = p[n:]
+
+		if len(d.buf) == encryptedChunkSize {
+			if err := d.openChunk(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close opens the last, possibly shorter, chunk and makes sure no content is
+// missing
+func (d *decryptingWriter) Close() error {
+	if len(d.buf) > 0 {
+		if err := d.openChunk(); err != nil {
+			return err
+		}
+	}
+	if d.remaining > 0 {
+		return fmt.Errorf("encrypted blob ended %d bytes early", d.remaining)
+	}
+
+	return nil
+}
+
+func (d *decryptingWriter) openChunk() error {
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.index, d.index == d.lastChunk), d.buf, nil)
+	if err != nil {
+		return &Error{Kind: ErrChecksumMismatch, Err: fmt.Errorf("chunk %d of the encrypted blob failed authentication: %w", d.index, err)}
+	}
+	d.index++
+
+	plain = plain[min(d.skip, int64(len(plain))):]
+	d.skip = 0
+	plain = plain[:min(d.remaining, int64(len(plain)))]
+	d.remaining -= int64(len(plain))
+	d.buf = d.buf[:0]
+
+	_, err = d.dest.Write(plain)
+	return err
+}
+
+// errEncryptedBlob refuses to download an encrypted blob as it is stored
+var errEncryptedBlob = &Error{
+	Kind: ErrInvalidConfig,
+	Err:  errors.New("blob is client-side encrypted, set client_side_encryption_key_file or client_side_encryption_kms_key_id to decrypt it"),
+}
+
+// clientSideEncryptionConfigured tells whether uploads are encrypted and
+// downloads decrypted, without loading the key
+func (b *awsS3Client) clientSideEncryptionConfigured() bool {
+	return b.s3cliConfig.ClientSideEncryptionKeyFile != "" || b.s3cliConfig.ClientSideEncryptionKMSKeyID != ""
+}
+
+// encryptUpload seals src under a new data key, which is stored wrapped in
+// the metadata of the upload. Content which can be read at any offset, e.g. a
+// file, remains so, a stream is sealed while it is read.
+func encryptUpload(ctx context.Context, wrapper keyWrapper, src io.Reader, headers *objectHeaders) (io.Reader, error) {
+	key, wrapped, err := wrapper.newDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers.metadata == nil {
+		headers.metadata = map[string]string{}
+	}
+	maps.Copy(headers.metadata, encryptionMetadata(wrapper, wrapped))
+
+	if readerAt, ok := src.(readerAtSeeker); ok {
+		return newSealedSection(readerAt, key)
+	}
+	return newEncryptingReader(src, key)
+}
+
+type readerAtSeeker interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// sealedReaderAt seals the chunks of content covering a read, so that the
+// parts of an upload can be read concurrently and again on a retry without
+// storing the sealed content. A chunk is always sealed under the same nonce,
+// so reading it again is refused if its content changed meanwhile: sealing
+// other content under the nonce would give away the key stream.
+type sealedReaderAt struct {
+	r         io.ReaderAt
+	size      int64
+	lastChunk int64
+	aead      cipher.AEAD
+
+	sumsMutex sync.Mutex
+	sums      map[int64][sha256.Size]byte
+}
+
+// errSourceChanged refuses to seal a chunk again after its content changed
+var errSourceChanged = errors.New("source changed while it was uploaded")
+
+// newSealedSection returns the sealed content of src from its current
+// position on
+func newSealedSection(src readerAtSeeker, key []byte) (*io.SectionReader, error) {
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	size, err := seekerSize(src)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := &sealedReaderAt{
+		r:         io.NewSectionReader(src, start, size),
+		size:      size,
+		lastChunk: max(size-1, 0) / encryptionChunkSize,
+		aead:      aead,
+		sums:      map[int64][sha256.Size]byte{},
+	}
+	return io.NewSectionReader(sealed, 0, encryptedSize(size)), nil
+}
+
+func (s *sealedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	plain := make([]byte, encryptionChunkSize)
+	var n int
+	for len(p) > 0 {
+		index := off / encryptedChunkSize
+		if off < 0 || index > s.lastChunk {
+			return n, io.EOF
+		}
+
+		chunkStart := index * encryptionChunkSize
+		length := min(encryptionChunkSize, s.size-chunkStart)
+		if read, err := s.r.ReadAt(plain[:length], chunkStart); int64(read) < length {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		if err := s.checkUnchanged(index, plain[:length]); err != nil {
+			return n, err
+		}
+
+		sealed := s.aead.Seal(nil, chunkNonce(index, index == s.lastChunk), plain[:length], nil)
+		copied := copy(p, sealed[off-index*encryptedChunkSize:])
+		p = p[copied:]
+		off += int64(copied)
+		n += copied
+	}
+
+	return n, nil
+}
+
+// checkUnchanged records the digest of the chunk at index when it is first
+// sealed and fails if it is sealed again with other content
+func (s *sealedReaderAt) checkUnchanged(index int64, plain []byte) error {
+	sum := sha256.Sum256(plain)
+
+	s.sumsMutex.Lock()
+	defer s.sumsMutex.Unlock()
+	if previous, ok := s.sums[index]; ok && previous != sum {
+		return errSourceChanged
+	}
+	s.sums[index] = sum
+	return nil
+}
+
+// getDecrypted downloads and decrypts src into dest if client-side
+// encryption is configured and src is encrypted, and tells whether it did.
+// Blobs stored in plain are left to the usual download. A range of the
+// content is served from the chunks covering it. Result is filled before
+// the download, so that it describes the content as it is written.
+func (b *awsS3Client) getDecrypted(ctx context.Context, src string, dest io.Writer, opts GetOptions) (bool, error) {
+	wrapper, err := b.clientSideEncryption()
+	if wrapper == nil || err != nil {
+		return false, err
+	}
+
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.VersionID),
+		IfNoneMatch:          ifNoneMatchParam(opts.IfNoneMatch),
+		IfModifiedSince:      ifModifiedSinceParam(opts.IfModifiedSince),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		return true, err
+	}
+	if !isEncrypted(head.Metadata) {
+		return false, nil
+	}
+
+	key, err := dataKey(ctx, wrapper, head.Metadata)
+	if err != nil {
+		return true, err
+	}
+	encrypted := aws.ToInt64(head.ContentLength)
+	size, err := decryptedSize(encrypted)
+	if err != nil {
+		return true, err
+	}
+
+	// The version and ETag pin the object the key was read from
+	getParams := &s3.GetObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            head.VersionId,
+		IfMatch:              head.ETag,
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	if opts.Result != nil {
+		opts.Result.Size = size
+		opts.Result.ETag = strings.Trim(aws.ToString(head.ETag), `"`)
+		opts.Result.VersionID = aws.ToString(head.VersionId)
+	}
+
+	// A compressed blob is decrypted as a whole and decompressed, a range is
+	// then cut from its original content
+	var decompressor *decompressingWriter
+	var verifier *digestVerifier
+	if compression := head.Metadata[metadataCompression]; compression != "" && !opts.Raw {
+		if decompressor, verifier, err = originalContent(dest, compression, head.Metadata, opts); err != nil {
+			return true, err
+		}
+		dest = decompressor
+		opts.Range = ""
+	} else if len(opts.Digests) > 0 {
+		verifier = newDigestVerifier(opts.Digests)
+		dest = io.MultiWriter(dest, verifier)
+	}
+
+	first, last := int64(0), size-1
+	if opts.Range != "" {
+		if first, last, err = contentRangeBounds(opts.Range, size); err != nil {
+			return true, err
+		}
+		if opts.Result != nil {
+			opts.Result.ContentRange = fmt.Sprintf("bytes %d-%d/%d", first, last, size)
+		}
+	}
+	firstChunk, lastChunk := first/encryptionChunkSize, max(last, 0)/encryptionChunkSize
+	if opts.Range != "" {
+		getParams.Range = aws.String(fmt.Sprintf("bytes=%d-%d", firstChunk*encryptedChunkSize, min((lastChunk+1)*encryptedChunkSize, encrypted)-1))
+	}
+
+	decrypter, err := newDecryptingWriter(dest, key, firstChunk, max(size-1, 0)/encryptionChunkSize, first-firstChunk*encryptionChunkSize, last-first+1)
+	if err == nil {
+		err = b.getStream(ctx, getParams, decrypter, nil, false)
+	}
+	if err == nil {
+		err = decrypter.Close()
+	}
+	if decompressor != nil {
+		err = decompressor.Close(err)
+	}
+	if err != nil {
+		return true, err
+	}
+	if verifier != nil {
+		if err = verifier.Verify(); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// contentRangeBounds returns the first and last byte of a range of content
+// of size bytes, given as bytes=<first>-<last>, bytes=<first>- or
+// bytes=-<suffix length>
+func contentRangeBounds(contentRange string, size int64) (int64, int64, error) {
+	invalid := &smithy.GenericAPIError{Code: "InvalidRange", Message: fmt.Sprintf("range '%s' is not satisfiable for a blob of %d bytes", contentRange, size)}
+
+	firstValue, lastValue, ok := strings.Cut(strings.TrimPrefix(contentRange, "bytes="), "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+
+	var first, last int64
+	var err error
+	if firstValue == "" {
+		var suffix int64
+		if suffix, err = strconv.ParseInt(lastValue, 10, 64); err != nil || suffix == 0 {
+			return 0, 0, invalid
+		}
+		first, last = max(size-suffix, 0), size-1
+	} else {
+		if first, err = strconv.ParseInt(firstValue, 10, 64); err != nil {
+			return 0, 0, invalid
+		}
+		last = size - 1
+		if lastValue != "" {
+			if last, err = strconv.ParseInt(lastValue, 10, 64); err != nil {
+				return 0, 0, invalid
+			}
+			last = min(last, size-1)
+		}
+	}
+	if first >= size || first > last {
+		return 0, 0, invalid
+	}
+
+	return first, last, nil
+}
+
+// plaintextGuard refuses encrypted objects when client-side encryption is
+// not configured, rather than writing out their ciphertext
+type plaintextGuard struct {
+	manager.DownloadAPIClient //nolint:staticcheck
+}
+
+func (g *plaintextGuard) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	resp, err := g.DownloadAPIClient.GetObject(ctx, params, optFns...)
+	if err == nil && isEncrypted(resp.Metadata) {
+		resp.Body.Close() //nolint:errcheck
+		return nil, errEncryptedBlob
+	}
+
+	return resp, err
+}
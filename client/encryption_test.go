@@ -0,0 +1,382 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client-side encryption", func() {
+	var server *httptest.Server
+	var objects map[string][]byte
+	var metadata map[string]http.Header
+	var objectsMutex sync.Mutex
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+	var content []byte
+	var parts map[int][]byte
+	var failNextPut func()
+
+	writeKeyFile := func() string {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		Expect(err).ToNot(HaveOccurred())
+
+		keyFile := filepath.Join(GinkgoT().TempDir(), "key")
+		Expect(os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600)).To(Succeed())
+		return keyFile
+	}
+
+	BeforeEach(func() {
+		objects = map[string][]byte{}
+		metadata = map[string]http.Header{}
+		parts = map[int][]byte{}
+		failNextPut = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			objectsMutex.Lock()
+			defer objectsMutex.Unlock()
+
+			recordMetadata := func() {
+				metadata[r.URL.Path] = http.Header{}
+				for name, values := range r.Header {
+					if strings.HasPrefix(name, "X-Amz-Meta-") {
+						metadata[r.URL.Path][name] = values
+					}
+				}
+			}
+
+			query := r.URL.Query()
+			switch {
+			case r.Method == http.MethodPost && query.Has("uploads"):
+				recordMetadata()
+				fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>some-bucket</Bucket><Key>some-key</Key><UploadId>some-upload</UploadId></InitiateMultipartUploadResult>`) //nolint:errcheck
+			case r.Method == http.MethodPost && query.Has("uploadId"):
+				var object []byte
+				for partNumber := 1; partNumber <= len(parts); partNumber++ {
+					object = append(object, parts[partNumber]...)
+				}
+				objects[r.URL.Path] = object
+				fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"some-etag-3"</ETag></CompleteMultipartUploadResult>`) //nolint:errcheck
+			case r.Method == http.MethodPut:
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if query.Has("partNumber") {
+					partNumber, _ := strconv.Atoi(query.Get("partNumber")) //nolint:errcheck
+					parts[partNumber] = body
+					w.Header().Set("ETag", fmt.Sprintf(`"etag-part-%d"`, partNumber))
+					return
+				}
+				if failNextPut != nil {
+					failNextPut()
+					failNextPut = nil
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				objects[r.URL.Path] = body
+				recordMetadata()
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, len(body)))
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				body, ok := objects[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				for name, values := range metadata[r.URL.Path] {
+					w.Header()[name] = values
+				}
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, len(body)))
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config = newTestConfig()
+		s3Config.ClientSideEncryptionKeyFile = writeKeyFile()
+		blobstoreClient = newTestClient(server.URL, s3Config)
+
+		// Three chunks, the last one partial
+		content = make([]byte, 150000)
+		_, err := rand.Read(content)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blobstoreClient.Put(bytes.NewReader(content), "some-key")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("stores the blob encrypted along with its wrapped data key", func() {
+		stored := objects["/some-bucket/some-folder/some-key"]
+		Expect(stored).To(HaveLen(150000 + 3*16))
+		Expect(bytes.Contains(stored, content[:64])).To(BeFalse())
+
+		storedMetadata := metadata["/some-bucket/some-folder/some-key"]
+		Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption")).To(Equal("aes-256-gcm-chunked-v1"))
+		Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption-Key-Wrap")).To(Equal("local"))
+		Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption-Key")).ToNot(BeEmpty())
+	})
+
+	It("decrypts the blob on get", func() {
+		var result client.ObjectInfo
+		dest := &bytes.Buffer{}
+		err := blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+			o.Result = &result
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(dest.Bytes()).To(Equal(content))
+		Expect(result.Size).To(Equal(int64(150000)))
+		Expect(result.ETag).To(Equal("etag-150048"))
+	})
+
+	It("reports the size of the content on stat", func() {
+		metadata, err := blobstoreClient.Stat("some-key")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(metadata.Size).To(Equal(int64(150000 + 3*16)))
+		Expect(metadata.Encryption).To(Equal("aes-256-gcm-chunked-v1"))
+		Expect(metadata.OriginalSize).To(Equal(int64(150000)))
+	})
+
+	It("refuses to sync", func() {
+		err := blobstoreClient.SyncUpload(GinkgoT().TempDir(), "some-prefix")
+		Expect(errors.Is(err, client.ErrInvalidConfig)).To(BeTrue())
+
+		err = blobstoreClient.SyncDownload("some-prefix", GinkgoT().TempDir())
+		Expect(errors.Is(err, client.ErrInvalidConfig)).To(BeTrue())
+	})
+
+	It("decrypts the blob into a file", func() {
+		destPath := filepath.Join(GinkgoT().TempDir(), "blob")
+		Expect(blobstoreClient.GetFile("some-key", destPath)).To(Succeed())
+
+		downloaded, err := os.ReadFile(destPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downloaded).To(Equal(content))
+	})
+
+	It("refuses to seal the content again once the source changed", func() {
+		sourcePath := filepath.Join(GinkgoT().TempDir(), "source")
+		Expect(os.WriteFile(sourcePath, content, 0644)).To(Succeed())
+		source, err := os.Open(sourcePath)
+		Expect(err).ToNot(HaveOccurred())
+		defer source.Close() //nolint:errcheck
+
+		// The retry of the upload reads the changed file
+		failNextPut = func() {
+			os.WriteFile(sourcePath, bytes.Repeat([]byte("x"), len(content)), 0644) //nolint:errcheck
+		}
+		err = blobstoreClient.Put(source, "changed-key")
+		Expect(err).To(MatchError(ContainSubstring("source changed while it was uploaded")))
+		Expect(objects).ToNot(HaveKey("/some-bucket/some-folder/changed-key"))
+	})
+
+	It("round trips an empty blob", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader(nil), "empty-key")).To(Succeed())
+		Expect(objects["/some-bucket/some-folder/empty-key"]).To(HaveLen(16))
+
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("empty-key", dest)).To(Succeed())
+		Expect(dest.Len()).To(BeZero())
+	})
+
+	DescribeTable("decrypts ranges of the blob",
+		func(contentRange string, first int, last int) {
+			var result client.ObjectInfo
+			dest := &bytes.Buffer{}
+			err := blobstoreClient.GetStream("some-key", dest, func(o *client.GetOptions) {
+				o.Range = contentRange
+				o.Result = &result
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dest.Bytes()).To(Equal(content[first : last+1]))
+			Expect(result.ContentRange).To(Equal(fmt.Sprintf("bytes %d-%d/150000", first, last)))
+		},
+		Entry("within the first chunk", "bytes=10-19", 10, 19),
+		Entry("across chunks", "bytes=65530-131080", 65530, 131080),
+		Entry("up to the end", "bytes=140000-", 140000, 149999),
+		Entry("a suffix", "bytes=-100", 149900, 149999),
+		Entry("beyond the end", "bytes=149990-200000", 149990, 149999),
+	)
+
+	It("refuses ranges beyond the blob", func() {
+		err := blobstoreClient.GetStream("some-key", io.Discard, func(o *client.GetOptions) {
+			o.Range = "bytes=150000-"
+		})
+		Expect(err).To(MatchError(ContainSubstring("InvalidRange")))
+	})
+
+	It("detects tampered content", func() {
+		objects["/some-bucket/some-folder/some-key"][70000] ^= 1
+
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue())
+	})
+
+	It("detects truncated content", func() {
+		stored := objects["/some-bucket/some-folder/some-key"]
+		objects["/some-bucket/some-folder/some-key"] = stored[:2*(64*1024+16)]
+
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrChecksumMismatch)).To(BeTrue())
+	})
+
+	It("refuses blobs encrypted with another key", func() {
+		s3Config.ClientSideEncryptionKeyFile = writeKeyFile()
+		blobstoreClient = newTestClient(server.URL, s3Config)
+
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrInvalidConfig)).To(BeTrue())
+	})
+
+	It("downloads blobs stored in plain", func() {
+		objects["/some-bucket/some-folder/plain-key"] = []byte("plain content")
+
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("plain-key", dest)).To(Succeed())
+		Expect(dest.String()).To(Equal("plain content"))
+	})
+
+	It("refuses encrypted blobs without encryption configured", func() {
+		s3Config.ClientSideEncryptionKeyFile = ""
+
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrInvalidConfig)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("client-side encrypted")))
+	})
+
+	It("fails with an invalid key file", func() {
+		s3Config.ClientSideEncryptionKeyFile = filepath.Join(GinkgoT().TempDir(), "missing")
+		blobstoreClient = newTestClient(server.URL, s3Config)
+
+		err := blobstoreClient.Put(bytes.NewReader(content), "other-key")
+		Expect(errors.Is(err, client.ErrInvalidConfig)).To(BeTrue())
+	})
+
+	DescribeTable("encrypts multipart uploads larger than the part size",
+		func(newSource func([]byte) io.Reader) {
+			s3Config.MultipartUpload = true
+			s3Config.UploadPartSize = 5 * 1024 * 1024
+			large := make([]byte, 12*1024*1024)
+			_, err := rand.Read(large)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(blobstoreClient.Put(newSource(large), "large-key")).To(Succeed())
+
+			Expect(parts).To(HaveLen(3))
+			stored := objects["/some-bucket/some-folder/large-key"]
+			Expect(stored).To(HaveLen(len(large) + len(large)/(64*1024)*16))
+			Expect(metadata["/some-bucket/some-folder/large-key"].Get("X-Amz-Meta-S3cli-Encryption")).To(Equal("aes-256-gcm-chunked-v1"))
+
+			dest := &bytes.Buffer{}
+			Expect(blobstoreClient.GetStream("large-key", dest)).To(Succeed())
+			Expect(dest.Bytes()).To(Equal(large))
+		},
+		Entry("read from a seekable source", func(content []byte) io.Reader { return bytes.NewReader(content) }),
+		Entry("read from a stream", func(content []byte) io.Reader { return io.MultiReader(bytes.NewReader(content)) }),
+	)
+
+	Describe("with AWS KMS", func() {
+		type kmsRequest struct {
+			Target            string
+			KeyID             string            `json:"KeyId"`
+			KeySpec           string            `json:",omitempty"`
+			EncryptionContext map[string]string `json:",omitempty"`
+		}
+
+		var kmsServer *httptest.Server
+		var kmsRequests []kmsRequest
+		var refuseDecrypt bool
+
+		BeforeEach(func() {
+			kmsRequests = nil
+			refuseDecrypt = false
+			dataKey := bytes.Repeat([]byte{7}, 32)
+			kmsServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				objectsMutex.Lock()
+				defer objectsMutex.Unlock()
+
+				var request kmsRequest
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				request.Target = r.Header.Get("X-Amz-Target")
+				kmsRequests = append(kmsRequests, request)
+
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				switch {
+				case request.Target == "TrentService.GenerateDataKey":
+					json.NewEncoder(w).Encode(map[string]any{ //nolint:errcheck
+						"KeyId":          request.KeyID,
+						"Plaintext":      dataKey,
+						"CiphertextBlob": []byte("wrapped data key"),
+					})
+				case request.Target == "TrentService.Decrypt" && !refuseDecrypt:
+					json.NewEncoder(w).Encode(map[string]any{"KeyId": request.KeyID, "Plaintext": dataKey}) //nolint:errcheck
+				default:
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"__type":"AccessDeniedException","message":"some message"}`) //nolint:errcheck
+				}
+			}))
+			GinkgoT().Setenv("AWS_ENDPOINT_URL_KMS", kmsServer.URL)
+
+			s3Config.ClientSideEncryptionKeyFile = ""
+			s3Config.ClientSideEncryptionKMSKeyID = "some-kms-key"
+			blobstoreClient = newTestClient(server.URL, s3Config)
+		})
+
+		AfterEach(func() {
+			kmsServer.Close()
+		})
+
+		It("has KMS generate and decrypt the data key within the encryption context", func() {
+			Expect(blobstoreClient.Put(bytes.NewReader(content), "kms-key")).To(Succeed())
+
+			storedMetadata := metadata["/some-bucket/some-folder/kms-key"]
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption-Key-Wrap")).To(Equal("kms"))
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption-Key-Id")).To(Equal("some-kms-key"))
+			Expect(storedMetadata.Get("X-Amz-Meta-S3cli-Encryption-Key")).To(Equal(base64.StdEncoding.EncodeToString([]byte("wrapped data key"))))
+
+			dest := &bytes.Buffer{}
+			Expect(blobstoreClient.GetStream("kms-key", dest)).To(Succeed())
+			Expect(dest.Bytes()).To(Equal(content))
+
+			encryptionContext := map[string]string{"s3cli-encryption": "aes-256-gcm-chunked-v1"}
+			Expect(kmsRequests).To(Equal([]kmsRequest{
+				{Target: "TrentService.GenerateDataKey", KeyID: "some-kms-key", KeySpec: "AES_256", EncryptionContext: encryptionContext},
+				{Target: "TrentService.Decrypt", KeyID: "some-kms-key", EncryptionContext: encryptionContext},
+			}))
+		})
+
+		It("fails when KMS refuses to decrypt the data key", func() {
+			Expect(blobstoreClient.Put(bytes.NewReader(content), "kms-key")).To(Succeed())
+			refuseDecrypt = true
+
+			err := blobstoreClient.GetStream("kms-key", io.Discard)
+			Expect(err).To(MatchError(ContainSubstring("decrypting data key")))
+			Expect(err).To(MatchError(ContainSubstring("AccessDeniedException")))
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Kinds of errors returned by S3CompatibleClient, to be tested with errors.Is
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrAccessDenied       = errors.New("access denied")
+	ErrThrottled          = errors.New("throttled")
+	ErrChecksumMismatch   = errors.New("checksum mismatch")
+	ErrReadOnlyMode       = errors.New("read only mode")
+	ErrInvalidConfig      = errors.New("invalid config")
+	ErrTimeout            = errors.New("timeout")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrNotModified        = errors.New("not modified")
+	// ErrArchived is returned for reads of an object in an archive storage
+	// class such as GLACIER, which has to be restored first
+	ErrArchived = errors.New("archived")
+	// ErrObjectLocked is returned for deletes and overwrites S3 Object Lock
+	// denies, because of a retention period or legal hold
+	ErrObjectLocked = errors.New("object locked")
+	// ErrInvalidArgument is returned for options which are invalid or cannot
+	// be combined, e.g. an expected ETag with if_none_match
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// Error is an error classified by Kind, one of the Err* errors of this
+// package. errors.Is and errors.As see both Kind and Err, e.g. the
+// smithy.APIError returned by the blobstore.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// errorKindsByCode classifies the error codes of S3 and S3-compatible blobstores
+var errorKindsByCode = map[string]error{
+	"NotFound":                     ErrNotFound,
+	"NoSuchKey":                    ErrNotFound,
+	"NoSuchUpload":                 ErrNotFound,
+	"NoSuchVersion":                ErrNotFound,
+	"AccessDenied":                 ErrAccessDenied,
+	"Forbidden":                    ErrAccessDenied,
+	"AllAccessDisabled":            ErrAccessDenied,
+	"InvalidAccessKeyId":           ErrAccessDenied,
+	"SignatureDoesNotMatch":        ErrAccessDenied,
+	"ExpiredToken":                 ErrAccessDenied,
+	"InvalidToken":                 ErrAccessDenied,
+	"SlowDown":                     ErrThrottled,
+	"Throttling":                   ErrThrottled,
+	"ThrottlingException":          ErrThrottled,
+	"RequestLimitExceeded":         ErrThrottled,
+	"RequestThrottled":             ErrThrottled,
+	"TooManyRequests":              ErrThrottled,
+	"BadDigest":                    ErrChecksumMismatch,
+	"InvalidDigest":                ErrChecksumMismatch,
+	"XAmzContentSHA256Mismatch":    ErrChecksumMismatch,
+	"NoSuchBucket":                 ErrInvalidConfig,
+	"InvalidBucketName":            ErrInvalidConfig,
+	"AuthorizationHeaderMalformed": ErrInvalidConfig,
+	"PermanentRedirect":            ErrInvalidConfig,
+	"RequestTimeout":               ErrTimeout,
+	"PreconditionFailed":           ErrPreconditionFailed,
+	"ConditionalRequestConflict":   ErrPreconditionFailed,
+	"NotModified":                  ErrNotModified,
+	"InvalidObjectState":           ErrArchived,
+	"ObjectLocked":                 ErrObjectLocked,
+}
+
+// errorKindsByStatus classifies errors by HTTP status when their code is unknown,
+// e.g. HeadObject responses, which have no body to carry a code
+var errorKindsByStatus = map[int]error{
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusUnauthorized:       ErrAccessDenied,
+	http.StatusForbidden:          ErrAccessDenied,
+	http.StatusTooManyRequests:    ErrThrottled,
+	http.StatusPreconditionFailed: ErrPreconditionFailed,
+	http.StatusNotModified:        ErrNotModified,
+}
+
+// classifyError wraps err into an Error if its kind is known
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	if kind := errorKind(err); kind != nil {
+		if kind == ErrArchived {
+			err = archivedError(err)
+		}
+		return &Error{Kind: kind, Err: err}
+	}
+
+	return err
+}
+
+// archivedError tells that the object has to be restored, which the
+// InvalidObjectState of S3 only hints at
+func archivedError(err error) error {
+	var stateErr *types.InvalidObjectState
+	if errors.As(err, &stateErr) && stateErr.AccessTier != "" {
+		return fmt.Errorf("blob is archived in %s, restore required: %w", stateErr.AccessTier, err)
+	}
+	if errors.As(err, &stateErr) && stateErr.StorageClass != "" {
+		return fmt.Errorf("blob is archived in %s, restore required: %w", stateErr.StorageClass, err)
+	}
+
+	return fmt.Errorf("blob is archived, restore required: %w", err)
+}
+
+// isObjectLockDenial tells an AccessDenied caused by Object Lock, which S3
+// only tells by its message, e.g. "Access Denied because object protected by
+// object lock."
+func isObjectLockDenial(apiErr smithy.APIError) bool {
+	return apiErr.ErrorCode() == "AccessDenied" && strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "object lock")
+}
+
+func errorKind(err error) error {
+	var mismatchErr *DigestMismatchError
+	if errors.As(err, &mismatchErr) {
+		return ErrChecksumMismatch
+	}
+
+	if errors.Is(err, errorInvalidCredentialsSourceValue) {
+		return ErrReadOnlyMode
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if isObjectLockDenial(apiErr) {
+			return ErrObjectLocked
+		}
+		if kind, ok := errorKindsByCode[apiErr.ErrorCode()]; ok {
+			return kind
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		if kind, ok := errorKindsByStatus[respErr.HTTPStatusCode()]; ok {
+			return kind
+		}
+	}
+
+	// Covers operation_timeout_seconds, attempt_timeout_seconds and network
+	// timeouts, but not the cancellation of an interrupted command
+	var timeoutErr interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &timeoutErr) && timeoutErr.Timeout() {
+		return ErrTimeout
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	var server *httptest.Server
+	var status int
+	var code string
+	var delay time.Duration
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		code = ""
+		delay = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(status)
+			if code != "" && r.Method != http.MethodHead {
+				fmt.Fprintf(w, `<Error><Code>%s</Code><Message>some message</Message></Error>`, code) //nolint:errcheck
+			}
+		}))
+
+		s3Config = newTestConfig()
+	})
+
+	JustBeforeEach(func() {
+		s3Client := newTestS3Client(server.URL, func(c *aws.Config) {
+			c.RetryMaxAttempts = 1
+		})
+
+		blobstoreClient = client.New(s3Client, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("classifies the errors of the blobstore by code",
+		func(responseStatus int, responseCode string, kind error) {
+			status, code = responseStatus, responseCode
+
+			err := blobstoreClient.GetStream("some-key", &bytes.Buffer{})
+			Expect(err).To(MatchError(kind))
+
+			var apiErr smithy.APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.ErrorCode()).To(Equal(responseCode))
+		},
+		Entry("a missing object", http.StatusNotFound, "NoSuchKey", client.ErrNotFound),
+		Entry("missing permissions", http.StatusForbidden, "AccessDenied", client.ErrAccessDenied),
+		Entry("invalid credentials", http.StatusForbidden, "InvalidAccessKeyId", client.ErrAccessDenied),
+		Entry("throttling", http.StatusServiceUnavailable, "SlowDown", client.ErrThrottled),
+		Entry("a corrupt upload", http.StatusBadRequest, "BadDigest", client.ErrChecksumMismatch),
+		Entry("a missing bucket", http.StatusNotFound, "NoSuchBucket", client.ErrInvalidConfig),
+		Entry("an archived object", http.StatusForbidden, "InvalidObjectState", client.ErrArchived),
+		Entry("a blob protected by object lock", http.StatusBadRequest, "ObjectLocked", client.ErrObjectLocked),
+	)
+
+	It("classifies the errors of responses without a body by status", func() {
+		status = http.StatusForbidden
+
+		_, err := blobstoreClient.Exists("some-key")
+		Expect(err).To(MatchError(client.ErrAccessDenied))
+	})
+
+	It("leaves unknown errors unclassified", func() {
+		status, code = http.StatusInternalServerError, "InternalError"
+
+		err := blobstoreClient.GetStream("some-key", &bytes.Buffer{})
+		Expect(err).To(HaveOccurred())
+
+		var classified *client.Error
+		Expect(errors.As(err, &classified)).To(BeFalse())
+	})
+
+	It("classifies a digest mismatch", func() {
+		status = http.StatusOK
+
+		err := blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.Digests = []client.Digest{{Algorithm: "sha1", Value: "07e1306432667f916639d47481edc4f2ca456454"}}
+		})
+		Expect(err).To(MatchError(client.ErrChecksumMismatch))
+
+		var mismatchErr *client.DigestMismatchError
+		Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+	})
+
+	Context("when credentials_source is none", func() {
+		BeforeEach(func() {
+			s3Config.CredentialsSource = config.NoneCredentialsSource
+		})
+
+		It("classifies writes as read only mode", func() {
+			Expect(blobstoreClient.Delete("some-key")).To(MatchError(client.ErrReadOnlyMode))
+		})
+	})
+
+	Context("when operation_timeout_seconds is exceeded", func() {
+		BeforeEach(func() {
+			s3Config.OperationTimeoutSeconds = 1
+			delay = 2 * time.Second
+		})
+
+		It("classifies the error as timeout", func() {
+			_, err := blobstoreClient.ExistsWithContext(context.Background(), "some-key")
+			Expect(err).To(MatchError(client.ErrTimeout))
+		})
+	})
+})
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// partialDownloadSuffix ends the name of the partial file of a resumable download
+	partialDownloadSuffix = ".s3cli-partial"
+	// tmpDownloadSuffix ends the name of the temporary file of any other download
+	tmpDownloadSuffix = ".s3cli-tmp"
+)
+
+// GetFile downloads a blob into a sibling temporary file which is synced and
+// renamed to destPath once complete, so destPath never holds a partial blob
+// nor one failing the digest verification.
+func (b *awsS3Client) GetFile(ctx context.Context, src string, destPath string, optFns ...func(*GetOptions)) error {
+	opts := getOptions(optFns)
+	if err := validateGetOptions(opts); err != nil {
+		return err
+	}
+
+	// Devices and pipes such as /dev/null can neither be replaced nor written at offsets
+	if info, err := os.Stat(destPath); err == nil && !info.Mode().IsRegular() {
+		destFile, err := os.OpenFile(destPath, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer destFile.Close() //nolint:errcheck
+
+		return b.GetStream(ctx, src, destFile, optFns...)
+	}
+
+	if opts.Resume {
+		return b.getFileResumable(ctx, src, destPath, opts)
+	}
+
+	dir, base := filepath.Dir(destPath), filepath.Base(destPath)
+	tmpFile, err := os.CreateTemp(dir, "."+base+".*"+tmpDownloadSuffix)
+	if err != nil {
+		return err
+	}
+	// CreateTemp restricts the file to its owner, while the blob used to be
+	// created with the usual permissions
+	if err = tmpFile.Chmod(0644); err != nil {
+		return commitDownload(tmpFile, destPath, err, true)
+	}
+
+	err = b.Get(ctx, src, tmpFile, optFns...)
+	return commitDownload(tmpFile, destPath, err, true)
+}
+
+// getFileResumable downloads sequentially, so the partial file always holds a
+// prefix of the object which a ranged GET can continue. Its name carries the
+// ETag of the object to make sure it is never continued with another version.
+func (b *awsS3Client) getFileResumable(ctx context.Context, src string, destPath string, opts GetOptions) error {
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.VersionID),
+		IfNoneMatch:          ifNoneMatchParam(opts.IfNoneMatch),
+		IfModifiedSince:      ifModifiedSinceParam(opts.IfModifiedSince),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		return err
+	}
+	// Encrypted objects are decrypted chunk by chunk from their beginning
+	if isEncrypted(head.Metadata) {
+		if !b.clientSideEncryptionConfigured() {
+			return errEncryptedBlob
+		}
+		return &Error{Kind: ErrInvalidArgument, Err: errors.New("downloads of client-side encrypted blobs cannot be resumed")}
+	}
+	// Compressed objects are decompressed from their beginning
+	if head.Metadata[metadataCompression] != "" && !opts.Raw {
+		return &Error{Kind: ErrInvalidArgument, Err: errors.New("downloads of compressed blobs can only be resumed raw")}
+	}
+	etag := aws.ToString(head.ETag)
+	size := aws.ToInt64(head.ContentLength)
+	if opts.Result != nil {
+		*opts.Result = ObjectInfo{
+			Key:       src,
+			Bucket:    b.s3cliConfig.BucketName,
+			Size:      size,
+			ETag:      strings.Trim(etag, `"`),
+			VersionID: aws.ToString(head.VersionId),
+		}
+	}
+
+	dir, base := filepath.Dir(destPath), filepath.Base(destPath)
+	partialName := fmt.Sprintf(".%s.%s%s", base, strings.Trim(etag, `"`), partialDownloadSuffix)
+	removeStalePartialDownloads(dir, base, partialName)
+	partialPath := filepath.Join(dir, partialName)
+
+	partialFile, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := partialFile.Stat()
+	if err != nil {
+		return commitDownload(partialFile, destPath, err, false)
+	}
+	downloaded := info.Size()
+	if downloaded > size {
+		// Not a prefix of this object, start from scratch
+		if err = partialFile.Truncate(0); err != nil {
+			return commitDownload(partialFile, destPath, err, false)
+		}
+		downloaded = 0
+	}
+	if opts.Result != nil {
+		opts.Result.ResumedFrom = downloaded
+	}
+
+	var dest io.Writer = partialFile
+	var verifier *digestVerifier
+	if len(opts.Digests) > 0 {
+		// The digests cover the whole object, including the bytes downloaded before
+		verifier = newDigestVerifier(opts.Digests)
+		if _, err = io.Copy(verifier, io.NewSectionReader(partialFile, 0, downloaded)); err != nil {
+			return commitDownload(partialFile, destPath, err, false)
+		}
+		dest = io.MultiWriter(partialFile, verifier)
+	}
+
+	getParams := &s3.GetObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(src),
+		VersionId:            versionIDParam(opts.VersionID),
+		IfMatch:              aws.String(etag),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	}
+	switch {
+	case downloaded == 0:
+		err = b.getStream(ctx, getParams, dest, nil, false)
+	case downloaded < size:
+		b.logger.InfoContext(ctx, "Resuming download", "key", src, "offset", downloaded, "size", size)
+		getParams.Range = aws.String(fmt.Sprintf("bytes=%d-", downloaded))
+
+		var resp *s3.GetObjectOutput
+		if resp, err = b.s3Client.GetObject(ctx, getParams); err == nil {
+			_, err = io.Copy(dest, resp.Body)
+			resp.Body.Close() //nolint:errcheck
+		}
+	}
+	if err == nil && verifier != nil {
+		err = verifier.Verify()
+	}
+
+	// Neither a changed object nor corrupt content is worth resuming
+	var apiErr smithy.APIError
+	var mismatchErr *DigestMismatchError
+	removePartial := errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" || errors.As(err, &mismatchErr)
+	return commitDownload(partialFile, destPath, err, removePartial)
+}
+
+// commitDownload syncs and renames a successfully downloaded file to destPath,
+// then syncs its directory so that the rename survives a crash too. After a
+// failed download it removes the file unless it may be resumed.
+func commitDownload(file *os.File, destPath string, downloadErr error, removeOnError bool) error {
+	err := downloadErr
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), destPath)
+	}
+
+	if err != nil && removeOnError {
+		os.Remove(file.Name()) //nolint:errcheck
+	}
+	if err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(destPath))
+}
+
+// syncDir flushes the entries of the directory at path to disk
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeStalePartialDownloads removes partial downloads of other versions of the
+// object, i.e. the files named "."+base+"."+etag+partialDownloadSuffix
+func removeStalePartialDownloads(dir string, base string, keepName string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != keepName && isPartialDownloadOf(name, base) {
+			os.Remove(filepath.Join(dir, name)) //nolint:errcheck
+		}
+	}
+}
+
+// isPartialDownloadOf tells whether name is a partial download of base. ETags
+// contain no dots, which keeps the partial downloads of e.g. foo.tgz apart
+// from those of foo.
+func isPartialDownloadOf(name string, base string) bool {
+	etag, ok := strings.CutPrefix(name, "."+base+".")
+	if !ok {
+		return false
+	}
+	etag, ok = strings.CutSuffix(etag, partialDownloadSuffix)
+
+	return ok && etag != "" && !strings.Contains(etag, ".")
+}
@@ -0,0 +1,190 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GetFile", func() {
+	const content = "some blob content"
+
+	var server *httptest.Server
+	var gets []*http.Request
+	var etag string
+	var failGet, truncateGet, changeAfterHead bool
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+	var dir, destPath string
+
+	BeforeEach(func() {
+		gets = []*http.Request{}
+		etag = "some-etag"
+		failGet, truncateGet, changeAfterHead = false, false, false
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			w.Header().Set("ETag", `"`+etag+`"`)
+			if r.Method == http.MethodHead {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				if changeAfterHead {
+					etag = "other-etag"
+				}
+				return
+			}
+
+			gets = append(gets, r)
+			switch {
+			case failGet:
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			case r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != `"`+etag+`"`:
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`) //nolint:errcheck
+				return
+			}
+
+			start, end := 0, len(content)-1
+			if rangeHeader, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+				first, last, _ := strings.Cut(rangeHeader, "-")
+				start, _ = strconv.Atoi(first) //nolint:errcheck
+				if last != "" {
+					end, _ = strconv.Atoi(last) //nolint:errcheck
+					end = min(end, len(content)-1)
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+			}
+			body := content[start : end+1]
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			if r.Header.Get("Range") != "" {
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			if truncateGet {
+				// The connection is closed before the announced length was sent
+				body = body[:len(body)/2]
+			}
+			w.Write([]byte(body)) //nolint:errcheck
+		}))
+
+		s3Client := newTestS3Client(server.URL, func(c *aws.Config) {
+			c.RetryMaxAttempts = 1
+		})
+		blobstoreClient = client.New(s3Client, newTestConfig())
+
+		dir = GinkgoT().TempDir()
+		destPath = filepath.Join(dir, "blob")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	dirEntries := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	resume := func(o *client.GetOptions) {
+		o.Resume = true
+	}
+
+	It("renames the completed download to the destination", func() {
+		Expect(blobstoreClient.GetFile("some-key", destPath)).To(Succeed())
+
+		Expect(os.ReadFile(destPath)).To(Equal([]byte(content)))
+		info, err := os.Stat(destPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0644)))
+		Expect(dirEntries()).To(Equal([]string{"blob"}))
+	})
+
+	It("removes the temporary file of a failed download and keeps the destination", func() {
+		Expect(os.WriteFile(destPath, []byte("previous content"), 0644)).To(Succeed())
+		failGet = true
+
+		Expect(blobstoreClient.GetFile("some-key", destPath)).ToNot(Succeed())
+
+		Expect(os.ReadFile(destPath)).To(Equal([]byte("previous content")))
+		Expect(dirEntries()).To(Equal([]string{"blob"}))
+	})
+
+	It("continues a partial download with a ranged GET", func() {
+		partialPath := filepath.Join(dir, ".blob.some-etag.s3cli-partial")
+		Expect(os.WriteFile(partialPath, []byte(content[:5]), 0644)).To(Succeed())
+
+		var object client.ObjectInfo
+		Expect(blobstoreClient.GetFile("some-key", destPath, resume, func(o *client.GetOptions) {
+			o.Result = &object
+		})).To(Succeed())
+
+		Expect(object.Size).To(Equal(int64(len(content))))
+		Expect(object.ResumedFrom).To(Equal(int64(5)))
+		Expect(gets).To(HaveLen(1))
+		Expect(gets[0].Header.Get("Range")).To(Equal("bytes=5-"))
+		Expect(gets[0].Header.Get("If-Match")).To(Equal(`"some-etag"`))
+		Expect(os.ReadFile(destPath)).To(Equal([]byte(content)))
+		Expect(dirEntries()).To(Equal([]string{"blob"}))
+	})
+
+	It("keeps the partial file of a failed download to resume it", func() {
+		truncateGet = true
+		Expect(blobstoreClient.GetFile("some-key", destPath, resume)).ToNot(Succeed())
+
+		partialPath := filepath.Join(dir, ".blob.some-etag.s3cli-partial")
+		partial, err := os.ReadFile(partialPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(HavePrefix(string(partial)))
+		Expect(destPath).ToNot(BeAnExistingFile())
+
+		truncateGet = false
+		Expect(blobstoreClient.GetFile("some-key", destPath, resume)).To(Succeed())
+
+		Expect(gets[len(gets)-1].Header.Get("Range")).To(Equal(fmt.Sprintf("bytes=%d-", len(partial))))
+		Expect(os.ReadFile(destPath)).To(Equal([]byte(content)))
+		Expect(partialPath).ToNot(BeAnExistingFile())
+	})
+
+	It("only removes the partial downloads of other versions of the blob", func() {
+		for _, name := range []string{
+			".blob.old-etag.s3cli-partial",
+			".blob.tgz.old-etag.s3cli-partial",
+			".blob.123456.s3cli-tmp",
+		} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte("other content"), 0644)).To(Succeed())
+		}
+
+		Expect(blobstoreClient.GetFile("some-key", destPath, resume)).To(Succeed())
+
+		Expect(gets[0].Header.Get("Range")).To(Or(BeEmpty(), HavePrefix("bytes=0-")))
+		Expect(os.ReadFile(destPath)).To(Equal([]byte(content)))
+		Expect(dirEntries()).To(ConsistOf(".blob.123456.s3cli-tmp", ".blob.tgz.old-etag.s3cli-partial", "blob"))
+	})
+
+	It("discards the partial file when the blob changes during the download", func() {
+		changeAfterHead = true
+
+		err := blobstoreClient.GetFile("some-key", destPath, resume)
+		Expect(err).To(MatchError(client.ErrPreconditionFailed))
+
+		Expect(dirEntries()).To(BeEmpty())
+	})
+})
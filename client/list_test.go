@@ -0,0 +1,161 @@
+package client_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listObject   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listObject struct {
+	Key          string `xml:"Key"`
+	Size         int64  `xml:"Size"`
+	ETag         string `xml:"ETag"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+var _ = Describe("List", func() {
+	var server *httptest.Server
+	var keys []string
+	var requests []string
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		keys = []string{
+			"some-folder/a",
+			"some-folder/b",
+			"some-folder/dir/c",
+			"some-folder/dir/d",
+			"some-folder/e",
+			"other-folder/f",
+		}
+		requests = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			query := r.URL.Query()
+			requests = append(requests, r.URL.RawQuery)
+			prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+			maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+			if err != nil {
+				maxKeys = 1000
+			}
+			start, _ := strconv.Atoi(query.Get("continuation-token")) //nolint:errcheck
+
+			// Entries of the page, each common prefix counting once
+			var result listBucketResult
+			seen := map[string]bool{}
+			i := start
+			for ; i < len(keys) && len(result.Contents)+len(result.CommonPrefixes) < maxKeys; i++ {
+				key := keys[i]
+				if !strings.HasPrefix(key, prefix) {
+					continue
+				}
+				if delimiter != "" {
+					if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+						dir := key[:len(prefix)+index+len(delimiter)]
+						if !seen[dir] {
+							seen[dir] = true
+							result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: dir})
+						}
+						continue
+					}
+				}
+				result.Contents = append(result.Contents, listObject{Key: key, Size: int64(len(key)), ETag: `"etag-` + key + `"`, StorageClass: "STANDARD"})
+			}
+			for _, key := range keys[i:] {
+				if strings.HasPrefix(key, prefix) {
+					result.IsTruncated = true
+					result.NextContinuationToken = strconv.Itoa(i)
+					break
+				}
+			}
+
+			w.Header().Set("Content-Type", "application/xml")
+			xml.NewEncoder(w).Encode(result) //nolint:errcheck
+		}))
+
+		blobstoreClient = newTestClient(server.URL, newTestConfig())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	list := func(prefix string, optFns ...func(*client.ListOptions)) []client.ListEntry {
+		var entries []client.ListEntry
+		Expect(blobstoreClient.List(prefix, func(entry client.ListEntry) error {
+			entries = append(entries, entry)
+			return nil
+		}, optFns...)).To(Succeed())
+
+		return entries
+	}
+
+	It("follows the pages of the listing", func() {
+		entries := list("", func(o *client.ListOptions) {
+			o.PageSize = 2
+		})
+
+		var listed []string
+		for _, entry := range entries {
+			listed = append(listed, entry.Key)
+		}
+		Expect(listed).To(Equal([]string{"a", "b", "dir/c", "dir/d", "e"}))
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[1]).To(ContainSubstring("continuation-token=2"))
+	})
+
+	It("strips the folder from the keys", func() {
+		entries := list("dir/")
+
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0]).To(Equal(client.ListEntry{Key: "dir/c", Size: int64(len("some-folder/dir/c")), ETag: "etag-some-folder/dir/c", StorageClass: "STANDARD"}))
+		Expect(entries[1].Key).To(Equal("dir/d"))
+		Expect(requests[0]).To(ContainSubstring("prefix=some-folder%2Fdir%2F"))
+	})
+
+	It("groups keys into common prefixes with a delimiter", func() {
+		entries := list("", func(o *client.ListOptions) {
+			o.Delimiter = "/"
+		})
+
+		Expect(entries).To(Equal([]client.ListEntry{
+			{Key: "dir/", IsPrefix: true},
+			{Key: "a", Size: int64(len("some-folder/a")), ETag: "etag-some-folder/a", StorageClass: "STANDARD"},
+			{Key: "b", Size: int64(len("some-folder/b")), ETag: "etag-some-folder/b", StorageClass: "STANDARD"},
+			{Key: "e", Size: int64(len("some-folder/e")), ETag: "etag-some-folder/e", StorageClass: "STANDARD"},
+		}))
+	})
+
+	It("stops after the maximum number of entries", func() {
+		entries := list("", func(o *client.ListOptions) {
+			o.PageSize = 2
+			o.MaxEntries = 3
+		})
+
+		Expect(entries).To(HaveLen(3))
+		Expect(requests).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	s3cli_config "github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveAttributes are never logged, whatever their value
+var sensitiveAttributes = map[string]struct{}{
+	"access_key_id":        {},
+	"secret_access_key":    {},
+	"session_token":        {},
+	"swift_temp_url_key":   {},
+	"authorization":        {},
+	"x-amz-security-token": {},
+	"x-amz-server-side-encryption-customer-key": {},
+}
+
+// secretParameter matches the credentials and signatures carried by signed
+// requests and presigned URLs, e.g. "X-Amz-Signature=..." in a query string
+// or "Signature=..." in an Authorization header
+var secretParameter = regexp.MustCompile(`(?i)((?:X-Amz-)?(?:Credential|Signature|Security-Token)|AWSAccessKeyId|temp_url_sig)=[^&,\s"]+`)
+
+// Redact hides the credentials and signatures contained in s, e.g. a
+// presigned URL
+func Redact(s string) string {
+	return secretParameter.ReplaceAllString(s, "${1}="+redacted)
+}
+
+// NewLogger returns a logger writing to w at the log_level and in the
+// log_format of c. Everything it logs goes through Redact.
+func NewLogger(w io.Writer, c *s3cli_config.S3Cli) *slog.Logger {
+	level := slog.LevelInfo
+	switch c.LogLevel {
+	case s3cli_config.LogLevelDebug:
+		level = slog.LevelDebug
+	case s3cli_config.LogLevelWarn:
+		level = slog.LevelWarn
+	case s3cli_config.LogLevelError:
+		level = slog.LevelError
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if c.LogFormat == s3cli_config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, handlerOptions))
+	}
+
+	return slog.New(slog.NewTextHandler(w, handlerOptions))
+}
+
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if _, ok := sensitiveAttributes[strings.ToLower(attr.Key)]; ok {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case string:
+		return slog.String(attr.Key, Redact(value))
+	case error:
+		return slog.String(attr.Key, Redact(value.Error()))
+	}
+
+	return attr
+}
+
+// AddDebugLoggingMiddleware logs a summary of every attempt of a request at
+// debug level: operation, method, URL, status, request ID and duration.
+// Headers and bodies are not logged.
+func AddDebugLoggingMiddleware(logger *slog.Logger) func(*middleware.Stack) error {
+	// The innermost deserialize middleware sees each signed attempt and its
+	// raw response
+	debugLogging := middleware.DeserializeMiddlewareFunc("DebugLogging",
+		func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			req, ok := in.Request.(*smithyhttp.Request)
+			if !ok || !logger.Enabled(ctx, slog.LevelDebug) {
+				return next.HandleDeserialize(ctx, in)
+			}
+
+			operation := middleware.GetOperationName(ctx)
+			logger.DebugContext(ctx, "Sending request",
+				"operation", operation,
+				"method", req.Method,
+				"url", req.URL.String(),
+				"content_length", req.ContentLength,
+			)
+
+			start := time.Now()
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+
+			attrs := []any{"operation", operation, "duration", time.Since(start)}
+			if resp, ok := out.RawResponse.(*smithyhttp.Response); ok {
+				attrs = append(attrs,
+					"status", resp.StatusCode,
+					"request_id", resp.Header.Get("X-Amz-Request-Id"),
+					"content_length", resp.ContentLength,
+				)
+			}
+			if err != nil {
+				attrs = append(attrs, "error", err)
+			}
+			logger.DebugContext(ctx, "Received response", attrs...)
+
+			return out, metadata, err
+		},
+	)
+
+	return func(stack *middleware.Stack) error {
+		return stack.Deserialize.Add(debugLogging, middleware.After)
+	}
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	Describe("NewLogger()", func() {
+		It("logs at info level in text format by default", func() {
+			logger := client.NewLogger(out, &config.S3Cli{})
+			logger.Debug("hidden")
+			logger.Info("File exists in bucket", "key", "some-key")
+
+			Expect(out.String()).NotTo(ContainSubstring("hidden"))
+			Expect(out.String()).To(ContainSubstring(`level=INFO msg="File exists in bucket" key=some-key`))
+		})
+
+		It("honors the configured level and format", func() {
+			logger := client.NewLogger(out, &config.S3Cli{LogLevel: config.LogLevelWarn, LogFormat: config.LogFormatJSON})
+			logger.Info("hidden")
+			logger.Warn("Aborting multipart upload failed", "upload_id", "some-upload")
+
+			var entry map[string]any
+			Expect(json.Unmarshal(out.Bytes(), &entry)).To(Succeed())
+			Expect(entry).To(HaveKeyWithValue("level", "WARN"))
+			Expect(entry).To(HaveKeyWithValue("msg", "Aborting multipart upload failed"))
+			Expect(entry).To(HaveKeyWithValue("upload_id", "some-upload"))
+		})
+
+		It("redacts credentials and signatures", func() {
+			logger := client.NewLogger(out, &config.S3Cli{})
+			logger.Info("Sending request",
+				"secret_access_key", "some-secret",
+				"url", "https://bucket.s3.amazonaws.com/key?X-Amz-Credential=AKID%2F20240101&X-Amz-Signature=abc123&X-Amz-Expires=60",
+				"error", errors.New(`Get "https://host/key?Signature=abc123&AWSAccessKeyId=AKID": EOF`),
+			)
+
+			Expect(out.String()).NotTo(ContainSubstring("some-secret"))
+			Expect(out.String()).NotTo(ContainSubstring("abc123"))
+			Expect(out.String()).NotTo(ContainSubstring("AKID"))
+			Expect(out.String()).To(ContainSubstring("X-Amz-Signature=REDACTED"))
+			Expect(out.String()).To(ContainSubstring("X-Amz-Expires=60"))
+		})
+	})
+
+	Describe("Redact()", func() {
+		It("hides the signature of an Authorization header", func() {
+			Expect(client.Redact("AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc123")).
+				To(Equal("AWS4-HMAC-SHA256 Credential=REDACTED, SignedHeaders=host, Signature=REDACTED"))
+		})
+	})
+
+	Describe("AddDebugLoggingMiddleware()", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Amz-Request-Id", "some-request-id")
+				w.WriteHeader(http.StatusNotFound)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		newS3Client := func(logConfig *config.S3Cli) *s3.Client {
+			return s3.NewFromConfig(aws.Config{
+				Region:      "us-east-1",
+				Credentials: aws.AnonymousCredentials{},
+			}, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(server.URL)
+				o.UsePathStyle = true
+				o.APIOptions = []func(*middleware.Stack) error{
+					client.AddDebugLoggingMiddleware(client.NewLogger(out, logConfig)),
+				}
+			})
+		}
+
+		It("logs a summary of each request and response at debug level", func() {
+			s3Client := newS3Client(&config.S3Cli{LogLevel: config.LogLevelDebug})
+			_, err := s3Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+				Bucket: aws.String("some-bucket"),
+				Key:    aws.String("some-key"),
+			})
+			Expect(err).To(HaveOccurred())
+
+			Expect(out.String()).To(MatchRegexp(`msg="Sending request" operation=HeadObject method=HEAD url=http://.*/some-bucket/some-key`))
+			Expect(out.String()).To(MatchRegexp(`msg="Received response" operation=HeadObject duration=.* status=404 request_id=some-request-id`))
+		})
+
+		It("logs nothing above debug level", func() {
+			s3Client := newS3Client(&config.S3Cli{})
+			_, err := s3Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+				Bucket: aws.String("some-bucket"),
+				Key:    aws.String("some-key"),
+			})
+			Expect(err).To(HaveOccurred())
+
+			Expect(out.String()).To(BeEmpty())
+		})
+	})
+})
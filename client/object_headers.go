@@ -0,0 +1,102 @@
+package client
+
+import (
+	"io"
+	"maps"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// objectHeaders are the content headers, user metadata, tags, storage class
+// and Object Lock retention an upload creates its object with
+type objectHeaders struct {
+	contentType        *string
+	cacheControl       *string
+	contentDisposition *string
+	contentEncoding    *string
+	metadata           map[string]string
+	// tagging is the URL encoded tag set of the x-amz-tagging header
+	tagging               *string
+	storageClass          types.StorageClass
+	objectLockMode        types.ObjectLockMode
+	objectLockRetainUntil *time.Time
+}
+
+// objectHeaders merges the options of an upload of src to dest over the
+// configured defaults. Without a content type it is detected from the
+// extension of dest or, if dest has none, of the uploaded file. The blobstore
+// picks its own default otherwise, binary/octet-stream on AWS.
+func (b *awsS3Client) objectHeaders(src io.Reader, dest string, opts PutOptions) (objectHeaders, error) {
+	cfg := b.s3cliConfig
+
+	retention, err := b.uploadRetention(opts.Retention)
+	if err != nil {
+		return objectHeaders{}, err
+	}
+
+	contentType := firstNonEmpty(opts.ContentType, cfg.ContentType, mime.TypeByExtension(path.Ext(dest)))
+	if file, ok := src.(*os.File); ok && contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(file.Name()))
+	}
+
+	headers := objectHeaders{
+		contentType:        nonEmptyParam(contentType),
+		cacheControl:       nonEmptyParam(firstNonEmpty(opts.CacheControl, cfg.CacheControl)),
+		contentDisposition: nonEmptyParam(firstNonEmpty(opts.ContentDisposition, cfg.ContentDisposition)),
+		contentEncoding:    nonEmptyParam(firstNonEmpty(opts.ContentEncoding, cfg.ContentEncoding)),
+		storageClass:       types.StorageClass(firstNonEmpty(opts.StorageClass, cfg.StorageClass)),
+	}
+	if retention != (Retention{}) {
+		headers.objectLockMode = types.ObjectLockMode(retention.Mode)
+		headers.objectLockRetainUntil = aws.Time(retention.RetainUntilDate)
+	}
+
+	metadata := maps.Clone(cfg.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	maps.Copy(metadata, opts.Metadata)
+	if opts.StoreDigests {
+		maps.Copy(metadata, digestMetadata(opts.Digests))
+	}
+	if len(metadata) > 0 {
+		headers.metadata = metadata
+	}
+
+	tags := url.Values{}
+	for _, tagSet := range []map[string]string{cfg.Tags, opts.Tags} {
+		for name, value := range tagSet {
+			tags.Set(name, value)
+		}
+	}
+	if len(tags) > 0 {
+		headers.tagging = aws.String(tags.Encode())
+	}
+
+	return headers, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func nonEmptyParam(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return aws.String(value)
+}
@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Object headers and tags", func() {
+	var server *httptest.Server
+	var requests []string
+	var headers http.Header
+	var taggingBody string
+	var requestsMutex sync.Mutex
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		requests = []string{}
+		headers = nil
+		taggingBody = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			switch {
+			case r.Method == http.MethodGet && r.URL.Query().Has("tagging"):
+				requests = append(requests, "GetObjectTagging")
+				fmt.Fprint(w, `<Tagging><TagSet>`+ //nolint:errcheck
+					`<Tag><Key>a</Key><Value>1</Value></Tag>`+
+					`<Tag><Key>b</Key><Value>2</Value></Tag>`+
+					`</TagSet></Tagging>`)
+			case r.Method == http.MethodPut && r.URL.Query().Has("tagging"):
+				requests = append(requests, "PutObjectTagging")
+				taggingBody = string(body)
+			case r.Method == http.MethodDelete && r.URL.Query().Has("tagging"):
+				requests = append(requests, "DeleteObjectTagging")
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method == http.MethodPut:
+				requests = append(requests, "PutObject")
+				headers = r.Header.Clone()
+				w.Header().Set("ETag", `"some-etag"`)
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config = newTestConfig()
+		blobstoreClient = newTestClient(server.URL, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("detects the content type from the extension", func() {
+		err := blobstoreClient.Put(bytes.NewReader([]byte("{}")), "some-key.json")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(headers.Get("Content-Type")).To(Equal("application/json"))
+		Expect(headers.Get("X-Amz-Tagging")).To(BeEmpty())
+	})
+
+	It("merges the options over the configured defaults", func() {
+		s3Config.ContentType = "text/plain"
+		s3Config.CacheControl = "max-age=60"
+		s3Config.Metadata = map[string]string{"owner": "config", "team": "config"}
+		s3Config.Tags = map[string]string{"env": "test", "tier": "config"}
+
+		err := blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key.json", func(o *client.PutOptions) {
+			o.ContentDisposition = "attachment"
+			o.ContentEncoding = "gzip"
+			o.Metadata = map[string]string{"owner": "option"}
+			o.Tags = map[string]string{"tier": "option & more"}
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(headers.Get("Content-Type")).To(Equal("text/plain"))
+		Expect(headers.Get("Cache-Control")).To(Equal("max-age=60"))
+		Expect(headers.Get("Content-Disposition")).To(Equal("attachment"))
+		Expect(headers.Get("Content-Encoding")).To(Equal("gzip"))
+		Expect(headers.Get("X-Amz-Meta-Owner")).To(Equal("option"))
+		Expect(headers.Get("X-Amz-Meta-Team")).To(Equal("config"))
+
+		tags, err := url.ParseQuery(headers.Get("X-Amz-Tagging"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tags).To(Equal(url.Values{"env": {"test"}, "tier": {"option & more"}}))
+	})
+
+	It("lists the tags", func() {
+		tags, err := blobstoreClient.Tags("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tags).To(Equal(map[string]string{"a": "1", "b": "2"}))
+	})
+
+	It("adds tags to the existing ones", func() {
+		err := blobstoreClient.Tag("some-key", map[string]string{"b": "3", "c": "4"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{"GetObjectTagging", "PutObjectTagging"}))
+		Expect(taggingBody).To(ContainSubstring(`<Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>b</Key><Value>3</Value></Tag><Tag><Key>c</Key><Value>4</Value></Tag>`))
+	})
+
+	It("removes the named tags", func() {
+		err := blobstoreClient.Untag("some-key", []string{"a"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{"GetObjectTagging", "PutObjectTagging"}))
+		Expect(taggingBody).To(ContainSubstring(`<TagSet><Tag><Key>b</Key><Value>2</Value></Tag></TagSet>`))
+	})
+
+	It("removes all tags without names", func() {
+		err := blobstoreClient.Untag("some-key", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{"DeleteObjectTagging"}))
+	})
+})
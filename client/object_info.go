@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// objectInfoRecorder fills an ObjectInfo from the first GetObject response of
+// a download
+type objectInfoRecorder struct {
+	manager.DownloadAPIClient //nolint:staticcheck
+	info                      *ObjectInfo
+	// ranged records the Content-Range, which the downloader otherwise
+	// only receives for the parts it requested itself
+	ranged bool
+	once   sync.Once
+}
+
+func (r *objectInfoRecorder) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	resp, err := r.DownloadAPIClient.GetObject(ctx, params, optFns...)
+	if err == nil {
+		r.once.Do(func() {
+			r.info.ETag = strings.Trim(aws.ToString(resp.ETag), `"`)
+			r.info.VersionID = aws.ToString(resp.VersionId)
+			r.info.Size = objectSize(resp)
+			if r.ranged {
+				r.info.ContentRange = aws.ToString(resp.ContentRange)
+			}
+		})
+	}
+
+	return resp, err
+}
+
+// objectSize is the size of the whole object, also for ranged responses
+func objectSize(resp *s3.GetObjectOutput) int64 {
+	if _, total, ok := strings.Cut(aws.ToString(resp.ContentRange), "/"); ok {
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			return size
+		}
+	}
+
+	return aws.ToInt64(resp.ContentLength)
+}
+
+// downloadClient is the client of the download getParams requests, recording
+// into result if set. Encrypted objects are refused unless they are decrypted,
+// compressed ones are stopped with a compressedBlobError if decompress is set.
+func (b *awsS3Client) downloadClient(result *ObjectInfo, getParams *s3.GetObjectInput, decompress bool) manager.DownloadAPIClient { //nolint:staticcheck
+	var client manager.DownloadAPIClient = b.s3Client //nolint:staticcheck
+	if !b.clientSideEncryptionConfigured() {
+		client = &plaintextGuard{DownloadAPIClient: client}
+	}
+	if decompress {
+		client = &compressionGuard{DownloadAPIClient: client}
+	}
+	if result == nil {
+		return client
+	}
+
+	return &objectInfoRecorder{DownloadAPIClient: client, info: result, ranged: getParams.Range != nil}
+}
+
+// startObjectInfo resets result, if set, to describe key in bucket
+func startObjectInfo(result *ObjectInfo, bucket string, key string) {
+	if result != nil {
+		*result = ObjectInfo{Key: key, Bucket: bucket}
+	}
+}
+
+// countingReader counts the bytes of an upload which size is not known up front
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// seekerSize returns the number of bytes from the current position to the end
+func seekerSize(s io.Seeker) (int64, error) {
+	current, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = s.Seek(current, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return end - current, nil
+}
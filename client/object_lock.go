@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// Retention returns the Object Lock retention of key, the zero Retention if
+// it has none
+func (b *awsS3Client) Retention(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (Retention, error) {
+	opts := objectLockOptions(optFns)
+
+	output, err := b.s3Client.GetObjectRetention(ctx, &s3.GetObjectRetentionInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+	})
+	if noObjectLockConfiguration(err) {
+		return Retention{}, nil
+	}
+	if err != nil {
+		return Retention{}, err
+	}
+
+	if output.Retention == nil {
+		return Retention{}, nil
+	}
+	return Retention{
+		Mode:            string(output.Retention.Mode),
+		RetainUntilDate: aws.ToTime(output.Retention.RetainUntilDate),
+	}, nil
+}
+
+// SetRetention replaces the Object Lock retention of key. A retention can
+// only be extended, unless it is in GOVERNANCE mode and BypassGovernanceRetention
+// is set, which also allows removing it with the zero Retention.
+func (b *awsS3Client) SetRetention(ctx context.Context, key string, retention Retention, optFns ...func(*ObjectLockOptions)) error {
+	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	opts := objectLockOptions(optFns)
+	input := &s3.PutObjectRetentionInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+		Retention: &types.ObjectLockRetention{},
+	}
+	if retention != (Retention{}) {
+		if err := validateObjectLockMode(retention.Mode); err != nil {
+			return err
+		}
+		if retention.RetainUntilDate.IsZero() {
+			return &Error{Kind: ErrInvalidArgument, Err: errors.New("a retention requires a retain-until date")}
+		}
+		input.Retention.Mode = types.ObjectLockRetentionMode(retention.Mode)
+		input.Retention.RetainUntilDate = aws.Time(retention.RetainUntilDate)
+	}
+	if opts.BypassGovernanceRetention {
+		input.BypassGovernanceRetention = aws.Bool(true)
+	}
+
+	if _, err := b.s3Client.PutObjectRetention(ctx, input); err != nil {
+		return err
+	}
+
+	b.logger.InfoContext(ctx, "Set retention", "key", key, "bucket", b.s3cliConfig.BucketName, "mode", retention.Mode, "retain_until", retention.RetainUntilDate)
+	return nil
+}
+
+// LegalHold tells whether key is under an Object Lock legal hold
+func (b *awsS3Client) LegalHold(ctx context.Context, key string, optFns ...func(*ObjectLockOptions)) (bool, error) {
+	opts := objectLockOptions(optFns)
+
+	output, err := b.s3Client.GetObjectLegalHold(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+	})
+	if noObjectLockConfiguration(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return output.LegalHold != nil && output.LegalHold.Status == types.ObjectLockLegalHoldStatusOn, nil
+}
+
+// SetLegalHold places or lifts the Object Lock legal hold of key, which
+// protects it regardless of its retention until it is lifted
+func (b *awsS3Client) SetLegalHold(ctx context.Context, key string, on bool, optFns ...func(*ObjectLockOptions)) error {
+	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	opts := objectLockOptions(optFns)
+	status := types.ObjectLockLegalHoldStatusOff
+	if on {
+		status = types.ObjectLockLegalHoldStatusOn
+	}
+
+	_, err := b.s3Client.PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+		LegalHold: &types.ObjectLockLegalHold{Status: status},
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.InfoContext(ctx, "Set legal hold", "key", key, "bucket", b.s3cliConfig.BucketName, "legal_hold", status)
+	return nil
+}
+
+// uploadRetention merges the retention of an upload over object_lock_mode and
+// object_lock_retain_days
+func (b *awsS3Client) uploadRetention(retention Retention) (Retention, error) {
+	cfg := b.s3cliConfig
+
+	mode := firstNonEmpty(retention.Mode, cfg.ObjectLockMode)
+	retainUntil := retention.RetainUntilDate
+	if retainUntil.IsZero() && cfg.ObjectLockRetainDays > 0 {
+		retainUntil = time.Now().AddDate(0, 0, cfg.ObjectLockRetainDays)
+	}
+
+	switch {
+	case mode == "" && retainUntil.IsZero():
+		return Retention{}, nil
+	case mode == "":
+		return Retention{}, &Error{Kind: ErrInvalidArgument, Err: errors.New("a retain-until date requires an object lock mode")}
+	case retainUntil.IsZero():
+		return Retention{}, &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("object lock mode %s requires a retain-until date", mode)}
+	}
+	if err := validateObjectLockMode(mode); err != nil {
+		return Retention{}, err
+	}
+
+	return Retention{Mode: mode, RetainUntilDate: retainUntil}, nil
+}
+
+func validateObjectLockMode(mode string) error {
+	switch mode {
+	case config.ObjectLockModeGovernance, config.ObjectLockModeCompliance:
+		return nil
+	default:
+		return &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("invalid object lock mode '%s', must be GOVERNANCE or COMPLIANCE", mode)}
+	}
+}
+
+// noObjectLockConfiguration tells an object without a retention or legal
+// hold from a failure to read them
+func noObjectLockConfiguration(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchObjectLockConfiguration"
+}
+
+// lockedDeleteError tells which blob Object Lock refused to delete
+func lockedDeleteError(dest string, err error) error {
+	if errorKind(err) == ErrObjectLocked {
+		return fmt.Errorf("blob '%s' is protected by a retention period or legal hold: %w", dest, err)
+	}
+
+	return err
+}
+
+func objectLockOptions(optFns []func(*ObjectLockOptions)) ObjectLockOptions {
+	var opts ObjectLockOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	return opts
+}
@@ -0,0 +1,203 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Object Lock", func() {
+	var server *httptest.Server
+	var requests map[string]*http.Request
+	var bodies map[string]string
+	var responseStatus int
+	var response string
+	var requestsMutex sync.Mutex
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		requests = map[string]*http.Request{}
+		bodies = map[string]string{}
+		responseStatus = http.StatusOK
+		response = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			body, _ := io.ReadAll(r.Body) //nolint:errcheck
+			operation := r.Method
+			switch {
+			case r.URL.Query().Has("retention"):
+				operation += " retention"
+			case r.URL.Query().Has("legal-hold"):
+				operation += " legal-hold"
+			}
+			requests[operation], bodies[operation] = r, string(body)
+
+			if r.Method == http.MethodPut && operation == http.MethodPut {
+				w.Header().Set("ETag", `"some-etag"`)
+				return
+			}
+			w.WriteHeader(responseStatus)
+			w.Write([]byte(response)) //nolint:errcheck
+		}))
+
+		s3Client := newTestS3Client(server.URL, func(c *aws.Config) {
+			// As configured for blobstores without additional checksums
+			c.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			c.RetryMaxAttempts = 1
+		})
+
+		s3Config = newTestConfig()
+		blobstoreClient = client.New(s3Client, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("uploads with the configured retention and a checksum", func() {
+		s3Config.ObjectLockMode = config.ObjectLockModeGovernance
+		s3Config.ObjectLockRetainDays = 30
+
+		Expect(blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key")).To(Succeed())
+
+		put := requests[http.MethodPut]
+		Expect(put.Header.Get("X-Amz-Object-Lock-Mode")).To(Equal("GOVERNANCE"))
+		retainUntil, err := time.Parse(time.RFC3339, put.Header.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retainUntil).To(BeTemporally("~", time.Now().AddDate(0, 0, 30), time.Minute))
+		Expect(put.Header.Get("X-Amz-Checksum-Crc32") + put.Header.Get("X-Amz-Trailer")).ToNot(BeEmpty())
+	})
+
+	It("takes the mode of an upload from the configuration", func() {
+		s3Config.ObjectLockMode = config.ObjectLockModeCompliance
+		retainUntil := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+		Expect(blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.Retention.RetainUntilDate = retainUntil
+		})).To(Succeed())
+
+		put := requests[http.MethodPut]
+		Expect(put.Header.Get("X-Amz-Object-Lock-Mode")).To(Equal("COMPLIANCE"))
+		Expect(put.Header.Get("X-Amz-Object-Lock-Retain-Until-Date")).To(Equal("2030-01-02T00:00:00Z"))
+	})
+
+	It("refuses an upload retention without a mode", func() {
+		err := blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.Retention.RetainUntilDate = time.Now().Add(time.Hour)
+		})
+		Expect(err).To(MatchError("a retain-until date requires an object lock mode"))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("reads the retention of a blob", func() {
+		response = `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>2030-01-02T00:00:00.000Z</RetainUntilDate></Retention>`
+
+		retention, err := blobstoreClient.Retention("some-key", func(o *client.ObjectLockOptions) {
+			o.VersionID = "some-version"
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retention.Mode).To(Equal("GOVERNANCE"))
+		Expect(retention.RetainUntilDate.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+		Expect(requests[http.MethodGet+" retention"].URL.Query().Get("versionId")).To(Equal("some-version"))
+	})
+
+	It("reports no retention for a blob without one", func() {
+		responseStatus = http.StatusNotFound
+		response = `<Error><Code>NoSuchObjectLockConfiguration</Code><Message>The specified object does not have a ObjectLock configuration</Message></Error>`
+
+		retention, err := blobstoreClient.Retention("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retention).To(Equal(client.Retention{}))
+
+		on, err := blobstoreClient.LegalHold("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(on).To(BeFalse())
+	})
+
+	It("extends the retention of a blob", func() {
+		Expect(blobstoreClient.SetRetention("some-key", client.Retention{
+			Mode:            "COMPLIANCE",
+			RetainUntilDate: time.Date(2031, 1, 2, 0, 0, 0, 0, time.UTC),
+		})).To(Succeed())
+
+		put := requests[http.MethodPut+" retention"]
+		Expect(put.URL.Path).To(Equal("/some-bucket/some-folder/some-key"))
+		Expect(bodies[http.MethodPut+" retention"]).To(ContainSubstring("<Mode>COMPLIANCE</Mode>"))
+		Expect(bodies[http.MethodPut+" retention"]).To(ContainSubstring("<RetainUntilDate>2031-01-02T00:00:00Z</RetainUntilDate>"))
+		Expect(put.Header.Get("X-Amz-Bypass-Governance-Retention")).To(BeEmpty())
+	})
+
+	It("removes a GOVERNANCE retention bypassing it", func() {
+		Expect(blobstoreClient.SetRetention("some-key", client.Retention{}, func(o *client.ObjectLockOptions) {
+			o.BypassGovernanceRetention = true
+		})).To(Succeed())
+
+		put := requests[http.MethodPut+" retention"]
+		Expect(put.Header.Get("X-Amz-Bypass-Governance-Retention")).To(Equal("true"))
+		Expect(bodies[http.MethodPut+" retention"]).ToNot(ContainSubstring("<Mode>"))
+	})
+
+	It("rejects unknown modes", func() {
+		err := blobstoreClient.SetRetention("some-key", client.Retention{Mode: "forever", RetainUntilDate: time.Now()})
+		Expect(err).To(MatchError("invalid object lock mode 'forever', must be GOVERNANCE or COMPLIANCE"))
+	})
+
+	It("reads and sets the legal hold of a blob", func() {
+		response = `<LegalHold><Status>ON</Status></LegalHold>`
+		on, err := blobstoreClient.LegalHold("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(on).To(BeTrue())
+
+		response = ""
+		Expect(blobstoreClient.SetLegalHold("some-key", false)).To(Succeed())
+		Expect(bodies[http.MethodPut+" legal-hold"]).To(ContainSubstring("<Status>OFF</Status>"))
+	})
+
+	It("refuses to change retentions and legal holds in read only mode", func() {
+		s3Config.CredentialsSource = config.NoneCredentialsSource
+
+		Expect(errors.Is(blobstoreClient.SetRetention("some-key", client.Retention{}), client.ErrReadOnlyMode)).To(BeTrue())
+		Expect(errors.Is(blobstoreClient.SetLegalHold("some-key", true), client.ErrReadOnlyMode)).To(BeTrue())
+	})
+
+	It("tells a delete denied by Object Lock from missing permissions", func() {
+		responseStatus = http.StatusForbidden
+		response = `<Error><Code>AccessDenied</Code><Message>Access Denied because object protected by object lock.</Message></Error>`
+
+		err := blobstoreClient.Delete("some-key", func(o *client.DeleteOptions) {
+			o.VersionID = "some-version"
+		})
+		Expect(errors.Is(err, client.ErrObjectLocked)).To(BeTrue())
+		Expect(errors.Is(err, client.ErrAccessDenied)).To(BeFalse())
+		Expect(err).To(MatchError(ContainSubstring("blob 'some-key' is protected by a retention period or legal hold")))
+
+		response = `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`
+		err = blobstoreClient.Delete("some-key")
+		Expect(errors.Is(err, client.ErrAccessDenied)).To(BeTrue())
+	})
+
+	It("bypasses GOVERNANCE retentions on delete", func() {
+		responseStatus = http.StatusNoContent
+
+		Expect(blobstoreClient.Delete("some-key", func(o *client.DeleteOptions) {
+			o.VersionID = "some-version"
+			o.BypassGovernanceRetention = true
+		})).To(Succeed())
+		Expect(requests[http.MethodDelete].Header.Get("X-Amz-Bypass-Governance-Retention")).To(Equal("true"))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -17,13 +18,21 @@ type openstackSwiftS3Client struct {
 	s3cliConfig *config.S3Cli
 }
 
-func (c *openstackSwiftS3Client) Sign(objectID string, action string, expiration time.Duration) (string, error) {
+func (c *openstackSwiftS3Client) Sign(objectID string, action string, expiration time.Duration, optFns ...func(*SignOptions)) (string, error) {
+	var opts SignOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+	if opts.VersionID != "" {
+		return "", &Error{Kind: ErrInvalidArgument, Err: errors.New("signing object versions is not supported for Openstack Swift")}
+	}
+
 	action = strings.ToUpper(action)
 	switch action {
 	case "GET", "PUT":
 		return c.signedURL(action, objectID, expiration)
 	default:
-		return "", fmt.Errorf("action not implemented: %s", action)
+		return "", &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("action not implemented: %s", action)}
 	}
 }
 
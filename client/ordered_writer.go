@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// orderedWriterAt adapts a sequential io.Writer (e.g. stdout) to the io.WriterAt
+// the parallel downloader writes to. Chunks arriving ahead of the current write
+// position are held in memory until the gap before them has been filled. Writes
+// landing more than window bytes ahead block, which bounds the memory used to
+// roughly the window, i.e. concurrency * part size.
+type orderedWriterAt struct {
+	w      io.Writer
+	window int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	offset  int64 // next byte to be written to w
+	pending map[int64][]byte
+	err     error
+}
+
+func newOrderedWriterAt(w io.Writer, window int64) *orderedWriterAt {
+	o := &orderedWriterAt{
+		w:       w,
+		window:  window,
+		pending: map[int64][]byte{},
+	}
+	o.cond = sync.NewCond(&o.mu)
+
+	return o
+}
+
+func (o *orderedWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.err == nil && off > o.offset && off+int64(len(p)) > o.offset+o.window {
+		o.cond.Wait()
+	}
+	if o.err != nil {
+		return 0, o.err
+	}
+
+	if off != o.offset {
+		o.pending[off] = bytes.Clone(p)
+		return len(p), nil
+	}
+
+	chunk := p
+	for {
+		if _, err := o.w.Write(chunk); err != nil {
+			o.failLocked(err)
+			return 0, err
+		}
+		o.offset += int64(len(chunk))
+
+		var ok bool
+		if chunk, ok = o.pending[o.offset]; !ok {
+			break
+		}
+		delete(o.pending, o.offset)
+	}
+	o.cond.Broadcast()
+
+	return len(p), nil
+}
+
+// fail releases writers waiting for a gap that will never be filled
+func (o *orderedWriterAt) fail(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.failLocked(err)
+}
+
+func (o *orderedWriterAt) failLocked(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+	o.cond.Broadcast()
+}
+
+// orderedDownloadClient reports every failed part to the orderedWriterAt, so
+// that writers blocked behind the failed part give up instead of deadlocking
+// the downloader.
+type orderedDownloadClient struct {
+	manager.DownloadAPIClient //nolint:staticcheck
+	writer                    *orderedWriterAt
+}
+
+func (c *orderedDownloadClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	resp, err := c.DownloadAPIClient.GetObject(ctx, params, optFns...)
+	if err != nil {
+		c.writer.fail(err)
+		return nil, err
+	}
+
+	resp.Body = &failingReadCloser{ReadCloser: resp.Body, writer: c.writer}
+	return resp, nil
+}
+
+type failingReadCloser struct {
+	io.ReadCloser
+	writer *orderedWriterAt
+}
+
+func (r *failingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		r.writer.fail(err)
+	}
+
+	return n, err
+}
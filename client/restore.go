@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// Restore requests a temporary copy of an archived object, e.g. in the
+// GLACIER or DEEP_ARCHIVE storage class, which can be read once RestoreStatus
+// reports RestoreStateRestored. A restore already in progress is not an error,
+// restoring a restored object again extends how long its copy is kept.
+func (b *awsS3Client) Restore(ctx context.Context, key string, optFns ...func(*RestoreOptions)) error {
+	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	opts := restoreOptions(optFns)
+	request := &types.RestoreRequest{}
+	if opts.Days > 0 {
+		request.Days = aws.Int32(opts.Days)
+	}
+	switch tier := types.Tier(opts.Tier); tier {
+	case "":
+	case types.TierStandard, types.TierBulk, types.TierExpedited:
+		request.GlacierJobParameters = &types.GlacierJobParameters{Tier: tier}
+	default:
+		return &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf("invalid tier '%s', must be Standard, Bulk or Expedited", opts.Tier)}
+	}
+
+	_, err := b.s3Client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket:         aws.String(b.s3cliConfig.BucketName),
+		Key:            b.key(key),
+		VersionId:      versionIDParam(opts.VersionID),
+		RestoreRequest: request,
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "RestoreAlreadyInProgress":
+			b.logger.InfoContext(ctx, "Restore already in progress", "key", key, "bucket", b.s3cliConfig.BucketName)
+			return nil
+		case "InvalidObjectState":
+			// Not to be taken for the ErrArchived of reading an archived object
+			return fmt.Errorf("blob '%s' is not archived and can be read as it is: %s", key, apiErr.ErrorMessage())
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	b.logger.InfoContext(ctx, "Requested restore", "key", key, "bucket", b.s3cliConfig.BucketName, "days", opts.Days, "tier", opts.Tier)
+	return nil
+}
+
+// RestoreStatus tells whether key is archived and how far its restore got,
+// from the storage class and the x-amz-restore header HeadObject reports
+func (b *awsS3Client) RestoreStatus(ctx context.Context, key string, optFns ...func(*RestoreOptions)) (RestoreStatus, error) {
+	opts := restoreOptions(optFns)
+
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(key),
+		VersionId:            versionIDParam(opts.VersionID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		return RestoreStatus{}, err
+	}
+
+	status := RestoreStatus{
+		Key:           key,
+		Bucket:        b.s3cliConfig.BucketName,
+		VersionID:     aws.ToString(head.VersionId),
+		StorageClass:  string(head.StorageClass),
+		ArchiveStatus: string(head.ArchiveStatus),
+		State:         RestoreStateNotArchived,
+	}
+	restore := aws.ToString(head.Restore)
+	switch {
+	case strings.Contains(restore, `ongoing-request="true"`):
+		status.State = RestoreStateRestoring
+	case strings.Contains(restore, `ongoing-request="false"`):
+		status.State = RestoreStateRestored
+		// e.g. ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+		if _, expiry, found := strings.Cut(restore, `expiry-date="`); found {
+			expiry, _, _ = strings.Cut(expiry, `"`)
+			if expiryDate, err := http.ParseTime(expiry); err == nil {
+				status.ExpiryDate = expiryDate
+			}
+		}
+	case head.StorageClass == types.StorageClassGlacier || head.StorageClass == types.StorageClassDeepArchive || head.ArchiveStatus != "":
+		status.State = RestoreStateArchived
+	}
+
+	return status, nil
+}
+
+func restoreOptions(optFns []func(*RestoreOptions)) RestoreOptions {
+	var opts RestoreOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	return opts
+}
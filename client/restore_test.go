@@ -0,0 +1,174 @@
+package client_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage classes and restores", func() {
+	var server *httptest.Server
+	var requests map[string]*http.Request
+	var bodies map[string]string
+	var headHeaders http.Header
+	var restoreStatus int
+	var restoreResponse string
+	var requestsMutex sync.Mutex
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		requests = map[string]*http.Request{}
+		bodies = map[string]string{}
+		headHeaders = http.Header{"Etag": {`"some-etag"`}, "Content-Length": {"7"}}
+		restoreStatus = http.StatusAccepted
+		restoreResponse = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			body, _ := io.ReadAll(r.Body) //nolint:errcheck
+			switch {
+			case r.Method == http.MethodPost && r.URL.Query().Has("restore"):
+				requests["RestoreObject"], bodies["RestoreObject"] = r, string(body)
+				w.WriteHeader(restoreStatus)
+				w.Write([]byte(restoreResponse)) //nolint:errcheck
+			case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+				requests["CopyObject"] = r
+				w.Write([]byte(`<CopyObjectResult><ETag>"some-etag"</ETag></CopyObjectResult>`)) //nolint:errcheck
+			case r.Method == http.MethodPut:
+				requests["PutObject"] = r
+				w.Header().Set("ETag", `"some-etag"`)
+			case r.Method == http.MethodDelete:
+				requests["DeleteObject"] = r
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method == http.MethodHead:
+				for name, values := range headHeaders {
+					w.Header()[name] = values
+				}
+			case r.Method == http.MethodGet:
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`<Error><Code>InvalidObjectState</Code><Message>The operation is not valid for the object's storage class</Message><StorageClass>GLACIER</StorageClass></Error>`)) //nolint:errcheck
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config = newTestConfig()
+		blobstoreClient = newTestClient(server.URL, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("uploads with the configured storage class", func() {
+		s3Config.StorageClass = "STANDARD_IA"
+		Expect(blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key")).To(Succeed())
+		Expect(requests["PutObject"].Header.Get("X-Amz-Storage-Class")).To(Equal("STANDARD_IA"))
+
+		Expect(blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key", func(o *client.PutOptions) {
+			o.StorageClass = "GLACIER"
+		})).To(Succeed())
+		Expect(requests["PutObject"].Header.Get("X-Amz-Storage-Class")).To(Equal("GLACIER"))
+	})
+
+	It("changes the storage class by copying a blob onto itself", func() {
+		Expect(blobstoreClient.Copy("some-key", "some-key", func(o *client.CopyOptions) {
+			o.StorageClass = "DEEP_ARCHIVE"
+		})).To(Succeed())
+		Expect(requests["CopyObject"].Header.Get("X-Amz-Storage-Class")).To(Equal("DEEP_ARCHIVE"))
+	})
+
+	It("refuses to move a blob onto itself to change its storage class", func() {
+		err := blobstoreClient.Move("some-key", "some-key", func(o *client.CopyOptions) {
+			o.StorageClass = "GLACIER"
+		})
+		Expect(err).To(MatchError("cannot move 'some-key' onto itself"))
+		Expect(errors.Is(err, client.ErrInvalidArgument)).To(BeTrue())
+		Expect(requests).NotTo(HaveKey("CopyObject"))
+		Expect(requests).NotTo(HaveKey("DeleteObject"))
+
+		Expect(blobstoreClient.Exists("some-key")).To(BeTrue())
+	})
+
+	It("requests a restore for the given days and tier", func() {
+		Expect(blobstoreClient.Restore("some-key", func(o *client.RestoreOptions) {
+			o.Days = 3
+			o.Tier = "Bulk"
+		})).To(Succeed())
+
+		Expect(requests["RestoreObject"].URL.Path).To(Equal("/some-bucket/some-folder/some-key"))
+		Expect(bodies["RestoreObject"]).To(ContainSubstring("<Days>3</Days>"))
+		Expect(bodies["RestoreObject"]).To(ContainSubstring("<Tier>Bulk</Tier>"))
+	})
+
+	It("rejects unknown tiers", func() {
+		err := blobstoreClient.Restore("some-key", func(o *client.RestoreOptions) {
+			o.Tier = "Fast"
+		})
+		Expect(err).To(MatchError("invalid tier 'Fast', must be Standard, Bulk or Expedited"))
+	})
+
+	It("accepts a restore already in progress", func() {
+		restoreStatus = http.StatusConflict
+		restoreResponse = `<Error><Code>RestoreAlreadyInProgress</Code><Message>Object restore is already in progress</Message></Error>`
+
+		Expect(blobstoreClient.Restore("some-key")).To(Succeed())
+	})
+
+	It("does not take a blob which is not archived for an archived one", func() {
+		restoreStatus = http.StatusForbidden
+		restoreResponse = `<Error><Code>InvalidObjectState</Code><Message>Restore is not allowed for the object's current storage class</Message></Error>`
+
+		err := blobstoreClient.Restore("some-key")
+		Expect(err).To(MatchError(ContainSubstring("is not archived")))
+		Expect(errors.Is(err, client.ErrArchived)).To(BeFalse())
+	})
+
+	DescribeTable("reports the state of the restore",
+		func(storageClass string, restore string, state string, expiry time.Time) {
+			headHeaders.Set("X-Amz-Storage-Class", storageClass)
+			if restore != "" {
+				headHeaders.Set("X-Amz-Restore", restore)
+			}
+
+			status, err := blobstoreClient.RestoreStatus("some-key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.StorageClass).To(Equal(storageClass))
+			Expect(status.State).To(Equal(state))
+			Expect(status.ExpiryDate.Equal(expiry)).To(BeTrue())
+		},
+		Entry("of a blob which is not archived", "STANDARD_IA", "", client.RestoreStateNotArchived, time.Time{}),
+		Entry("of an archived blob", "GLACIER", "", client.RestoreStateArchived, time.Time{}),
+		Entry("of a blob being restored", "DEEP_ARCHIVE", `ongoing-request="true"`, client.RestoreStateRestoring, time.Time{}),
+		Entry("of a restored blob", "GLACIER", `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`,
+			client.RestoreStateRestored, time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)),
+	)
+
+	It("reports Intelligent-Tiering archive tiers as archived", func() {
+		headHeaders.Set("X-Amz-Storage-Class", "INTELLIGENT_TIERING")
+		headHeaders.Set("X-Amz-Archive-Status", "DEEP_ARCHIVE_ACCESS")
+
+		status, err := blobstoreClient.RestoreStatus("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.ArchiveStatus).To(Equal("DEEP_ARCHIVE_ACCESS"))
+		Expect(status.State).To(Equal(client.RestoreStateArchived))
+	})
+
+	It("fails to get an archived blob with a clear error", func() {
+		err := blobstoreClient.GetStream("some-key", io.Discard)
+		Expect(errors.Is(err, client.ErrArchived)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("blob is archived in GLACIER, restore required")))
+	})
+})
@@ -0,0 +1,369 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"golang.org/x/sync/errgroup"
+)
+
+// uploadState records an in-progress multipart upload. It is written to
+// upload_state_dir after every completed part, so that a put of the same file
+// to the same key interrupted by a crash or reboot only uploads the missing
+// parts when it is run again.
+type uploadState struct {
+	Bucket            string         `json:"bucket"`
+	Key               string         `json:"key"`
+	Source            string         `json:"source"`
+	Size              int64          `json:"size"`
+	ModTime           time.Time      `json:"mod_time"`
+	PartSize          int64          `json:"part_size"`
+	ChecksumAlgorithm string         `json:"checksum_algorithm,omitempty"`
+	UploadID          string         `json:"upload_id"`
+	Parts             []uploadedPart `json:"parts"`
+}
+
+type uploadedPart struct {
+	PartNumber    int32  `json:"part_number"`
+	ETag          string `json:"etag"`
+	ChecksumCRC32 string `json:"checksum_crc32,omitempty"`
+}
+
+// resumableSource returns the file behind src if the upload can be resumed,
+// i.e. resume mode is enabled and src is a regular file spanning several parts
+func (b *awsS3Client) resumableSource(src io.Reader) (*os.File, os.FileInfo, bool) {
+	cfg := b.s3cliConfig
+	if cfg.UploadStateDir == "" || !cfg.MultipartUpload {
+		return nil, nil, false
+	}
+
+	file, ok := src.(*os.File)
+	if !ok {
+		return nil, nil, false
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() <= b.uploadPartSize(info.Size()) {
+		return nil, nil, false
+	}
+
+	return file, info, true
+}
+
+// uploadPartSize mirrors the uploader: the configured part size, grown if the
+// object would otherwise need more parts than S3 allows
+func (b *awsS3Client) uploadPartSize(size int64) int64 {
+	partSize := defaultTransferPartSize
+	if b.s3cliConfig.UploadPartSize > 0 {
+		partSize = b.s3cliConfig.UploadPartSize
+	}
+	if size/partSize >= maxUploadParts {
+		partSize = size/maxUploadParts + 1
+	}
+
+	return partSize
+}
+
+func (b *awsS3Client) putResumable(ctx context.Context, file *os.File, info os.FileInfo, dest string, opts PutOptions, conditions writeConditions) error {
+	cfg := b.s3cliConfig
+
+	source, err := filepath.Abs(file.Name())
+	if err != nil {
+		return err
+	}
+
+	state := &uploadState{
+		Bucket:   cfg.BucketName,
+		Key:      aws.ToString(b.key(dest)),
+		Source:   source,
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UTC(),
+		PartSize: b.uploadPartSize(info.Size()),
+	}
+	if !cfg.ShouldDisableUploaderRequestChecksumCalculation() {
+		state.ChecksumAlgorithm = string(types.ChecksumAlgorithmCrc32)
+	}
+
+	statePath := filepath.Join(cfg.UploadStateDir, state.fileName())
+	done, err := b.resumeUploadState(ctx, statePath, state)
+	if err != nil {
+		return err
+	}
+
+	sse := b.sseCustomerKey()
+	if state.UploadID == "" {
+		headers, err := b.objectHeaders(file, dest, opts)
+		if err != nil {
+			return err
+		}
+		createParams := &s3.CreateMultipartUploadInput{
+			Bucket:                    aws.String(state.Bucket),
+			Key:                       aws.String(state.Key),
+			ChecksumAlgorithm:         types.ChecksumAlgorithm(state.ChecksumAlgorithm),
+			ContentType:               headers.contentType,
+			CacheControl:              headers.cacheControl,
+			ContentDisposition:        headers.contentDisposition,
+			ContentEncoding:           headers.contentEncoding,
+			Metadata:                  headers.metadata,
+			Tagging:                   headers.tagging,
+			StorageClass:              headers.storageClass,
+			ObjectLockMode:            headers.objectLockMode,
+			ObjectLockRetainUntilDate: headers.objectLockRetainUntil,
+			SSECustomerAlgorithm:      sse.algorithm,
+			SSECustomerKey:            sse.key,
+			SSECustomerKeyMD5:         sse.keyMD5,
+		}
+		if cfg.ServerSideEncryption != "" {
+			createParams.ServerSideEncryption = types.ServerSideEncryption(cfg.ServerSideEncryption)
+		}
+		if cfg.SSEKMSKeyID != "" {
+			createParams.SSEKMSKeyId = aws.String(cfg.SSEKMSKeyID)
+		}
+
+		upload, err := b.s3Client.CreateMultipartUpload(ctx, createParams)
+		if err != nil {
+			return err
+		}
+		state.UploadID = aws.ToString(upload.UploadId)
+
+		if err = state.save(statePath); err != nil {
+			return err
+		}
+	} else {
+		b.logger.InfoContext(ctx, "Resuming upload", "source", source, "key", dest, "uploaded_parts", len(done))
+	}
+
+	concurrency := defaultTransferConcurrency
+	if cfg.UploadConcurrency > 0 {
+		concurrency = cfg.UploadConcurrency
+	}
+
+	var stateMutex sync.Mutex
+	partCount := (state.Size + state.PartSize - 1) / state.PartSize
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	for i := int64(0); i < partCount; i++ {
+		partNumber := int32(i + 1)
+		if _, ok := done[partNumber]; ok {
+			continue
+		}
+		offset := i * state.PartSize
+		length := min(state.PartSize, state.Size-offset)
+
+		group.Go(func() error {
+			part, err := b.s3Client.UploadPart(groupCtx, &s3.UploadPartInput{
+				Bucket:               aws.String(state.Bucket),
+				Key:                  aws.String(state.Key),
+				UploadId:             aws.String(state.UploadID),
+				PartNumber:           aws.Int32(partNumber),
+				Body:                 io.NewSectionReader(file, offset, length),
+				ChecksumAlgorithm:    types.ChecksumAlgorithm(state.ChecksumAlgorithm),
+				SSECustomerAlgorithm: sse.algorithm,
+				SSECustomerKey:       sse.key,
+				SSECustomerKeyMD5:    sse.keyMD5,
+			})
+			if err != nil {
+				return fmt.Errorf("uploading part %d: %w", partNumber, err)
+			}
+
+			stateMutex.Lock()
+			defer stateMutex.Unlock()
+
+			state.Parts = append(state.Parts, uploadedPart{
+				PartNumber:    partNumber,
+				ETag:          aws.ToString(part.ETag),
+				ChecksumCRC32: aws.ToString(part.ChecksumCRC32),
+			})
+			return state.save(statePath)
+		})
+	}
+
+	if err = group.Wait(); err != nil {
+		b.logger.WarnContext(ctx, "Upload interrupted, run the same put again to resume it", "key", dest, "error", err)
+		return fmt.Errorf("upload failure: %w", err)
+	}
+
+	sort.Slice(state.Parts, func(i, j int) bool { return state.Parts[i].PartNumber < state.Parts[j].PartNumber })
+	completedParts := make([]types.CompletedPart, 0, len(state.Parts))
+	for _, part := range state.Parts {
+		completedPart := types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		}
+		if part.ChecksumCRC32 != "" {
+			completedPart.ChecksumCRC32 = aws.String(part.ChecksumCRC32)
+		}
+		completedParts = append(completedParts, completedPart)
+	}
+
+	completeResult, err := b.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:               aws.String(state.Bucket),
+		Key:                  aws.String(state.Key),
+		UploadId:             aws.String(state.UploadID),
+		MultipartUpload:      &types.CompletedMultipartUpload{Parts: completedParts},
+		IfNoneMatch:          conditions.ifNoneMatch,
+		IfMatch:              conditions.ifMatch,
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		// Resuming would fail the same way
+		if errors.Is(errorKind(err), ErrPreconditionFailed) {
+			b.abortMultipartUpload(ctx, state.Bucket, aws.String(state.Key), aws.String(state.UploadID))
+			os.Remove(statePath) //nolint:errcheck
+		}
+		return fmt.Errorf("upload failure: %w", err)
+	}
+
+	if err = os.Remove(statePath); err != nil {
+		b.logger.WarnContext(ctx, "Removing upload state failed", "error", err)
+	}
+
+	if opts.Result != nil {
+		opts.Result.Size = state.Size
+		opts.Result.ETag = strings.Trim(aws.ToString(completeResult.ETag), `"`)
+		opts.Result.VersionID = aws.ToString(completeResult.VersionId)
+		opts.Result.Location = aws.ToString(completeResult.Location)
+	}
+
+	b.logger.InfoContext(ctx, "Successfully uploaded file", "key", dest, "location", aws.ToString(completeResult.Location))
+	return nil
+}
+
+// resumeUploadState loads a previous state for the same upload into state and
+// returns the parts S3 already holds for it. Without a usable previous state,
+// state.UploadID stays empty and a new multipart upload has to be started.
+func (b *awsS3Client) resumeUploadState(ctx context.Context, statePath string, state *uploadState) (map[int32]uploadedPart, error) {
+	previous, err := loadUploadState(statePath, b.logger)
+	if err != nil || previous == nil {
+		return nil, err
+	}
+
+	if !previous.ModTime.Equal(state.ModTime) || previous.Size != state.Size || previous.PartSize != state.PartSize ||
+		previous.ChecksumAlgorithm != state.ChecksumAlgorithm {
+		b.logger.InfoContext(ctx, "Source changed since the interrupted upload, starting over", "source", state.Source)
+		b.abortStaleUpload(ctx, previous)
+		return nil, os.Remove(statePath)
+	}
+
+	recorded := map[int32]string{}
+	for _, part := range previous.Parts {
+		recorded[part.PartNumber] = part.ETag
+	}
+
+	done := map[int32]uploadedPart{}
+	paginator := s3.NewListPartsPaginator(b.s3Client, &s3.ListPartsInput{
+		Bucket:   aws.String(previous.Bucket),
+		Key:      aws.String(previous.Key),
+		UploadId: aws.String(previous.UploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload" {
+			b.logger.InfoContext(ctx, "Interrupted upload no longer exists, starting over", "source", state.Source)
+			return nil, os.Remove(statePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range page.Parts {
+			partNumber := aws.ToInt32(part.PartNumber)
+			expectedSize := min(state.PartSize, state.Size-int64(partNumber-1)*state.PartSize)
+			if aws.ToInt64(part.Size) != expectedSize {
+				continue
+			}
+			// A part uploaded right before the crash may be missing from the
+			// state file, but one recorded with another ETag was overwritten
+			if etag, ok := recorded[partNumber]; ok && etag != aws.ToString(part.ETag) {
+				continue
+			}
+
+			done[partNumber] = uploadedPart{
+				PartNumber:    partNumber,
+				ETag:          aws.ToString(part.ETag),
+				ChecksumCRC32: aws.ToString(part.ChecksumCRC32),
+			}
+		}
+	}
+
+	state.UploadID = previous.UploadID
+	for _, part := range done {
+		state.Parts = append(state.Parts, part)
+	}
+
+	return done, nil
+}
+
+func (b *awsS3Client) abortStaleUpload(ctx context.Context, state *uploadState) {
+	_, err := b.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(state.Bucket),
+		Key:      aws.String(state.Key),
+		UploadId: aws.String(state.UploadID),
+	})
+	if err != nil {
+		b.logger.WarnContext(ctx, "Aborting stale multipart upload failed", "error", err)
+	}
+}
+
+// fileName identifies the upload of one source file to one object
+func (s *uploadState) fileName() string {
+	sum := sha256.Sum256([]byte(s.Bucket + "\x00" + s.Key + "\x00" + s.Source))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// save replaces the state file atomically, so a crash never leaves it half written
+func (s *uploadState) save(path string) error {
+	contents, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, contents, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func loadUploadState(path string, logger *slog.Logger) (*uploadState, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state uploadState
+	if err = json.Unmarshal(contents, &state); err != nil {
+		logger.Warn("Ignoring unreadable upload state", "path", path, "error", err)
+		return nil, nil
+	}
+
+	return &state, nil
+}
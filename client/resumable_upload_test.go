@@ -0,0 +1,272 @@
+package client_test
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type listPartsResult struct {
+	XMLName     xml.Name   `xml:"ListPartsResult"`
+	IsTruncated bool       `xml:"IsTruncated"`
+	Parts       []listPart `xml:"Part"`
+}
+
+type listPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int    `xml:"Size"`
+}
+
+var _ = Describe("Resumable uploads", func() {
+	const content = "0123456789abcdefghij"
+
+	var server *httptest.Server
+	var operations []string
+	var uploads map[string]map[int]string
+	var uploadCount int
+	var objects map[string]string
+	var failPart int
+	var requestsMutex sync.Mutex
+	var s3Client *s3.Client
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+	var sourcePath string
+
+	BeforeEach(func() {
+		operations = []string{}
+		uploads = map[string]map[int]string{}
+		uploadCount = 0
+		objects = map[string]string{}
+		failPart = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			body, _ := io.ReadAll(r.Body) //nolint:errcheck
+			query := r.URL.Query()
+			uploadID := query.Get("uploadId")
+			parts, exists := uploads[uploadID]
+
+			switch {
+			case r.Method == http.MethodPost && query.Has("uploads"):
+				uploadCount++
+				uploadID = fmt.Sprintf("upload-%d", uploadCount)
+				uploads[uploadID] = map[int]string{}
+				operations = append(operations, "CreateMultipartUpload "+uploadID)
+				fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>some-bucket</Bucket><Key>some-key</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, uploadID) //nolint:errcheck
+				return
+			case r.Method == http.MethodPut && uploadID == "":
+				operations = append(operations, "PutObject")
+				objects[r.URL.Path] = string(body)
+				w.Header().Set("ETag", `"some-etag"`)
+				return
+			case !exists:
+				operations = append(operations, "ListParts "+uploadID)
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist</Message></Error>`) //nolint:errcheck
+				return
+			case r.Method == http.MethodPut:
+				partNumber, _ := strconv.Atoi(query.Get("partNumber")) //nolint:errcheck
+				operations = append(operations, fmt.Sprintf("UploadPart %s %d", uploadID, partNumber))
+				if partNumber == failPart {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				parts[partNumber] = string(body)
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d-%x"`, partNumber, md5.Sum(body)))
+			case r.Method == http.MethodGet:
+				operations = append(operations, "ListParts "+uploadID)
+				var result listPartsResult
+				for partNumber, part := range parts {
+					result.Parts = append(result.Parts, listPart{PartNumber: partNumber, ETag: fmt.Sprintf(`"etag-%d-%x"`, partNumber, md5.Sum([]byte(part))), Size: len(part)})
+				}
+				sort.Slice(result.Parts, func(i, j int) bool { return result.Parts[i].PartNumber < result.Parts[j].PartNumber })
+				xml.NewEncoder(w).Encode(result) //nolint:errcheck
+			case r.Method == http.MethodDelete:
+				operations = append(operations, "AbortMultipartUpload "+uploadID)
+				delete(uploads, uploadID)
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method == http.MethodPost:
+				operations = append(operations, "CompleteMultipartUpload "+uploadID)
+				partNumbers := make([]int, 0, len(parts))
+				for partNumber := range parts {
+					partNumbers = append(partNumbers, partNumber)
+				}
+				sort.Ints(partNumbers)
+				var object strings.Builder
+				for _, partNumber := range partNumbers {
+					object.WriteString(parts[partNumber])
+				}
+				objects[r.URL.Path] = object.String()
+				delete(uploads, uploadID)
+				fmt.Fprint(w, `<CompleteMultipartUploadResult><Location>some-location</Location><ETag>"some-etag-4"</ETag></CompleteMultipartUploadResult>`) //nolint:errcheck
+			}
+		}))
+
+		s3Client = newTestS3Client(server.URL, func(c *aws.Config) {
+			c.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			c.RetryMaxAttempts = 1
+		})
+
+		s3Config = newTestConfig()
+		s3Config.MultipartUpload = true
+		s3Config.UploadPartSize = 5
+		s3Config.UploadConcurrency = 1
+		s3Config.UploadStateDir = GinkgoT().TempDir()
+		blobstoreClient = client.New(s3Client, s3Config)
+
+		sourcePath = filepath.Join(GinkgoT().TempDir(), "some-file")
+		Expect(os.WriteFile(sourcePath, []byte(content), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	put := func() error {
+		file, err := os.Open(sourcePath)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close() //nolint:errcheck
+
+		return blobstoreClient.Put(file, "some-key")
+	}
+
+	// interrupt fails the upload of the third part, leaving the first two
+	// uploaded and recorded in upload_state_dir
+	interrupt := func() {
+		failPart = 3
+		Expect(put()).To(MatchError(ContainSubstring("uploading part 3")))
+		Expect(operations).To(Equal([]string{
+			"CreateMultipartUpload upload-1",
+			"UploadPart upload-1 1",
+			"UploadPart upload-1 2",
+			"UploadPart upload-1 3",
+		}))
+		Expect(os.ReadDir(s3Config.UploadStateDir)).To(HaveLen(1))
+
+		failPart = 0
+		operations = []string{}
+	}
+
+	It("only uploads the parts missing after an interruption", func() {
+		interrupt()
+
+		Expect(put()).To(Succeed())
+		Expect(operations).To(Equal([]string{
+			"ListParts upload-1",
+			"UploadPart upload-1 3",
+			"UploadPart upload-1 4",
+			"CompleteMultipartUpload upload-1",
+		}))
+		Expect(objects["/some-bucket/some-folder/some-key"]).To(Equal(content))
+		Expect(os.ReadDir(s3Config.UploadStateDir)).To(BeEmpty())
+	})
+
+	DescribeTable("aborts the interrupted upload when the source changed",
+		func(change func(), expectedContent string) {
+			interrupt()
+			change()
+
+			Expect(put()).To(Succeed())
+			Expect(operations[:2]).To(Equal([]string{
+				"AbortMultipartUpload upload-1",
+				"CreateMultipartUpload upload-2",
+			}))
+			Expect(operations).ToNot(ContainElement(HavePrefix("ListParts")))
+			Expect(operations).To(ContainElement("UploadPart upload-2 1"))
+			Expect(objects["/some-bucket/some-folder/some-key"]).To(Equal(expectedContent))
+			Expect(os.ReadDir(s3Config.UploadStateDir)).To(BeEmpty())
+		},
+		Entry("modified", func() {
+			modTime := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(sourcePath, modTime, modTime)).To(Succeed())
+		}, content),
+		Entry("resized", func() {
+			Expect(os.WriteFile(sourcePath, []byte(content+"klm"), 0600)).To(Succeed())
+		}, content+"klm"),
+	)
+
+	It("starts over when the interrupted upload no longer exists", func() {
+		interrupt()
+		delete(uploads, "upload-1")
+
+		Expect(put()).To(Succeed())
+		Expect(operations).To(Equal([]string{
+			"ListParts upload-1",
+			"CreateMultipartUpload upload-2",
+			"UploadPart upload-2 1",
+			"UploadPart upload-2 2",
+			"UploadPart upload-2 3",
+			"UploadPart upload-2 4",
+			"CompleteMultipartUpload upload-2",
+		}))
+		Expect(objects["/some-bucket/some-folder/some-key"]).To(Equal(content))
+		Expect(os.ReadDir(s3Config.UploadStateDir)).To(BeEmpty())
+	})
+
+	It("warns that the upload of an encrypted file cannot be resumed", func() {
+		Expect(os.WriteFile(sourcePath, []byte(strings.Repeat("a", 6*1024*1024)), 0600)).To(Succeed())
+		s3Config.UploadPartSize = 5 * 1024 * 1024
+		keyFile := filepath.Join(GinkgoT().TempDir(), "key")
+		Expect(os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)), 0600)).To(Succeed())
+		s3Config.ClientSideEncryptionKeyFile = keyFile
+		s3Config.LogLevel = config.LogLevelWarn
+
+		// The client logs to the stderr it was created with
+		logFile, err := os.Create(filepath.Join(GinkgoT().TempDir(), "log"))
+		Expect(err).ToNot(HaveOccurred())
+		stderr := os.Stderr
+		os.Stderr = logFile
+		blobstoreClient = client.New(s3Client, s3Config)
+		os.Stderr = stderr
+
+		Expect(put()).To(Succeed())
+		Expect(operations).To(Equal([]string{
+			"CreateMultipartUpload upload-1",
+			"UploadPart upload-1 1",
+			"UploadPart upload-1 2",
+			"CompleteMultipartUpload upload-1",
+		}))
+		Expect(os.ReadDir(s3Config.UploadStateDir)).To(BeEmpty())
+		Expect(os.ReadFile(logFile.Name())).To(ContainSubstring("Upload cannot be resumed"))
+	})
+	It("warns that the upload of a compressed file cannot be resumed", func() {
+		Expect(os.WriteFile(sourcePath, []byte(strings.Repeat("a", 6*1024*1024)), 0600)).To(Succeed())
+		s3Config.UploadPartSize = 5 * 1024 * 1024
+		s3Config.Compression = config.CompressionGzip
+		s3Config.LogLevel = config.LogLevelWarn
+
+		// The client logs to the stderr it was created with
+		logFile, err := os.Create(filepath.Join(GinkgoT().TempDir(), "log"))
+		Expect(err).ToNot(HaveOccurred())
+		stderr := os.Stderr
+		os.Stderr = logFile
+		blobstoreClient = client.New(s3Client, s3Config)
+		os.Stderr = stderr
+
+		Expect(put()).To(Succeed())
+		Expect(operations).To(Equal([]string{"PutObject"}))
+		Expect(os.ReadDir(s3Config.UploadStateDir)).To(BeEmpty())
+		Expect(os.ReadFile(logFile.Name())).To(ContainSubstring("Upload cannot be resumed"))
+	})
+})
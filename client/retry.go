@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	s3cli_config "github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+const defaultRetryMaxBackoff = 20 * time.Second
+
+// newRetryer builds the retryer of every request from the retry settings.
+// Without any of them the SDK's standard retryer is used unchanged.
+func newRetryer(c *s3cli_config.S3Cli) func() aws.Retryer {
+	return func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			if c.RetryMaxAttempts > 0 {
+				o.MaxAttempts = c.RetryMaxAttempts
+			}
+
+			if c.RetryBaseBackoff > 0 || c.RetryMaxBackoff > 0 {
+				backoff := &fullJitterBackoff{base: time.Second, max: defaultRetryMaxBackoff}
+				if c.RetryBaseBackoff > 0 {
+					backoff.base = time.Duration(c.RetryBaseBackoff) * time.Millisecond
+				}
+				if c.RetryMaxBackoff > 0 {
+					backoff.max = time.Duration(c.RetryMaxBackoff) * time.Millisecond
+				}
+				o.MaxBackoff = backoff.max
+				o.Backoff = backoff
+			}
+
+			if len(c.RetryStatusCodes) > 0 {
+				codes := map[int]struct{}{}
+				for _, code := range c.RetryStatusCodes {
+					codes[code] = struct{}{}
+				}
+
+				// Throttling error codes such as SlowDown stay retryable
+				// whatever their status code
+				retryables := make([]retry.IsErrorRetryable, 0, len(o.Retryables))
+				for _, retryable := range o.Retryables {
+					if _, ok := retryable.(retry.RetryableHTTPStatusCode); ok {
+						retryable = retry.RetryableHTTPStatusCode{Codes: codes}
+					}
+					retryables = append(retryables, retryable)
+				}
+				o.Retryables = retryables
+			}
+		})
+	}
+}
+
+// fullJitterBackoff waits a random duration below base * 2^(attempt-1),
+// capped at max
+type fullJitterBackoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+func (b *fullJitterBackoff) BackoffDelay(attempt int, _ error) (time.Duration, error) {
+	ceiling := b.max
+	if attempt < 32 {
+		ceiling = min(b.max, b.base<<max(attempt-1, 0))
+	}
+	if ceiling <= 0 {
+		return 0, nil
+	}
+
+	return rand.N(ceiling), nil //nolint:gosec
+}
+
+// attemptTimeoutError reports an attempt cancelled after attempt_timeout_seconds.
+// Unlike the cancellation of the whole operation it is retried, which is why
+// it does not wrap the cancellation error.
+type attemptTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *attemptTimeoutError) Error() string {
+	return fmt.Sprintf("attempt timed out after %s: %v", e.timeout, e.err)
+}
+
+func (e *attemptTimeoutError) Timeout() bool {
+	return true
+}
+
+// AddAttemptTimeoutMiddleware cancels every attempt of a request which did
+// not receive its response headers within timeout. Response bodies, e.g. of
+// GetObject, are read after the attempt and are not limited; the context of
+// a successful attempt is cancelled once its body is closed.
+func AddAttemptTimeoutMiddleware(timeout time.Duration) func(*middleware.Stack) error {
+	attemptTimeout := middleware.FinalizeMiddlewareFunc("AttemptTimeout",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			attemptCtx, cancel := context.WithCancel(ctx)
+			timer := time.AfterFunc(timeout, cancel)
+
+			out, metadata, err := next.HandleFinalize(context.WithValue(attemptCtx, attemptCancelKey{}, cancel), in)
+			if !timer.Stop() && ctx.Err() == nil {
+				return out, metadata, &attemptTimeoutError{timeout: timeout, err: err}
+			}
+			if err != nil {
+				cancel()
+			}
+
+			return out, metadata, err
+		},
+	)
+
+	// Sees the raw response before the operation deserializer, which closes
+	// the body unless it is a stream handed to the caller
+	cancelOnClose := middleware.DeserializeMiddlewareFunc("AttemptTimeoutBody",
+		func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+
+			cancel, ok := ctx.Value(attemptCancelKey{}).(context.CancelFunc)
+			if resp, isHTTP := out.RawResponse.(*smithyhttp.Response); ok && isHTTP && resp.Body != nil {
+				resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+			}
+
+			return out, metadata, err
+		},
+	)
+
+	return func(stack *middleware.Stack) error {
+		// Presigning stacks do not send requests
+		if _, ok := stack.Finalize.Get("Retry"); !ok {
+			return nil
+		}
+
+		if err := stack.Finalize.Insert(attemptTimeout, "Retry", middleware.After); err != nil {
+			return err
+		}
+		return stack.Deserialize.Insert(cancelOnClose, "OperationDeserializer", middleware.After)
+	}
+}
+
+type attemptCancelKey struct{}
+
+// cancelOnCloseBody releases the context of an attempt with its response body
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
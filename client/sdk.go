@@ -3,7 +3,9 @@ package client
 import (
 	"context"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -61,6 +63,8 @@ func NewAwsS3ClientWithApiOptions(
 		return nil, err
 	}
 
+	awsConfig.Retryer = newRetryer(c)
+
 	if c.AssumeRoleArn != "" {
 		stsClient := sts.NewFromConfig(awsConfig)
 		provider := stscreds.NewAssumeRoleProvider(stsClient, c.AssumeRoleArn)
@@ -88,6 +92,12 @@ func NewAwsS3ClientWithApiOptions(
 			}
 			o.BaseEndpoint = aws.String(endpoint)
 		}
+		if c.LogLevel == s3cli_config.LogLevelDebug {
+			o.APIOptions = append(o.APIOptions, AddDebugLoggingMiddleware(NewLogger(os.Stderr, c)))
+		}
+		if c.AttemptTimeoutSeconds > 0 {
+			o.APIOptions = append(o.APIOptions, AddAttemptTimeoutMiddleware(time.Duration(c.AttemptTimeoutSeconds)*time.Second))
+		}
 		// Apply custom middlewares if provided
 		o.APIOptions = append(o.APIOptions, apiOptions...)
 	})
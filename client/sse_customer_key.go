@@ -0,0 +1,49 @@
+package client
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// sseCustomerKey holds the SSE-C parameters every request reading or writing
+// an object encrypted with a customer-provided key has to send: the
+// algorithm, the key and its MD5. They are nil without sse_customer_key.
+type sseCustomerKey struct {
+	algorithm *string
+	key       *string
+	keyMD5    *string
+}
+
+// sseCustomerKey returns the SSE-C parameters of the configured key, which
+// config.NewFromReader made sure is a base64 encoded 256-bit key
+func (b *awsS3Client) sseCustomerKey() sseCustomerKey {
+	encodedKey := b.s3cliConfig.SSECustomerKey
+	if encodedKey == "" {
+		return sseCustomerKey{}
+	}
+
+	key, _ := base64.StdEncoding.DecodeString(encodedKey) //nolint:errcheck
+	digest := md5.Sum(key)                                //nolint:gosec
+	return sseCustomerKey{
+		algorithm: aws.String("AES256"),
+		key:       aws.String(encodedKey),
+		keyMD5:    aws.String(base64.StdEncoding.EncodeToString(digest[:])),
+	}
+}
+
+// sseCustomerKeyHeaders picks the SSE-C headers out of the headers a request
+// was presigned with, which the requests using its URL have to send
+func sseCustomerKeyHeaders(signedHeader http.Header) http.Header {
+	headers := http.Header{}
+	for name, values := range signedHeader {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-server-side-encryption-customer-") {
+			headers[name] = values
+		}
+	}
+
+	return headers
+}
@@ -0,0 +1,114 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSE-C", func() {
+	var server *httptest.Server
+	var headers map[string]http.Header
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	keyMD5 := md5.Sum(key) //nolint:gosec
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	encodedKeyMD5 := base64.StdEncoding.EncodeToString(keyMD5[:])
+
+	expectSSECustomerKey := func(header http.Header, prefix string) {
+		Expect(header.Get(prefix + "Algorithm")).To(Equal("AES256"))
+		Expect(header.Get(prefix + "Key")).To(Equal(encodedKey))
+		Expect(header.Get(prefix + "Key-Md5")).To(Equal(encodedKeyMD5))
+	}
+
+	BeforeEach(func() {
+		headers = map[string]http.Header{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			io.Copy(io.Discard, r.Body) //nolint:errcheck
+			switch {
+			case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+				headers["CopyObject"] = r.Header.Clone()
+				w.Write([]byte(`<CopyObjectResult><ETag>"some-etag"</ETag></CopyObjectResult>`)) //nolint:errcheck
+			case r.Method == http.MethodPut:
+				headers["PutObject"] = r.Header.Clone()
+				w.Header().Set("ETag", `"some-etag"`)
+			case r.Method == http.MethodHead:
+				headers["HeadObject"] = r.Header.Clone()
+				w.Header().Set("ETag", `"some-etag"`)
+				w.Header().Set("Content-Length", "7")
+			case r.Method == http.MethodGet:
+				headers["GetObject"] = r.Header.Clone()
+				w.Header().Set("ETag", `"some-etag"`)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte("content")))
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config := newTestConfig()
+		s3Config.SSECustomerKey = encodedKey
+		blobstoreClient = newTestClient(server.URL, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the key with uploads", func() {
+		Expect(blobstoreClient.Put(bytes.NewReader([]byte("content")), "some-key")).To(Succeed())
+
+		expectSSECustomerKey(headers["PutObject"], "X-Amz-Server-Side-Encryption-Customer-")
+	})
+
+	It("sends the key with downloads", func() {
+		dest := &bytes.Buffer{}
+		Expect(blobstoreClient.GetStream("some-key", dest)).To(Succeed())
+
+		Expect(dest.String()).To(Equal("content"))
+		expectSSECustomerKey(headers["GetObject"], "X-Amz-Server-Side-Encryption-Customer-")
+	})
+
+	It("sends the key with existence checks", func() {
+		exists, err := blobstoreClient.Exists("some-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		expectSSECustomerKey(headers["HeadObject"], "X-Amz-Server-Side-Encryption-Customer-")
+	})
+
+	It("sends the key for both the source and the copy", func() {
+		Expect(blobstoreClient.Copy("some-key", "other-key")).To(Succeed())
+
+		expectSSECustomerKey(headers["HeadObject"], "X-Amz-Server-Side-Encryption-Customer-")
+		expectSSECustomerKey(headers["CopyObject"], "X-Amz-Server-Side-Encryption-Customer-")
+		expectSSECustomerKey(headers["CopyObject"], "X-Amz-Copy-Source-Server-Side-Encryption-Customer-")
+	})
+
+	It("signs the key headers along with the URL", func() {
+		var signedHeaders http.Header
+		signedURL, err := blobstoreClient.Sign("some-key", "get", time.Hour, func(o *client.SignOptions) {
+			o.SignedHeaders = &signedHeaders
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(signedURL).To(ContainSubstring("x-amz-server-side-encryption-customer-algorithm"))
+		Expect(signedURL).ToNot(ContainSubstring(encodedKey))
+		Expect(signedHeaders).ToNot(HaveKey("Host"))
+		expectSSECustomerKey(signedHeaders, "X-Amz-Server-Side-Encryption-Customer-")
+	})
+})
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Stat returns the metadata HeadObject reports for key
+func (b *awsS3Client) Stat(ctx context.Context, key string, optFns ...func(*StatOptions)) (ObjectMetadata, error) {
+	var opts StatOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(key),
+		VersionId:            versionIDParam(opts.VersionID),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+		// Checksums are only reported on request
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return ObjectMetadata{}, err
+	}
+
+	metadata := ObjectMetadata{
+		Key:                       key,
+		Bucket:                    b.s3cliConfig.BucketName,
+		VersionID:                 aws.ToString(head.VersionId),
+		Size:                      aws.ToInt64(head.ContentLength),
+		ETag:                      strings.Trim(aws.ToString(head.ETag), `"`),
+		ContentType:               aws.ToString(head.ContentType),
+		LastModified:              aws.ToTime(head.LastModified),
+		StorageClass:              string(head.StorageClass),
+		CacheControl:              aws.ToString(head.CacheControl),
+		ContentDisposition:        aws.ToString(head.ContentDisposition),
+		ContentEncoding:           aws.ToString(head.ContentEncoding),
+		ServerSideEncryption:      string(head.ServerSideEncryption),
+		SSEKMSKeyID:               aws.ToString(head.SSEKMSKeyId),
+		Metadata:                  head.Metadata,
+		TagCount:                  int(aws.ToInt32(head.TagCount)),
+		Checksums:                 map[string]string{},
+		ChecksumType:              string(head.ChecksumType),
+		ObjectLockMode:            string(head.ObjectLockMode),
+		ObjectLockRetainUntilDate: aws.ToTime(head.ObjectLockRetainUntilDate),
+		ObjectLockLegalHold:       string(head.ObjectLockLegalHoldStatus),
+	}
+	for algorithm, checksum := range map[types.ChecksumAlgorithm]*string{
+		types.ChecksumAlgorithmCrc32:     head.ChecksumCRC32,
+		types.ChecksumAlgorithmCrc32c:    head.ChecksumCRC32C,
+		types.ChecksumAlgorithmCrc64nvme: head.ChecksumCRC64NVME,
+		types.ChecksumAlgorithmSha1:      head.ChecksumSHA1,
+		types.ChecksumAlgorithmSha256:    head.ChecksumSHA256,
+	} {
+		if checksum != nil {
+			metadata.Checksums[string(algorithm)] = *checksum
+		}
+	}
+	if isEncrypted(head.Metadata) {
+		metadata.Encryption = head.Metadata[metadataEncryption]
+		if metadata.OriginalSize, err = decryptedSize(metadata.Size); err != nil {
+			metadata.OriginalSize = -1
+		}
+	}
+	if compression := head.Metadata[metadataCompression]; compression != "" {
+		metadata.Compression = compression
+		metadata.OriginalSize = originalSize(head.Metadata)
+	}
+
+	b.logger.InfoContext(ctx, "Read object metadata", "key", key, "bucket", b.s3cliConfig.BucketName, "version_id", metadata.VersionID)
+	return metadata, nil
+}
@@ -0,0 +1,96 @@
+package client_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stat", func() {
+	var server *httptest.Server
+	var requests []*http.Request
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+			requests = append(requests, r)
+
+			if r.URL.Path != "/some-bucket/some-folder/some-key" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			header := w.Header()
+			header.Set("Content-Length", "42")
+			header.Set("Content-Type", "text/plain")
+			header.Set("Cache-Control", "max-age=60")
+			header.Set("ETag", `"some-etag"`)
+			header.Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+			header.Set("X-Amz-Version-Id", "v1")
+			header.Set("X-Amz-Storage-Class", "STANDARD_IA")
+			header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "some-kms-key")
+			header.Set("X-Amz-Meta-Sha256", "some-digest")
+			header.Set("X-Amz-Tagging-Count", "2")
+			header.Set("X-Amz-Checksum-Sha256", "some-checksum")
+			header.Set("X-Amz-Checksum-Type", "FULL_OBJECT")
+			header.Set("X-Amz-Object-Lock-Mode", "GOVERNANCE")
+			header.Set("X-Amz-Object-Lock-Retain-Until-Date", "2030-01-01T00:00:00Z")
+			header.Set("X-Amz-Object-Lock-Legal-Hold", "ON")
+		}))
+
+		blobstoreClient = newTestClient(server.URL, newTestConfig())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns the metadata HeadObject reports", func() {
+		metadata, err := blobstoreClient.Stat("some-key", func(o *client.StatOptions) {
+			o.VersionID = "v1"
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(metadata).To(Equal(client.ObjectMetadata{
+			Key:                       "some-key",
+			Bucket:                    "some-bucket",
+			VersionID:                 "v1",
+			Size:                      42,
+			ETag:                      "some-etag",
+			ContentType:               "text/plain",
+			LastModified:              time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			StorageClass:              "STANDARD_IA",
+			CacheControl:              "max-age=60",
+			ServerSideEncryption:      "aws:kms",
+			SSEKMSKeyID:               "some-kms-key",
+			Metadata:                  map[string]string{"sha256": "some-digest"},
+			TagCount:                  2,
+			Checksums:                 map[string]string{"SHA256": "some-checksum"},
+			ChecksumType:              "FULL_OBJECT",
+			ObjectLockMode:            "GOVERNANCE",
+			ObjectLockRetainUntilDate: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			ObjectLockLegalHold:       "ON",
+		}))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Method).To(Equal(http.MethodHead))
+		Expect(requests[0].URL.Query().Get("versionId")).To(Equal("v1"))
+		Expect(requests[0].Header.Get("X-Amz-Checksum-Mode")).To(Equal("ENABLED"))
+	})
+
+	It("classifies a missing object", func() {
+		_, err := blobstoreClient.Stat("missing-key")
+		Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue(), "%v", err)
+	})
+})
@@ -0,0 +1,487 @@
+package client
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+// Operations a sync performs on a file or key
+const (
+	SyncUpload   = "upload"
+	SyncDownload = "download"
+	SyncDelete   = "delete"
+)
+
+// SyncAction is a transfer or deletion a sync performed, or would perform in
+// a dry run
+type SyncAction struct {
+	// Op is SyncUpload, SyncDownload or SyncDelete
+	Op string
+	// Key is relative to the configured folder_name, empty when a local file
+	// is deleted
+	Key string
+	// Path is the local file, empty when a key is deleted
+	Path string
+	Size int64
+}
+
+// syncFile is a file or key present on one side of a sync, by its path
+// relative to the synced directory
+type syncFile struct {
+	size int64
+	etag string
+}
+
+// errSyncEncrypted refuses syncs with client-side encryption configured:
+// neither the ETag nor the metadata of an encrypted blob tell whether its
+// content matches a file
+var errSyncEncrypted = &Error{
+	Kind: ErrInvalidConfig,
+	Err:  errors.New("sync cannot compare client-side encrypted blobs with files, it does not support client_side_encryption_key_file or client_side_encryption_kms_key_id"),
+}
+
+// SyncUpload uploads the files below localDir which are missing or differ
+// below prefix, the key of a file being prefix joined with its path relative
+// to localDir. Files are compared by size and MD5, see SyncOptions.
+func (b *awsS3Client) SyncUpload(ctx context.Context, localDir string, prefix string, optFns ...func(*SyncOptions)) error {
+	if b.clientSideEncryptionConfigured() {
+		return errSyncEncrypted
+	}
+	opts := syncOptions(optFns)
+	prefix = syncPrefix(prefix)
+
+	localFiles, err := listLocalFiles(localDir)
+	if err != nil {
+		return err
+	}
+	remoteFiles, err := b.listSyncKeys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	s := b.newSyncRun(ctx, opts)
+	for _, rel := range sortedPaths(localFiles) {
+		local := localFiles[rel]
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		key := prefix + rel
+		s.run(func(ctx context.Context) error {
+			if remote, ok := remoteFiles[rel]; ok {
+				unchanged, err := b.syncUnchanged(ctx, localPath, local.size, key, remote, opts.SizeOnly)
+				if err != nil || unchanged {
+					return err
+				}
+			}
+
+			action := SyncAction{Op: SyncUpload, Key: key, Path: localPath, Size: local.size}
+			return s.perform(action, func() error {
+				file, err := os.Open(localPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close() //nolint:errcheck
+
+				return b.Put(ctx, file, key)
+			})
+		})
+	}
+
+	if opts.Delete {
+		var extraKeys []string
+		for _, rel := range sortedPaths(remoteFiles) {
+			if _, ok := localFiles[rel]; !ok {
+				extraKeys = append(extraKeys, prefix+rel)
+			}
+		}
+		if len(extraKeys) > 0 {
+			s.run(func(ctx context.Context) error {
+				return b.DeleteKeys(ctx, extraKeys, func(o *BatchDeleteOptions) {
+					o.DryRun = opts.DryRun
+					o.Deleted = func(key string) {
+						s.report(SyncAction{Op: SyncDelete, Key: key, Size: remoteFiles[strings.TrimPrefix(key, prefix)].size})
+					}
+				})
+			})
+		}
+	}
+
+	return s.wait()
+}
+
+// SyncDownload downloads the keys below prefix which are missing or differ
+// below localDir, the mirror image of SyncUpload
+func (b *awsS3Client) SyncDownload(ctx context.Context, prefix string, localDir string, optFns ...func(*SyncOptions)) error {
+	if b.clientSideEncryptionConfigured() {
+		return errSyncEncrypted
+	}
+	opts := syncOptions(optFns)
+	prefix = syncPrefix(prefix)
+
+	remoteFiles, err := b.listSyncKeys(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	localFiles, err := listLocalFiles(localDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	s := b.newSyncRun(ctx, opts)
+	for _, rel := range sortedPaths(remoteFiles) {
+		remote := remoteFiles[rel]
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		key := prefix + rel
+		s.run(func(ctx context.Context) error {
+			if local, ok := localFiles[rel]; ok {
+				unchanged, err := b.syncUnchanged(ctx, localPath, local.size, key, remote, opts.SizeOnly)
+				if err != nil || unchanged {
+					return err
+				}
+			}
+
+			action := SyncAction{Op: SyncDownload, Key: key, Path: localPath, Size: remote.size}
+			return s.perform(action, func() error {
+				if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+					return err
+				}
+				return b.GetFile(ctx, key, localPath)
+			})
+		})
+	}
+
+	if opts.Delete {
+		for _, rel := range sortedPaths(localFiles) {
+			if _, ok := remoteFiles[rel]; ok {
+				continue
+			}
+
+			localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+			action := SyncAction{Op: SyncDelete, Path: localPath, Size: localFiles[rel].size}
+			s.run(func(context.Context) error {
+				return s.perform(action, func() error {
+					return os.Remove(localPath)
+				})
+			})
+		}
+	}
+
+	return s.wait()
+}
+
+func syncOptions(optFns []func(*SyncOptions)) SyncOptions {
+	var opts SyncOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	return opts
+}
+
+// syncPrefix makes a non-empty prefix end in a path separator, so that it
+// acts as a directory
+func syncPrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return prefix + "/"
+	}
+
+	return prefix
+}
+
+// listLocalFiles lists the regular files below dir by their slash separated
+// path relative to dir. Partial and temporary downloads are left out.
+func listLocalFiles(dir string) (map[string]syncFile, error) {
+	files := map[string]syncFile{}
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), partialDownloadSuffix) ||
+			strings.HasSuffix(entry.Name(), tmpDownloadSuffix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		files[rel] = syncFile{size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// listSyncKeys lists the keys below prefix by their path relative to prefix.
+// Keys ending in a slash are folder markers and left out.
+func (b *awsS3Client) listSyncKeys(ctx context.Context, prefix string) (map[string]syncFile, error) {
+	files := map[string]syncFile{}
+	err := b.List(ctx, prefix, func(entry ListEntry) error {
+		rel := strings.TrimPrefix(entry.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			return nil
+		}
+		// Keys such as "a/../../b" would leave the synced directory
+		if cleaned := path.Clean(rel); cleaned != rel || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			b.logger.WarnContext(ctx, "Skipping key which is no valid file path", "key", entry.Key)
+			return nil
+		}
+
+		files[rel] = syncFile{size: entry.Size, etag: entry.ETag}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// sortedPaths are the paths of files in order, so that syncs start their
+// transfers in a predictable order
+func sortedPaths(files map[string]syncFile) []string {
+	paths := make([]string, 0, len(files))
+	for rel := range files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// syncUnchanged compares the local file with the object of the same path.
+// The ETag of an object uploaded in one part is the MD5 of its content, that
+// of a multipart upload the MD5 of the MD5s of its parts followed by the
+// number of parts. Its part size is not recorded, the usual ones are tried.
+// Objects which ETag is no MD5, e.g. encrypted with SSE-KMS, always differ.
+// Objects which seem to differ may be compressed, see compressedUnchanged.
+func (b *awsS3Client) syncUnchanged(ctx context.Context, localPath string, size int64, key string, remote syncFile, sizeOnly bool) (bool, error) {
+	unchanged, err := storedUnchanged(localPath, size, remote, sizeOnly, b.uploadPartSize(size))
+	if err != nil || unchanged {
+		return unchanged, err
+	}
+
+	return b.compressedUnchanged(ctx, localPath, size, key, sizeOnly)
+}
+
+// storedUnchanged compares the local file with the object as it is stored
+func storedUnchanged(localPath string, size int64, remote syncFile, sizeOnly bool, uploadPartSize int64) (bool, error) {
+	if size != remote.size {
+		return false, nil
+	}
+	if sizeOnly {
+		return true, nil
+	}
+
+	etag, partsSuffix, multipart := strings.Cut(remote.etag, "-")
+	if !multipart {
+		digest, err := fileMD5(localPath, 0)
+		if err != nil {
+			return false, err
+		}
+		return digest == etag, nil
+	}
+
+	parts, err := strconv.ParseInt(partsSuffix, 10, 64)
+	if err != nil || parts < 1 {
+		return false, nil
+	}
+	for _, partSize := range multipartPartSizes(size, parts, uploadPartSize) {
+		digest, err := fileMD5(localPath, partSize)
+		if err != nil {
+			return false, err
+		}
+		if digest == fmt.Sprintf("%s-%d", etag, parts) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// compressedUnchanged compares the local file with the original content of a
+// compressed object, whose size and SHA256 its metadata records. Neither the
+// listed size nor the ETag tell, so the object is looked up with a HEAD
+// request. Objects which are not compressed differ.
+func (b *awsS3Client) compressedUnchanged(ctx context.Context, localPath string, size int64, key string, sizeOnly bool) (bool, error) {
+	sse := b.sseCustomerKey()
+	head, err := b.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:               aws.String(b.s3cliConfig.BucketName),
+		Key:                  b.key(key),
+		SSECustomerAlgorithm: sse.algorithm,
+		SSECustomerKey:       sse.key,
+		SSECustomerKeyMD5:    sse.keyMD5,
+	})
+	if err != nil {
+		return false, err
+	}
+	if head.Metadata[metadataCompression] == "" || originalSize(head.Metadata) != size {
+		return false, nil
+	}
+	if sizeOnly {
+		return true, nil
+	}
+
+	digest, err := fileSHA256(localPath)
+	if err != nil {
+		return false, err
+	}
+	return digest == head.Metadata[metadataOriginalSHA256], nil
+}
+
+// multipartPartSizes are the part sizes which split size bytes into parts:
+// the part size s3cli uploads with, the 8 MiB of the AWS CLI and those of
+// uploaders choosing the smallest part size, possibly in whole MiB
+func multipartPartSizes(size int64, parts int64, uploadPartSize int64) []int64 {
+	const mib = 1024 * 1024
+	smallest := (size + parts - 1) / parts
+
+	var partSizes []int64
+	for _, partSize := range []int64{uploadPartSize, 8 * mib, smallest, (smallest + mib - 1) / mib * mib} {
+		if partSize <= 0 || (size+partSize-1)/partSize != parts || slices.Contains(partSizes, partSize) {
+			continue
+		}
+		partSizes = append(partSizes, partSize)
+	}
+
+	return partSizes
+}
+
+// fileMD5 is the hex encoded MD5 of a file or, with a part size, the MD5 of
+// the concatenated MD5s of its parts as in a multipart ETag
+func fileMD5(filePath string, partSize int64) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	if partSize == 0 {
+		digest := md5.New() //nolint:gosec
+		if _, err = io.Copy(digest, file); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(digest.Sum(nil)), nil
+	}
+
+	var parts int
+	partDigests := md5.New() //nolint:gosec
+	for {
+		partDigest := md5.New() //nolint:gosec
+		n, err := io.CopyN(partDigest, file, partSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		if n == 0 {
+			break
+		}
+		partDigests.Write(partDigest.Sum(nil)) //nolint:errcheck
+		parts++
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(partDigests.Sum(nil)), parts), nil
+}
+
+// fileSHA256 is the hex encoded SHA256 of a file
+func fileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	digest := sha256.New()
+	if _, err = io.Copy(digest, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// syncRun transfers the files of a sync concurrently and reports what it did
+type syncRun struct {
+	opts   SyncOptions
+	group  *errgroup.Group
+	ctx    context.Context
+	mutex  sync.Mutex
+	logger *slog.Logger
+}
+
+func (b *awsS3Client) newSyncRun(ctx context.Context, opts SyncOptions) *syncRun {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultTransferConcurrency
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+
+	return &syncRun{
+		opts:   opts,
+		group:  group,
+		ctx:    groupCtx,
+		logger: b.logger,
+	}
+}
+
+// run executes fn concurrently, unless a previous one failed
+func (s *syncRun) run(fn func(ctx context.Context) error) {
+	s.group.Go(func() error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		return fn(s.ctx)
+	})
+}
+
+// perform executes fn, unless in a dry run, and reports action once it is done
+func (s *syncRun) perform(action SyncAction, fn func() error) error {
+	if !s.opts.DryRun {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	s.report(action)
+	return nil
+}
+
+// report passes action to the Synced callback, one at a time
+func (s *syncRun) report(action SyncAction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.logger.InfoContext(s.ctx, "Synced", "op", action.Op, "key", action.Key, "path", action.Path, "size", action.Size, "dry_run", s.opts.DryRun)
+	if s.opts.Synced != nil {
+		s.opts.Synced(action)
+	}
+}
+
+func (s *syncRun) wait() error {
+	return s.group.Wait()
+}
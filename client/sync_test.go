@@ -0,0 +1,270 @@
+package client_test
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sync", func() {
+	type object struct {
+		content  string
+		etag     string
+		metadata http.Header
+	}
+
+	var server *httptest.Server
+	var objects map[string]object
+	var requests []string
+	var requestsMutex sync.Mutex
+	var localDir string
+	var s3Config *config.S3Cli
+	var blobstoreClient client.S3CompatibleClient
+
+	md5Hex := func(content string) string {
+		digest := md5.Sum([]byte(content)) //nolint:gosec
+		return hex.EncodeToString(digest[:])
+	}
+
+	writeFile := func(rel string, content string) {
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		Expect(os.MkdirAll(filepath.Dir(localPath), 0755)).To(Succeed())
+		Expect(os.WriteFile(localPath, []byte(content), 0644)).To(Succeed())
+	}
+
+	readFile := func(rel string) string {
+		content, err := os.ReadFile(filepath.Join(localDir, filepath.FromSlash(rel)))
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	runSync := func(upload bool, optFns ...func(*client.SyncOptions)) []client.SyncAction {
+		var actions []client.SyncAction
+		optFns = append(optFns, func(o *client.SyncOptions) {
+			o.Synced = func(action client.SyncAction) {
+				action.Path = strings.TrimPrefix(action.Path, localDir)
+				actions = append(actions, action)
+			}
+		})
+
+		var err error
+		if upload {
+			err = blobstoreClient.SyncUpload(localDir, "some-prefix", optFns...)
+		} else {
+			err = blobstoreClient.SyncDownload("some-prefix", localDir, optFns...)
+		}
+		Expect(err).NotTo(HaveOccurred())
+
+		sort.Slice(actions, func(i, j int) bool { return actions[i].Key+actions[i].Path < actions[j].Key+actions[j].Path })
+		return actions
+	}
+
+	BeforeEach(func() {
+		objects = map[string]object{}
+		requests = nil
+		localDir = GinkgoT().TempDir()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			key := strings.TrimPrefix(r.URL.Path, "/some-bucket/")
+			switch {
+			case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+				keys := make([]string, 0, len(objects))
+				for key := range objects {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+
+				fmt.Fprint(w, `<ListBucketResult>`) //nolint:errcheck
+				for _, key := range keys {
+					fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><ETag>"%s"</ETag></Contents>`, key, len(objects[key].content), objects[key].etag) //nolint:errcheck
+				}
+				fmt.Fprint(w, `</ListBucketResult>`) //nolint:errcheck
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				if r.Method == http.MethodGet {
+					requests = append(requests, "GetObject "+key)
+				}
+				for name, values := range objects[key].metadata {
+					w.Header()[name] = values
+				}
+				w.Header().Set("ETag", `"`+objects[key].etag+`"`)
+				w.Header().Set("Content-Length", strconv.Itoa(len(objects[key].content)))
+				if r.Method == http.MethodGet {
+					fmt.Fprint(w, objects[key].content) //nolint:errcheck
+				}
+			case r.Method == http.MethodPut:
+				requests = append(requests, "PutObject "+key)
+				body, _ := io.ReadAll(r.Body) //nolint:errcheck
+				stored := object{content: string(body), etag: md5Hex(string(body)), metadata: http.Header{}}
+				for name, values := range r.Header {
+					if strings.HasPrefix(name, "X-Amz-Meta-") {
+						stored.metadata[name] = values
+					}
+				}
+				objects[key] = stored
+				w.Header().Set("ETag", `"`+stored.etag+`"`)
+			case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+				requests = append(requests, "DeleteObjects")
+				fmt.Fprint(w, `<DeleteResult></DeleteResult>`) //nolint:errcheck
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		s3Config = newTestConfig()
+		s3Config.FolderName = ""
+		blobstoreClient = newTestClient(server.URL, s3Config)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("SyncUpload()", func() {
+		BeforeEach(func() {
+			writeFile("same", "same content")
+			writeFile("dir/changed", "new content")
+			writeFile("dir/new", "new")
+
+			// Uploaded in two parts of 5 MiB and 1 MiB
+			multipart := strings.Repeat("a", 6*1024*1024)
+			writeFile("multipart", multipart)
+			partDigests := md5Hex(multipart[:5*1024*1024]) + md5Hex(multipart[5*1024*1024:])
+			partDigestsBytes, _ := hex.DecodeString(partDigests) //nolint:errcheck
+
+			objects["some-prefix/same"] = object{content: "same content", etag: md5Hex("same content")}
+			objects["some-prefix/dir/changed"] = object{content: "old content", etag: md5Hex("old content")}
+			objects["some-prefix/multipart"] = object{content: multipart, etag: md5Hex(string(partDigestsBytes)) + "-2"}
+			objects["some-prefix/extra"] = object{content: "extra", etag: md5Hex("extra")}
+		})
+
+		It("uploads the files which are missing or differ", func() {
+			actions := runSync(true)
+
+			Expect(actions).To(Equal([]client.SyncAction{
+				{Op: client.SyncUpload, Key: "some-prefix/dir/changed", Path: "/dir/changed", Size: 11},
+				{Op: client.SyncUpload, Key: "some-prefix/dir/new", Path: "/dir/new", Size: 3},
+			}))
+			sort.Strings(requests)
+			Expect(requests).To(Equal([]string{"PutObject some-prefix/dir/changed", "PutObject some-prefix/dir/new"}))
+		})
+
+		It("compares by size only", func() {
+			actions := runSync(true, func(o *client.SyncOptions) { o.SizeOnly = true })
+
+			Expect(actions).To(Equal([]client.SyncAction{
+				{Op: client.SyncUpload, Key: "some-prefix/dir/new", Path: "/dir/new", Size: 3},
+			}))
+		})
+
+		It("deletes the keys missing locally", func() {
+			actions := runSync(true, func(o *client.SyncOptions) { o.Delete = true })
+
+			Expect(actions).To(ContainElement(client.SyncAction{Op: client.SyncDelete, Key: "some-prefix/extra", Size: 5}))
+			Expect(requests).To(ContainElement("DeleteObjects"))
+		})
+
+		It("only reports the changes in a dry run", func() {
+			actions := runSync(true, func(o *client.SyncOptions) {
+				o.Delete = true
+				o.DryRun = true
+			})
+
+			Expect(actions).To(HaveLen(3))
+			Expect(requests).To(BeEmpty())
+		})
+	})
+
+	Describe("SyncDownload()", func() {
+		BeforeEach(func() {
+			writeFile("same", "same content")
+			writeFile("dir/changed", "old content")
+			writeFile("extra", "extra")
+
+			objects["some-prefix/same"] = object{content: "same content", etag: md5Hex("same content")}
+			objects["some-prefix/dir/changed"] = object{content: "new content", etag: md5Hex("new content")}
+			objects["some-prefix/dir/sub/new"] = object{content: "new", etag: md5Hex("new")}
+			objects["some-prefix/dir/"] = object{}
+			objects["some-prefix/../escape"] = object{content: "escape", etag: md5Hex("escape")}
+		})
+
+		It("downloads the keys which are missing or differ", func() {
+			actions := runSync(false)
+
+			Expect(actions).To(Equal([]client.SyncAction{
+				{Op: client.SyncDownload, Key: "some-prefix/dir/changed", Path: "/dir/changed", Size: 11},
+				{Op: client.SyncDownload, Key: "some-prefix/dir/sub/new", Path: "/dir/sub/new", Size: 3},
+			}))
+			Expect(readFile("dir/changed")).To(Equal("new content"))
+			Expect(readFile("dir/sub/new")).To(Equal("new"))
+			Expect(readFile("extra")).To(Equal("extra"))
+		})
+
+		It("deletes the files missing in the bucket", func() {
+			actions := runSync(false, func(o *client.SyncOptions) { o.Delete = true })
+
+			Expect(actions).To(ContainElement(client.SyncAction{Op: client.SyncDelete, Path: "/extra", Size: 5}))
+			Expect(filepath.Join(localDir, "extra")).NotTo(BeAnExistingFile())
+		})
+
+		It("only reports the changes in a dry run", func() {
+			actions := runSync(false, func(o *client.SyncOptions) {
+				o.Delete = true
+				o.DryRun = true
+			})
+
+			Expect(actions).To(HaveLen(3))
+			Expect(requests).To(BeEmpty())
+			Expect(readFile("dir/changed")).To(Equal("old content"))
+			Expect(readFile("extra")).To(Equal("extra"))
+		})
+	})
+
+	Describe("with compression", func() {
+		BeforeEach(func() {
+			s3Config.Compression = config.CompressionGzip
+			writeFile("compressible", strings.Repeat("compressible content ", 100))
+			writeFile("dir/small", "small")
+		})
+
+		It("compares files with the original content of compressed blobs", func() {
+			Expect(runSync(true)).To(HaveLen(2))
+			Expect(objects["some-prefix/compressible"].content).ToNot(Equal(readFile("compressible")))
+
+			Expect(runSync(true)).To(BeEmpty())
+			Expect(runSync(true, func(o *client.SyncOptions) { o.SizeOnly = true })).To(BeEmpty())
+
+			writeFile("dir/small", "SMALL")
+			Expect(runSync(true)).To(Equal([]client.SyncAction{
+				{Op: client.SyncUpload, Key: "some-prefix/dir/small", Path: "/dir/small", Size: 5},
+			}))
+
+			localDir = GinkgoT().TempDir()
+			requests = nil
+			Expect(runSync(false)).To(HaveLen(2))
+			Expect(readFile("compressible")).To(Equal(strings.Repeat("compressible content ", 100)))
+			Expect(readFile("dir/small")).To(Equal("SMALL"))
+
+			requests = nil
+			Expect(runSync(false)).To(BeEmpty())
+			Expect(requests).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/cloudfoundry/bosh-s3cli/config"
+)
+
+// Tags returns the tags of key by name
+func (b *awsS3Client) Tags(ctx context.Context, key string, optFns ...func(*TagOptions)) (map[string]string, error) {
+	opts := tagOptions(optFns)
+
+	output, err := b.s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return tags, nil
+}
+
+// Tag adds tags to key, replacing those of the same name and keeping the
+// others. S3 only replaces the tag set as a whole, a concurrent change of the
+// tags between reading and writing them is lost.
+func (b *awsS3Client) Tag(ctx context.Context, key string, tags map[string]string, optFns ...func(*TagOptions)) error {
+	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	current, err := b.Tags(ctx, key, optFns...)
+	if err != nil {
+		return err
+	}
+	maps.Copy(current, tags)
+
+	return b.putTags(ctx, key, current, tagOptions(optFns))
+}
+
+// Untag removes the tags of key with the given names, or all of its tags if
+// no name is given
+func (b *awsS3Client) Untag(ctx context.Context, key string, names []string, optFns ...func(*TagOptions)) error {
+	if b.s3cliConfig.CredentialsSource == config.NoneCredentialsSource {
+		return errorInvalidCredentialsSourceValue
+	}
+
+	opts := tagOptions(optFns)
+	if len(names) == 0 {
+		_, err := b.s3Client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket:    aws.String(b.s3cliConfig.BucketName),
+			Key:       b.key(key),
+			VersionId: versionIDParam(opts.VersionID),
+		})
+		if err == nil {
+			b.logger.InfoContext(ctx, "Removed all tags", "key", key, "bucket", b.s3cliConfig.BucketName)
+		}
+		return err
+	}
+
+	current, err := b.Tags(ctx, key, optFns...)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		delete(current, name)
+	}
+
+	return b.putTags(ctx, key, current, opts)
+}
+
+func (b *awsS3Client) putTags(ctx context.Context, key string, tags map[string]string, opts TagOptions) error {
+	tagSet := make([]types.Tag, 0, len(tags))
+	for _, name := range slices.Sorted(maps.Keys(tags)) {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(name), Value: aws.String(tags[name])})
+	}
+
+	_, err := b.s3Client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:    aws.String(b.s3cliConfig.BucketName),
+		Key:       b.key(key),
+		VersionId: versionIDParam(opts.VersionID),
+		Tagging:   &types.Tagging{TagSet: tagSet},
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.InfoContext(ctx, "Replaced tags", "key", key, "bucket", b.s3cliConfig.BucketName, "tags", len(tagSet))
+	return nil
+}
+
+func tagOptions(optFns []func(*TagOptions)) TagOptions {
+	var opts TagOptions
+	for _, optFn := range optFns {
+		optFn(&opts)
+	}
+
+	return opts
+}
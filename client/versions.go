@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ListVersions calls fn for every version and delete marker of key, the
+// latest first
+func (b *awsS3Client) ListVersions(ctx context.Context, key string, fn func(ObjectVersion) error) error {
+	fullKey := b.key(key)
+	paginator := s3.NewListObjectVersionsPaginator(b.s3Client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(b.s3cliConfig.BucketName),
+		Prefix: fullKey,
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The prefix also matches longer keys, which sort after key
+		var versions []ObjectVersion
+		pastKey := false
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) != *fullKey {
+				pastKey = true
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:          key,
+				VersionID:    aws.ToString(version.VersionId),
+				IsLatest:     aws.ToBool(version.IsLatest),
+				Size:         aws.ToInt64(version.Size),
+				ETag:         strings.Trim(aws.ToString(version.ETag), `"`),
+				LastModified: aws.ToTime(version.LastModified),
+				StorageClass: string(version.StorageClass),
+			})
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) != *fullKey {
+				pastKey = true
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:            key,
+				VersionID:      aws.ToString(marker.VersionId),
+				IsLatest:       aws.ToBool(marker.IsLatest),
+				IsDeleteMarker: true,
+				LastModified:   aws.ToTime(marker.LastModified),
+			})
+		}
+
+		// Versions and delete markers come in separate lists
+		sort.SliceStable(versions, func(i, j int) bool {
+			if versions[i].IsLatest != versions[j].IsLatest {
+				return versions[i].IsLatest
+			}
+			return versions[i].LastModified.After(versions[j].LastModified)
+		})
+
+		for _, version := range versions {
+			if err := fn(version); err != nil {
+				return err
+			}
+		}
+
+		if pastKey {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// deleteAllVersions permanently deletes every version and delete marker of dest
+func (b *awsS3Client) deleteAllVersions(ctx context.Context, dest string, bypassGovernanceRetention bool) error {
+	var versionIDs []string
+	err := b.ListVersions(ctx, dest, func(version ObjectVersion) error {
+		versionIDs = append(versionIDs, version.VersionID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, versionID := range versionIDs {
+		deleteParams := &s3.DeleteObjectInput{
+			Bucket:    aws.String(b.s3cliConfig.BucketName),
+			Key:       b.key(dest),
+			VersionId: aws.String(versionID),
+		}
+		if bypassGovernanceRetention {
+			deleteParams.BypassGovernanceRetention = aws.Bool(true)
+		}
+		if _, err = b.s3Client.DeleteObject(ctx, deleteParams); err != nil {
+			return lockedDeleteError(dest, err)
+		}
+	}
+
+	b.logger.InfoContext(ctx, "Deleted all versions", "key", dest, "versions", len(versionIDs))
+	return nil
+}
+
+// versionIDParam leaves the version of a request unset unless one was asked for
+func versionIDParam(versionID string) *string {
+	if versionID == "" {
+		return nil
+	}
+
+	return aws.String(versionID)
+}
@@ -0,0 +1,155 @@
+package client_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Versions", func() {
+	var server *httptest.Server
+	var requests []string
+	var requestsMutex sync.Mutex
+	var blobstoreClient client.S3CompatibleClient
+
+	BeforeEach(func() {
+		requests = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			query := r.URL.Query()
+			switch {
+			case r.Method == http.MethodGet && query.Has("versions"):
+				requests = append(requests, "ListObjectVersions "+query.Get("prefix"))
+				fmt.Fprint(w, `<ListVersionsResult>`+ //nolint:errcheck
+					`<Version><Key>some-folder/some-key</Key><VersionId>v1</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-01T00:00:00Z</LastModified><Size>3</Size><ETag>"etag-1"</ETag></Version>`+
+					`<Version><Key>some-folder/some-key</Key><VersionId>v2</VersionId><IsLatest>false</IsLatest><LastModified>2024-01-02T00:00:00Z</LastModified><Size>4</Size><ETag>"etag-2"</ETag></Version>`+
+					`<Version><Key>some-folder/some-key-longer</Key><VersionId>v9</VersionId><IsLatest>true</IsLatest><LastModified>2024-01-02T00:00:00Z</LastModified><Size>4</Size></Version>`+
+					`<DeleteMarker><Key>some-folder/some-key</Key><VersionId>v3</VersionId><IsLatest>true</IsLatest><LastModified>2024-01-03T00:00:00Z</LastModified></DeleteMarker>`+
+					`</ListVersionsResult>`)
+			case r.Method == http.MethodGet:
+				requests = append(requests, fmt.Sprintf("GetObject %s %s", r.URL.Path, query.Get("versionId")))
+				w.Header().Set("X-Amz-Version-Id", query.Get("versionId"))
+				fmt.Fprint(w, "old") //nolint:errcheck
+			case r.Method == http.MethodHead:
+				requests = append(requests, fmt.Sprintf("HeadObject %s %s", r.URL.Path, query.Get("versionId")))
+				w.Header().Set("Content-Length", "3")
+			case r.Method == http.MethodPut:
+				requests = append(requests, fmt.Sprintf("CopyObject %s %s", r.URL.Path, r.Header.Get("X-Amz-Copy-Source")))
+				w.Header().Set("X-Amz-Version-Id", "v4")
+				fmt.Fprint(w, `<CopyObjectResult><ETag>"etag-1"</ETag></CopyObjectResult>`) //nolint:errcheck
+			case r.Method == http.MethodDelete:
+				requests = append(requests, fmt.Sprintf("DeleteObject %s %s", r.URL.Path, query.Get("versionId")))
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		}))
+
+		blobstoreClient = newTestClient(server.URL, newTestConfig())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists the versions and delete markers of a key, the latest first", func() {
+		var versions []client.ObjectVersion
+		err := blobstoreClient.ListVersions("some-key", func(version client.ObjectVersion) error {
+			versions = append(versions, version)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{"ListObjectVersions some-folder/some-key"}))
+		Expect(versions).To(Equal([]client.ObjectVersion{
+			{Key: "some-key", VersionID: "v3", IsLatest: true, IsDeleteMarker: true, LastModified: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+			{Key: "some-key", VersionID: "v2", Size: 4, ETag: "etag-2", LastModified: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{Key: "some-key", VersionID: "v1", Size: 3, ETag: "etag-1", LastModified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}))
+	})
+
+	It("fetches a version", func() {
+		var info client.ObjectInfo
+		out := &bytes.Buffer{}
+		err := blobstoreClient.GetStream("some-key", out, func(o *client.GetOptions) {
+			o.VersionID = "v1"
+			o.Result = &info
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(out.String()).To(Equal("old"))
+		Expect(requests).To(Equal([]string{"GetObject /some-bucket/some-folder/some-key v1"}))
+		Expect(info.VersionID).To(Equal("v1"))
+	})
+
+	It("checks whether a version exists", func() {
+		exists, err := blobstoreClient.Exists("some-key", func(o *client.ExistsOptions) {
+			o.VersionID = "v1"
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(exists).To(BeTrue())
+		Expect(requests).To(Equal([]string{"HeadObject /some-bucket/some-folder/some-key v1"}))
+	})
+
+	It("deletes a version", func() {
+		err := blobstoreClient.Delete("some-key", func(o *client.DeleteOptions) {
+			o.VersionID = "v1"
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{"DeleteObject /some-bucket/some-folder/some-key v1"}))
+	})
+
+	It("purges every version and delete marker of a key", func() {
+		err := blobstoreClient.Delete("some-key", func(o *client.DeleteOptions) {
+			o.AllVersions = true
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{
+			"ListObjectVersions some-folder/some-key",
+			"DeleteObject /some-bucket/some-folder/some-key v3",
+			"DeleteObject /some-bucket/some-folder/some-key v2",
+			"DeleteObject /some-bucket/some-folder/some-key v1",
+		}))
+	})
+
+	It("restores a version by copying it onto its own key", func() {
+		var info client.ObjectInfo
+		err := blobstoreClient.Copy("some-key", "some-key", func(o *client.CopyOptions) {
+			o.SourceVersionID = "v1"
+			o.Result = &info
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(Equal([]string{
+			"HeadObject /some-bucket/some-folder/some-key v1",
+			"CopyObject /some-bucket/some-folder/some-key some-bucket/some-folder/some-key?versionId=v1",
+		}))
+		Expect(info.VersionID).To(Equal("v4"))
+	})
+
+	It("signs the GET of a version", func() {
+		signedURL, err := blobstoreClient.Sign("some-key", "get", time.Hour, func(o *client.SignOptions) {
+			o.VersionID = "v1"
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signedURL).To(ContainSubstring("versionId=v1"))
+
+		_, err = blobstoreClient.Sign("some-key", "put", time.Hour, func(o *client.SignOptions) {
+			o.VersionID = "v1"
+		})
+		Expect(err).To(MatchError("only the GET of an object version can be signed"))
+	})
+})
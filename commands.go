@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+)
+
+// commandRunner executes the commands s3cli and its batch command share,
+// once their arguments and flags are parsed into the options of the command
+type commandRunner struct {
+	blobstoreClient client.S3CompatibleClient
+	bucket          string
+	logger          *slog.Logger
+	result          *commandResult
+	// out receives the text output of the command. Without it the command
+	// records its output in result, as it does with -output json.
+	out io.Writer
+	// successCode is the exit status of a command which completed without
+	// error, e.g. exists of a missing blob
+	successCode int
+}
+
+// blobOptions are the arguments and flags of the commands which only name a
+// blob: exists, stat, tags, versions, restore-version and restore-status
+type blobOptions struct {
+	Key       string
+	VersionID string
+}
+
+type putOptions struct {
+	// Src is the path of the file to upload, '-' reads stdin
+	Src                string
+	Dst                string
+	Digests            []client.Digest
+	StoreDigest        bool
+	NoOverwrite        bool
+	IfMatch            string
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	Metadata           map[string]string
+	Tags               map[string]string
+	StorageClass       string
+	Retention          client.Retention
+}
+
+type getOptions struct {
+	Src string
+	// Dst is the path of the file to download to, '-' writes to stdout
+	Dst             string
+	Digests         []client.Digest
+	Resume          bool
+	VersionID       string
+	Range           string
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+	Raw             bool
+}
+
+type deleteOptions struct {
+	Key              string
+	VersionID        string
+	Purge            bool
+	BypassGovernance bool
+}
+
+// deleteManyOptions are the flags of delete -prefix and delete -from-file
+type deleteManyOptions struct {
+	// Prefix selects the blobs to delete, otherwise Keys lists them
+	Prefix      string
+	Keys        []string
+	DryRun      bool
+	Concurrency int
+}
+
+// tagOptions are the arguments and flags of tag, which sets Tags, and untag,
+// which removes the tags named in Names
+type tagOptions struct {
+	Key       string
+	VersionID string
+	Tags      map[string]string
+	Names     []string
+}
+
+type signOptions struct {
+	Key        string
+	Action     string
+	Expiration time.Duration
+	VersionID  string
+}
+
+type copyOptions struct {
+	Src          string
+	Dst          string
+	DestBucket   string
+	StorageClass string
+	// Move deletes Src once it was copied
+	Move bool
+}
+
+type restoreOptions struct {
+	Key       string
+	VersionID string
+	Days      int32
+	Tier      string
+}
+
+// retentionOptions are the arguments and flags of retention, which reports
+// the retention of the blob unless Set is true
+type retentionOptions struct {
+	Key              string
+	VersionID        string
+	Set              bool
+	Retention        client.Retention
+	BypassGovernance bool
+}
+
+// legalHoldOptions are the arguments and flags of legal-hold, which reports
+// the legal hold of the blob unless Set is true
+type legalHoldOptions struct {
+	Key       string
+	VersionID string
+	Set       bool
+	On        bool
+}
+
+type syncOptions struct {
+	Src         string
+	Dst         string
+	Download    bool
+	Delete      bool
+	DryRun      bool
+	SizeOnly    bool
+	Concurrency int
+}
+
+type listOptions struct {
+	Prefix     string
+	Delimiter  string
+	Long       bool
+	PageSize   int32
+	MaxEntries int
+	StartAfter string
+}
+
+func (r *commandRunner) put(ctx context.Context, opts putOptions) error {
+	r.result.setKey(opts.Dst, r.bucket)
+
+	var object client.ObjectInfo
+	putOpts := func(o *client.PutOptions) {
+		o.Digests = opts.Digests
+		o.StoreDigests = opts.StoreDigest
+		o.NoOverwrite = opts.NoOverwrite
+		o.IfMatch = opts.IfMatch
+		o.ContentType = opts.ContentType
+		o.CacheControl = opts.CacheControl
+		o.ContentDisposition = opts.ContentDisposition
+		o.ContentEncoding = opts.ContentEncoding
+		o.Metadata = opts.Metadata
+		o.Tags = opts.Tags
+		o.StorageClass = opts.StorageClass
+		o.Retention = opts.Retention
+		o.Result = &object
+	}
+
+	if opts.Src == "-" {
+		// Hide os.File's Seek, it fails when stdin is a pipe
+		err := r.blobstoreClient.PutWithContext(ctx, struct{ io.Reader }{os.Stdin}, opts.Dst, putOpts)
+		return r.setObject(object, err)
+	}
+
+	sourceFile, err := os.Open(opts.Src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close() //nolint:errcheck
+
+	err = r.blobstoreClient.PutWithContext(ctx, sourceFile, opts.Dst, putOpts)
+	return r.setObject(object, err)
+}
+
+func (r *commandRunner) get(ctx context.Context, opts getOptions) error {
+	r.result.setKey(opts.Src, r.bucket)
+
+	var object client.ObjectInfo
+	getOpts := func(o *client.GetOptions) {
+		o.Resume = opts.Resume
+		o.Digests = opts.Digests
+		o.Result = &object
+		o.VersionID = opts.VersionID
+		o.Range = opts.Range
+		o.IfNoneMatch = opts.IfNoneMatch
+		o.IfModifiedSince = opts.IfModifiedSince
+		o.Raw = opts.Raw
+	}
+
+	if opts.Dst == "-" {
+		err := r.blobstoreClient.GetStreamWithContext(ctx, opts.Src, os.Stdout, getOpts)
+		return r.setObject(object, err)
+	}
+
+	err := r.blobstoreClient.GetFileWithContext(ctx, opts.Src, opts.Dst, getOpts)
+	return r.setObject(object, err)
+}
+
+func (r *commandRunner) delete(ctx context.Context, opts deleteOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	return r.blobstoreClient.DeleteWithContext(ctx, opts.Key, func(o *client.DeleteOptions) {
+		o.VersionID = opts.VersionID
+		o.AllVersions = opts.Purge
+		o.BypassGovernanceRetention = opts.BypassGovernance
+	})
+}
+
+// deleteMany deletes the blobs below a prefix or those listed, and logs and
+// records the keys which could not be deleted
+func (r *commandRunner) deleteMany(ctx context.Context, opts deleteManyOptions) error {
+	r.result.setKey("", r.bucket)
+
+	batchOpts := func(o *client.BatchDeleteOptions) {
+		o.DryRun = opts.DryRun
+		o.Concurrency = opts.Concurrency
+		o.Deleted = func(key string) {
+			r.result.Deleted = append(r.result.Deleted, key)
+			if opts.DryRun && r.out != nil {
+				fmt.Fprintln(r.out, key) //nolint:errcheck
+			}
+		}
+	}
+
+	var err error
+	if opts.Prefix != "" {
+		err = r.blobstoreClient.DeletePrefixWithContext(ctx, opts.Prefix, batchOpts)
+	} else {
+		err = r.blobstoreClient.DeleteKeysWithContext(ctx, opts.Keys, batchOpts)
+	}
+
+	var batchErr *client.BatchDeleteError
+	if errors.As(err, &batchErr) {
+		for _, failure := range batchErr.Failures {
+			r.logger.Error("Deleting key failed", "key", failure.Key, "code", failure.Code, "message", failure.Message)
+			r.result.addDeleteFailure(failure)
+		}
+	}
+	if !opts.DryRun {
+		r.logger.Info("Deleted keys", "count", len(r.result.Deleted))
+	}
+
+	return err
+}
+
+func (r *commandRunner) exists(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	exists, err := r.blobstoreClient.ExistsWithContext(ctx, opts.Key, func(o *client.ExistsOptions) {
+		o.VersionID = opts.VersionID
+	})
+	r.result.Exists = &exists
+
+	// If the object exists the exit status is 0, otherwise it is 3
+	// We are using `3` since `1` and `2` have special meanings
+	if err == nil && !exists {
+		r.successCode = exitCodeNotFound
+	}
+
+	return err
+}
+
+func (r *commandRunner) stat(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	metadata, err := r.blobstoreClient.StatWithContext(ctx, opts.Key, func(o *client.StatOptions) {
+		o.VersionID = opts.VersionID
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.setMetadata(metadata)
+		return nil
+	}
+	return printMetadata(r.out, metadata)
+}
+
+func (r *commandRunner) tags(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	tags, err := r.blobstoreClient.TagsWithContext(ctx, opts.Key, func(o *client.TagOptions) {
+		o.VersionID = opts.VersionID
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.Tags = tags
+		return nil
+	}
+	return printTags(r.out, tags)
+}
+
+func (r *commandRunner) tag(ctx context.Context, opts tagOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	return r.blobstoreClient.TagWithContext(ctx, opts.Key, opts.Tags, func(o *client.TagOptions) {
+		o.VersionID = opts.VersionID
+	})
+}
+
+func (r *commandRunner) untag(ctx context.Context, opts tagOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	return r.blobstoreClient.UntagWithContext(ctx, opts.Key, opts.Names, func(o *client.TagOptions) {
+		o.VersionID = opts.VersionID
+	})
+}
+
+func (r *commandRunner) sign(ctx context.Context, opts signOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+
+	var signedHeaders http.Header
+	signedURL, err := r.blobstoreClient.SignWithContext(ctx, opts.Key, opts.Action, opts.Expiration, func(o *client.SignOptions) {
+		o.VersionID = opts.VersionID
+		o.SignedHeaders = &signedHeaders
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.setSignedURL(signedURL, signedHeaders)
+		return nil
+	}
+
+	// The SSE-C headers follow the URL one per line
+	if _, err = fmt.Fprint(r.out, signedURL); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(signedHeaders)) {
+		if _, err = fmt.Fprintf(r.out, "\n%s: %s", strings.ToLower(name), signedHeaders.Get(name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copy copies, or with opts.Move moves, a blob
+func (r *commandRunner) copy(ctx context.Context, opts copyOptions) error {
+	r.result.setKey(opts.Dst, r.bucket)
+	if opts.DestBucket != "" {
+		r.result.Bucket = opts.DestBucket
+	}
+
+	var object client.ObjectInfo
+	copyOpts := func(o *client.CopyOptions) {
+		o.DestinationBucket = opts.DestBucket
+		o.StorageClass = opts.StorageClass
+		o.Result = &object
+	}
+
+	var err error
+	if opts.Move {
+		err = r.blobstoreClient.MoveWithContext(ctx, opts.Src, opts.Dst, copyOpts)
+	} else {
+		err = r.blobstoreClient.CopyWithContext(ctx, opts.Src, opts.Dst, copyOpts)
+	}
+	return r.setObject(object, err)
+}
+
+func (r *commandRunner) versions(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+
+	return r.blobstoreClient.ListVersionsWithContext(ctx, opts.Key, func(version client.ObjectVersion) error {
+		if r.out == nil {
+			r.result.addVersion(version)
+			return nil
+		}
+		return printVersion(r.out, version)
+	})
+}
+
+// restoreVersion makes opts.VersionID the latest version of the blob
+func (r *commandRunner) restoreVersion(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+
+	var object client.ObjectInfo
+	err := r.blobstoreClient.CopyWithContext(ctx, opts.Key, opts.Key, func(o *client.CopyOptions) {
+		o.SourceVersionID = opts.VersionID
+		o.Result = &object
+	})
+	return r.setObject(object, err)
+}
+
+func (r *commandRunner) restore(ctx context.Context, opts restoreOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	return r.blobstoreClient.RestoreWithContext(ctx, opts.Key, func(o *client.RestoreOptions) {
+		o.VersionID = opts.VersionID
+		o.Days = opts.Days
+		o.Tier = opts.Tier
+	})
+}
+
+func (r *commandRunner) restoreStatus(ctx context.Context, opts blobOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+
+	status, err := r.blobstoreClient.RestoreStatusWithContext(ctx, opts.Key, func(o *client.RestoreOptions) {
+		o.VersionID = opts.VersionID
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.setRestoreStatus(status)
+		return nil
+	}
+	return printRestoreStatus(r.out, status)
+}
+
+func (r *commandRunner) retention(ctx context.Context, opts retentionOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+	lockOptions := func(o *client.ObjectLockOptions) {
+		o.VersionID = opts.VersionID
+		o.BypassGovernanceRetention = opts.BypassGovernance
+	}
+
+	if opts.Set {
+		return r.blobstoreClient.SetRetentionWithContext(ctx, opts.Key, opts.Retention, lockOptions)
+	}
+
+	retention, err := r.blobstoreClient.RetentionWithContext(ctx, opts.Key, lockOptions)
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.setRetention(retention)
+		return nil
+	}
+	return printLines(r.out, [][2]string{
+		{"key", opts.Key},
+		{"bucket", r.bucket},
+		{"version_id", opts.VersionID},
+		{"mode", retention.Mode},
+		{"retain_until", formatTime(retention.RetainUntilDate)},
+	})
+}
+
+func (r *commandRunner) legalHold(ctx context.Context, opts legalHoldOptions) error {
+	r.result.setKey(opts.Key, r.bucket)
+	r.result.VersionID = opts.VersionID
+	lockOptions := func(o *client.ObjectLockOptions) {
+		o.VersionID = opts.VersionID
+	}
+
+	if opts.Set {
+		return r.blobstoreClient.SetLegalHoldWithContext(ctx, opts.Key, opts.On, lockOptions)
+	}
+
+	on, err := r.blobstoreClient.LegalHoldWithContext(ctx, opts.Key, lockOptions)
+	if err != nil {
+		return err
+	}
+
+	if r.out == nil {
+		r.result.LegalHold = &on
+		return nil
+	}
+	return printLines(r.out, [][2]string{
+		{"key", opts.Key},
+		{"bucket", r.bucket},
+		{"version_id", opts.VersionID},
+		{"legal_hold", formatOnOff(on)},
+	})
+}
+
+func (r *commandRunner) sync(ctx context.Context, opts syncOptions) error {
+	syncOpts := func(o *client.SyncOptions) {
+		o.Delete = opts.Delete
+		o.DryRun = opts.DryRun
+		o.SizeOnly = opts.SizeOnly
+		o.Concurrency = opts.Concurrency
+		o.Synced = func(action client.SyncAction) {
+			if r.out == nil {
+				r.result.addSyncAction(action)
+				return
+			}
+			printSyncAction(r.out, action) //nolint:errcheck
+		}
+	}
+
+	if opts.Download {
+		r.result.setKey(opts.Src, r.bucket)
+		return r.blobstoreClient.SyncDownloadWithContext(ctx, opts.Src, opts.Dst, syncOpts)
+	}
+
+	r.result.setKey(opts.Dst, r.bucket)
+	return r.blobstoreClient.SyncUploadWithContext(ctx, opts.Src, opts.Dst, syncOpts)
+}
+
+func (r *commandRunner) list(ctx context.Context, opts listOptions) error {
+	return r.blobstoreClient.ListWithContext(ctx, opts.Prefix, func(entry client.ListEntry) error {
+		if r.out == nil {
+			r.result.addListEntry(entry)
+			return nil
+		}
+		return printListEntry(r.out, entry, opts.Long)
+	}, func(o *client.ListOptions) {
+		o.Delimiter = opts.Delimiter
+		o.PageSize = opts.PageSize
+		o.MaxEntries = opts.MaxEntries
+		o.StartAfter = opts.StartAfter
+	})
+}
+
+// setObject records the object a put, get or copy transferred, unless it
+// failed with err, and returns err
+func (r *commandRunner) setObject(object client.ObjectInfo, err error) error {
+	if err == nil && object.Key != "" {
+		r.result.setObject(object)
+	}
+
+	return err
+}
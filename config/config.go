@@ -1,10 +1,13 @@
 package config
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -37,6 +40,60 @@ type S3Cli struct {
 	DownloadPartSize    int64 `json:"download_part_size"`
 	UploadConcurrency   int   `json:"upload_concurrency"`
 	UploadPartSize      int64 `json:"upload_part_size"`
+	// UploadStateDir enables resumable multipart uploads of files. The progress
+	// of each upload is kept in this directory until the upload completed.
+	UploadStateDir string `json:"upload_state_dir"`
+	// Retry policy applied to every request. Zero values keep the defaults of
+	// the client layer: 3 attempts with a backoff growing from 1s up to 20s,
+	// retrying 500, 502, 503 and 504 responses as well as throttling errors
+	// such as 503 SlowDown. Backoff values are provided in milliseconds.
+	// AttemptTimeoutSeconds limits the wait for the response headers of each
+	// attempt, the download of a response body is not limited by it.
+	RetryMaxAttempts      int   `json:"retry_max_attempts"`
+	RetryBaseBackoff      int64 `json:"retry_base_backoff_ms"`
+	RetryMaxBackoff       int64 `json:"retry_max_backoff_ms"`
+	RetryStatusCodes      []int `json:"retry_status_codes"`
+	AttemptTimeoutSeconds int   `json:"attempt_timeout_seconds"`
+	// OperationTimeoutSeconds limits each operation, e.g. a put, including
+	// all of its requests and retries. Zero means no limit.
+	OperationTimeoutSeconds int `json:"operation_timeout_seconds"`
+	// LogLevel is one of debug, info, warn or error, defaults to info. The
+	// debug level also logs a summary of every request and response.
+	LogLevel string `json:"log_level"`
+	// LogFormat is text or json, defaults to text
+	LogFormat string `json:"log_format"`
+	// IfNoneMatch makes put refuse to overwrite existing blobs, as its
+	// -no-overwrite flag does
+	IfNoneMatch bool `json:"if_none_match"`
+	// Defaults of the content headers, user metadata and tags of uploaded
+	// blobs, which the flags of put extend or override. The content type is
+	// detected from the extension of the blob if unset.
+	ContentType        string            `json:"content_type"`
+	CacheControl       string            `json:"cache_control"`
+	ContentDisposition string            `json:"content_disposition"`
+	ContentEncoding    string            `json:"content_encoding"`
+	Metadata           map[string]string `json:"metadata"`
+	Tags               map[string]string `json:"tags"`
+	// Client-side encryption of blobs, with data keys wrapped either by a
+	// 256-bit key read from a local file, raw, hex or base64 encoded, or by
+	// an AWS KMS key. Encrypted blobs are decrypted transparently on get.
+	ClientSideEncryptionKeyFile  string `json:"client_side_encryption_key_file"`
+	ClientSideEncryptionKMSKeyID string `json:"client_side_encryption_kms_key_id"`
+	// SSE-C encryption of blobs with a base64 encoded 256-bit key, which the
+	// blobstore does not store. SSECustomerKeyFile is read into SSECustomerKey.
+	SSECustomerKey     string `json:"sse_customer_key"`
+	SSECustomerKeyFile string `json:"sse_customer_key_file"`
+	// Compression of uploaded blobs, gzip or zstd. Compressed blobs are
+	// decompressed transparently on get.
+	Compression string `json:"compression"`
+	// StorageClass of uploaded and copied blobs, e.g. STANDARD_IA or GLACIER.
+	// The blobstore picks its default, STANDARD on AWS, if unset.
+	StorageClass string `json:"storage_class"`
+	// Object Lock retention of uploaded blobs, in GOVERNANCE or COMPLIANCE
+	// mode for object_lock_retain_days from the upload. The bucket must have
+	// Object Lock enabled.
+	ObjectLockMode       string `json:"object_lock_mode"`
+	ObjectLockRetainDays int    `json:"object_lock_retain_days"`
 }
 
 const defaultAWSRegion = "us-east-1"
@@ -50,6 +107,29 @@ const NoneCredentialsSource = "none"
 
 const credentialsSourceEnvOrProfile = "env_or_profile"
 
+// Log levels and formats accepted by log_level and log_format
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Algorithms accepted by compression
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Modes accepted by object_lock_mode
+const (
+	ObjectLockModeGovernance = "GOVERNANCE"
+	ObjectLockModeCompliance = "COMPLIANCE"
+)
+
 // Nothing was provided in configuration
 const noCredentialsSourceProvided = ""
 
@@ -98,6 +178,34 @@ func NewFromReader(reader io.Reader) (S3Cli, error) {
 	if c.DownloadConcurrency < 0 || c.UploadConcurrency < 0 || c.DownloadPartSize < 0 || c.UploadPartSize < 0 {
 		return S3Cli{}, errors.New("download/upload concurrency and part sizes must be non-negative")
 	}
+	if c.RetryMaxAttempts < 0 || c.RetryBaseBackoff < 0 || c.RetryMaxBackoff < 0 || c.AttemptTimeoutSeconds < 0 {
+		return S3Cli{}, errors.New("retry attempts, backoffs and attempt timeout must be non-negative")
+	}
+	if c.OperationTimeoutSeconds < 0 {
+		return S3Cli{}, errors.New("operation_timeout_seconds must be non-negative")
+	}
+	for _, code := range c.RetryStatusCodes {
+		if code < 100 || code > 599 {
+			return S3Cli{}, fmt.Errorf("invalid retry status code: %d", code)
+		}
+	}
+	if err = c.ValidateLogging(); err != nil {
+		return S3Cli{}, err
+	}
+	if c.ClientSideEncryptionKeyFile != "" && c.ClientSideEncryptionKMSKeyID != "" {
+		return S3Cli{}, errors.New("client_side_encryption_key_file and client_side_encryption_kms_key_id can't be used together")
+	}
+	if err = c.loadSSECustomerKey(); err != nil {
+		return S3Cli{}, err
+	}
+	switch c.Compression {
+	case "", CompressionGzip, CompressionZstd:
+	default:
+		return S3Cli{}, fmt.Errorf("invalid compression '%s', must be gzip or zstd", c.Compression)
+	}
+	if err = c.validateObjectLock(); err != nil {
+		return S3Cli{}, err
+	}
 
 	switch c.CredentialsSource {
 	case StaticCredentialsSource:
@@ -210,6 +318,83 @@ func (c *S3Cli) IsGoogle() bool {
 	return Provider(c.Host) == "google"
 }
 
+// SupportsConditionalWrites tells whether the provider evaluates If-None-Match
+// and If-Match on PutObject and CompleteMultipartUpload. Alibaba Cloud OSS and
+// Google Cloud Storage ignore them in their S3 APIs.
+func (c *S3Cli) SupportsConditionalWrites() bool {
+	switch Provider(c.Host) {
+	case "alicloud", "google":
+		return false
+	default:
+		return true
+	}
+}
+
+// loadSSECustomerKey reads sse_customer_key_file and makes sure the key suits
+// SSE-C, which replaces the other kinds of server-side encryption
+func (c *S3Cli) loadSSECustomerKey() error {
+	switch {
+	case c.SSECustomerKeyFile != "" && c.SSECustomerKey != "":
+		return errors.New("sse_customer_key and sse_customer_key_file can't be used together")
+	case c.SSECustomerKeyFile != "":
+		key, err := ReadKeyFile(c.SSECustomerKeyFile)
+		if err != nil {
+			return err
+		}
+		c.SSECustomerKey = base64.StdEncoding.EncodeToString(key)
+	case c.SSECustomerKey != "":
+		if key, err := base64.StdEncoding.DecodeString(c.SSECustomerKey); err != nil || len(key) != 32 {
+			return errors.New("sse_customer_key must be a base64 encoded 256-bit key")
+		}
+	default:
+		return nil
+	}
+
+	if c.ServerSideEncryption != "" || c.SSEKMSKeyID != "" {
+		return errors.New("sse_customer_key can't be used with server_side_encryption or sse_kms_key_id")
+	}
+	return nil
+}
+
+// validateObjectLock checks object_lock_mode and object_lock_retain_days. A
+// mode without days leaves the retain-until date to the flags of put.
+func (c *S3Cli) validateObjectLock() error {
+	switch c.ObjectLockMode {
+	case "", ObjectLockModeGovernance, ObjectLockModeCompliance:
+	default:
+		return fmt.Errorf("invalid object_lock_mode '%s', must be GOVERNANCE or COMPLIANCE", c.ObjectLockMode)
+	}
+
+	if c.ObjectLockRetainDays < 0 {
+		return errors.New("object_lock_retain_days must be non-negative")
+	}
+	if c.ObjectLockRetainDays > 0 && c.ObjectLockMode == "" {
+		return errors.New("object_lock_retain_days requires object_lock_mode")
+	}
+	return nil
+}
+
+// ReadKeyFile reads a 256-bit key, hex or base64 encoded or as raw bytes
+func ReadKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	encoded := strings.TrimSpace(string(content))
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if len(content) == 32 {
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("key file '%s' must hold 32 bytes, raw, hex or base64 encoded", path)
+}
+
 func (c *S3Cli) ShouldDisableRequestChecksumCalculation() bool {
 	return !c.RequestChecksumCalculationEnabled
 }
@@ -221,3 +406,21 @@ func (c *S3Cli) ShouldDisableResponseChecksumCalculation() bool {
 func (c *S3Cli) ShouldDisableUploaderRequestChecksumCalculation() bool {
 	return !c.UploaderRequestChecksumCalculationEnabled
 }
+
+// ValidateLogging checks log_level and log_format, which may also be set
+// from the command line after the configuration was read
+func (c *S3Cli) ValidateLogging() error {
+	switch c.LogLevel {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		return fmt.Errorf("invalid log_level '%s', must be one of debug, info, warn or error", c.LogLevel)
+	}
+
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("invalid log_format '%s', must be text or json", c.LogFormat)
+	}
+
+	return nil
+}
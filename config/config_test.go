@@ -2,7 +2,11 @@ package config_test
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/cloudfoundry/bosh-s3cli/config"
 
@@ -25,6 +29,17 @@ var _ = Describe("BlobstoreClient configuration", func() {
 		Entry("google 1", "storage.googleapis.com", "google"),
 	)
 
+	DescribeTable("SupportsConditionalWrites",
+		func(host string, supported bool) {
+			c := config.S3Cli{Host: host}
+			Expect(c.SupportsConditionalWrites()).To(Equal(supported))
+		},
+		Entry("aws", "s3.amazonaws.com", true),
+		Entry("alicloud", "oss-r-s-1.aliyuncs.com", false),
+		Entry("google", "storage.googleapis.com", false),
+		Entry("other", "my-s3-compatible.example.com", true),
+	)
+
 	Describe("building a configuration", func() {
 		Describe("checking that either host or region has been set", func() {
 
@@ -341,6 +356,193 @@ var _ = Describe("BlobstoreClient configuration", func() {
 		})
 	})
 
+	Describe("resumable uploads", func() {
+		It("are disabled unless upload_state_dir is set", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.UploadStateDir).To(BeEmpty())
+		})
+
+		It("keeps their state in upload_state_dir", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","upload_state_dir":"/var/vcap/data/s3cli"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.UploadStateDir).To(Equal("/var/vcap/data/s3cli"))
+		})
+	})
+
+	Describe("retry policy", func() {
+		It("reads the retry settings", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket",
+				"retry_max_attempts":5,"retry_base_backoff_ms":200,"retry_max_backoff_ms":5000,
+				"retry_status_codes":[500,503],"attempt_timeout_seconds":30}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.RetryMaxAttempts).To(Equal(5))
+			Expect(c.RetryBaseBackoff).To(Equal(int64(200)))
+			Expect(c.RetryMaxBackoff).To(Equal(int64(5000)))
+			Expect(c.RetryStatusCodes).To(Equal([]int{500, 503}))
+			Expect(c.AttemptTimeoutSeconds).To(Equal(30))
+		})
+
+		It("rejects negative values and invalid status codes", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","retry_max_attempts":-1}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("retry attempts, backoffs and attempt timeout must be non-negative"))
+
+			dummyJSONBytes = []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","retry_status_codes":[5030]}`)
+			dummyJSONReader = bytes.NewReader(dummyJSONBytes)
+
+			_, err = config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("invalid retry status code: 5030"))
+		})
+	})
+
+	Describe("operation timeout", func() {
+		It("is disabled by default", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.OperationTimeoutSeconds).To(BeZero())
+		})
+
+		It("rejects negative values", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","operation_timeout_seconds":-1}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("operation_timeout_seconds must be non-negative"))
+		})
+	})
+
+	Describe("logging", func() {
+		It("accepts the supported levels and formats", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","log_level":"debug","log_format":"json"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.LogLevel).To(Equal(config.LogLevelDebug))
+			Expect(c.LogFormat).To(Equal(config.LogFormatJSON))
+		})
+
+		It("rejects unknown levels", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","log_level":"trace"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("invalid log_level 'trace', must be one of debug, info, warn or error"))
+		})
+
+		It("rejects unknown formats", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","log_format":"xml"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("invalid log_format 'xml', must be text or json"))
+		})
+	})
+
+	Describe("client-side encryption", func() {
+		It("rejects both a key file and a KMS key", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","client_side_encryption_key_file":"/some/key","client_side_encryption_kms_key_id":"alias/some-key"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("client_side_encryption_key_file and client_side_encryption_kms_key_id can't be used together"))
+		})
+	})
+
+	Describe("SSE-C", func() {
+		It("reads the key file", func() {
+			keyFile := filepath.Join(GinkgoT().TempDir(), "key")
+			Expect(os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)+"\n"), 0600)).To(Succeed())
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","sse_customer_key_file":"` + keyFile + `"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.SSECustomerKey).To(Equal(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xab}, 32))))
+		})
+
+		It("rejects keys of another length", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","sse_customer_key":"c2hvcnQ="}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("sse_customer_key must be a base64 encoded 256-bit key"))
+		})
+
+		It("rejects other kinds of server-side encryption", func() {
+			key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xab}, 32))
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","sse_customer_key":"` + key + `","server_side_encryption":"AES256"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("sse_customer_key can't be used with server_side_encryption or sse_kms_key_id"))
+		})
+	})
+
+	Describe("compression", func() {
+		It("accepts gzip and zstd", func() {
+			for _, compression := range []string{"gzip", "zstd"} {
+				dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","compression":"` + compression + `"}`)
+				dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+				c, err := config.NewFromReader(dummyJSONReader)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(c.Compression).To(Equal(compression))
+			}
+		})
+
+		It("rejects other algorithms", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","compression":"brotli"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("invalid compression 'brotli', must be gzip or zstd"))
+		})
+	})
+
+	Describe("object lock", func() {
+		It("accepts a mode and a retention period", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","object_lock_mode":"COMPLIANCE","object_lock_retain_days":30}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			c, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.ObjectLockMode).To(Equal(config.ObjectLockModeCompliance))
+			Expect(c.ObjectLockRetainDays).To(Equal(30))
+		})
+
+		It("rejects other modes", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","object_lock_mode":"forever"}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("invalid object_lock_mode 'forever', must be GOVERNANCE or COMPLIANCE"))
+		})
+
+		It("rejects a retention period without a mode", func() {
+			dummyJSONBytes := []byte(`{"access_key_id":"id","secret_access_key":"key","bucket_name":"some-bucket","object_lock_retain_days":30}`)
+			dummyJSONReader := bytes.NewReader(dummyJSONBytes)
+
+			_, err := config.NewFromReader(dummyJSONReader)
+			Expect(err).To(MatchError("object_lock_retain_days requires object_lock_mode"))
+		})
+	})
+
 	Describe("returning the S3 endpoint", func() {
 		Context("when port is provided", func() {
 			It("returns a URI in the form `host:port`", func() {
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+)
+
+// Exit statuses of s3cli. 1 is used for any other error and 2 for invalid
+// flags, as the flag package does.
+const (
+	exitCodeFailure = 1
+	// exitCodeUsage is also the status of an invalid batch request
+	exitCodeUsage = 2
+	// exitCodeNotFound is also the status of exists for a missing blob
+	exitCodeNotFound         = 3
+	exitCodeAccessDenied     = 4
+	exitCodeThrottled        = 5
+	exitCodeChecksumMismatch = 6
+	exitCodeReadOnlyMode     = 7
+	exitCodeInvalidConfig    = 8
+	exitCodeTimeout          = 9
+	// exitCodePreconditionFailed is the status of a put refused by
+	// -no-overwrite or -if-match
+	exitCodePreconditionFailed = 10
+	// exitCodeNotModified is the status of a get skipped by -if-none-match
+	// or -if-modified-since
+	exitCodeNotModified = 11
+	// exitCodeArchived is the status of a get of an archived blob, which
+	// has to be restored first
+	exitCodeArchived = 12
+	// exitCodeObjectLocked is the status of a delete refused by an Object
+	// Lock retention or legal hold
+	exitCodeObjectLocked = 13
+	// exitCodeInterrupted is the exit status after SIGINT cancelled the
+	// operation. Like in shells, a signal exits with 128 plus its number,
+	// e.g. SIGTERM with 143.
+	exitCodeInterrupted = 130
+)
+
+var exitCodesByKind = []struct {
+	kind error
+	code int
+}{
+	{client.ErrNotFound, exitCodeNotFound},
+	{client.ErrAccessDenied, exitCodeAccessDenied},
+	{client.ErrThrottled, exitCodeThrottled},
+	{client.ErrChecksumMismatch, exitCodeChecksumMismatch},
+	{client.ErrReadOnlyMode, exitCodeReadOnlyMode},
+	{client.ErrInvalidConfig, exitCodeInvalidConfig},
+	{client.ErrTimeout, exitCodeTimeout},
+	{client.ErrPreconditionFailed, exitCodePreconditionFailed},
+	{client.ErrNotModified, exitCodeNotModified},
+	{client.ErrArchived, exitCodeArchived},
+	{client.ErrObjectLocked, exitCodeObjectLocked},
+	{client.ErrInvalidArgument, exitCodeUsage},
+	{errUsage, exitCodeUsage},
+	{errInvalidBatchRequest, exitCodeUsage},
+}
+
+// errUsage is the kind of an invalid command line, e.g. a missing argument
+// or an invalid flag value
+var errUsage = errors.New("invalid usage")
+
+// exitCode is the exit status of a command which failed with err
+func exitCode(err error) int {
+	for _, kindCode := range exitCodesByKind {
+		if errors.Is(err, kindCode.kind) {
+			return kindCode.code
+		}
+	}
+
+	return exitCodeFailure
+}
+
+// signalError is the cause of the cancellation of an operation by a signal
+type signalError struct {
+	signal syscall.Signal
+}
+
+func (e *signalError) Error() string {
+	return "received " + e.signal.String()
+}
+
+// interruptedExitCode is the exit status of an operation interrupted by the
+// cancellation of ctx, which depends on the signal which cancelled it
+func interruptedExitCode(ctx context.Context) int {
+	var signalErr *signalError
+	if errors.As(context.Cause(ctx), &signalErr) {
+		return 128 + int(signalErr.signal)
+	}
+
+	return exitCodeInterrupted
+}
+
+// invalidConfig classifies an error reading the configuration
+func invalidConfig(err error) error {
+	return &client.Error{Kind: client.ErrInvalidConfig, Err: err}
+}
+
+// usageError classifies an invalid command line
+func usageError(err error) error {
+	return &client.Error{Kind: errUsage, Err: err}
+}
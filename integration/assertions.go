@@ -2,11 +2,17 @@ package integration
 
 import (
 	"context"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -16,7 +22,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	. "github.com/onsi/gomega" //nolint:staticcheck
+	. "github.com/onsi/ginkgo/v2" //nolint:staticcheck
+	. "github.com/onsi/gomega"    //nolint:staticcheck
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
 )
 
 var (
@@ -75,7 +84,7 @@ func AssertLifecycleWorks(s3CLIPath string, cfg *config.S3Cli) {
 	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
 	Expect(err).ToNot(HaveOccurred())
 	Expect(s3CLISession.ExitCode()).To(BeZero())
-	Expect(s3CLISession.Err.Contents()).To(MatchRegexp("File '.*' exists in bucket '.*'"))
+	Expect(s3CLISession.Err.Contents()).To(MatchRegexp("msg=\"File exists in bucket\" key=.* bucket=.*"))
 
 	tmpLocalFile, err := os.CreateTemp("", "s3cli-download")
 	Expect(err).ToNot(HaveOccurred())
@@ -98,7 +107,7 @@ func AssertLifecycleWorks(s3CLIPath string, cfg *config.S3Cli) {
 	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
 	Expect(err).ToNot(HaveOccurred())
 	Expect(s3CLISession.ExitCode()).To(Equal(3))
-	Expect(s3CLISession.Err.Contents()).To(MatchRegexp("File '.*' does not exist in bucket '.*'"))
+	Expect(s3CLISession.Err.Contents()).To(MatchRegexp("msg=\"File does not exist in bucket\" key=.* bucket=.*"))
 }
 
 func AssertOnPutFailures(cfg *config.S3Cli, content, errorMessage string) {
@@ -176,7 +185,7 @@ func AssertGetNonexistentFails(s3CLIPath string, cfg *config.S3Cli) {
 
 	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "get", "non-existent-file", "/dev/null")
 	Expect(err).ToNot(HaveOccurred())
-	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+	Expect(s3CLISession.ExitCode()).To(Equal(3))
 	Expect(s3CLISession.Err.Contents()).To(ContainSubstring("NoSuchKey"))
 }
 
@@ -298,3 +307,1013 @@ func AssertOnSignedURLs(s3CLIPath string, cfg *config.S3Cli) {
 	_, err = RunS3CLI(s3CLIPath, configPath, "delete", s3Filename+"_put_test")
 	Expect(err).ToNot(HaveOccurred())
 }
+
+// AssertListWorks asserts that `s3cli list` returns the keys below a prefix,
+// relative to the configured folder
+func AssertListWorks(s3CLIPath string, cfg *config.S3Cli) {
+	prefix := GenerateRandomString()
+	s3Filenames := []string{
+		fmt.Sprintf("%s/a", prefix),
+		fmt.Sprintf("%s/b", prefix),
+		fmt.Sprintf("%s/sub/c", prefix),
+	}
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(GenerateRandomString())
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	for _, s3Filename := range s3Filenames {
+		s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s3CLISession.ExitCode()).To(BeZero())
+		defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+	}
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "list", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(strings.Fields(string(s3CLISession.Out.Contents()))).To(Equal(s3Filenames))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "list", "-delimiter", "/", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(strings.Fields(string(s3CLISession.Out.Contents()))).To(ConsistOf(
+		fmt.Sprintf("%s/a", prefix),
+		fmt.Sprintf("%s/b", prefix),
+		fmt.Sprintf("%s/sub/", prefix),
+	))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "list", "-page-size", "1", "-max", "2", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(strings.Fields(string(s3CLISession.Out.Contents()))).To(Equal(s3Filenames[:2]))
+}
+
+// AssertBatchDeleteWorks asserts that `s3cli delete -prefix` and
+// `s3cli delete -from-file` remove several blobs at once
+func AssertBatchDeleteWorks(s3CLIPath string, cfg *config.S3Cli) {
+	prefix := GenerateRandomString()
+	s3Filenames := []string{
+		fmt.Sprintf("%s/a", prefix),
+		fmt.Sprintf("%s/b", prefix),
+		fmt.Sprintf("%s/sub/c", prefix),
+	}
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(GenerateRandomString())
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	for _, s3Filename := range s3Filenames {
+		s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s3CLISession.ExitCode()).To(BeZero())
+	}
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "delete", "-prefix", prefix+"/", "-dry-run")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(strings.Fields(string(s3CLISession.Out.Contents()))).To(Equal(s3Filenames))
+
+	keysFile := MakeContentFile(s3Filenames[0] + "\n")
+	defer os.Remove(keysFile) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "delete", "-from-file", keysFile)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "list", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(strings.Fields(string(s3CLISession.Out.Contents()))).To(Equal(s3Filenames[1:]))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "delete", "-prefix", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "list", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.Out.Contents()).To(BeEmpty())
+}
+
+// AssertSyncWorks asserts that `s3cli sync` uploads and downloads only the
+// files which differ, and deletes extra ones with -delete
+func AssertSyncWorks(s3CLIPath string, cfg *config.S3Cli) {
+	prefix := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	uploadDir, err := os.MkdirTemp("", "s3cli-sync-upload")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(uploadDir) //nolint:errcheck
+	downloadDir, err := os.MkdirTemp("", "s3cli-sync-download")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(downloadDir) //nolint:errcheck
+
+	Expect(os.MkdirAll(filepath.Join(uploadDir, "sub"), 0755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(uploadDir, "a"), []byte(GenerateRandomString()), 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(uploadDir, "sub", "b"), []byte(GenerateRandomString()), 0644)).To(Succeed())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", "-prefix", prefix+"/") //nolint:errcheck
+
+	syncOutput := func(args ...string) []string {
+		s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "sync", args...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s3CLISession.ExitCode()).To(BeZero())
+
+		lines := strings.Split(strings.TrimSpace(string(s3CLISession.Out.Contents())), "\n")
+		if len(lines) == 1 && lines[0] == "" {
+			return nil
+		}
+		return lines
+	}
+
+	Expect(syncOutput(uploadDir, prefix)).To(ConsistOf(
+		fmt.Sprintf("upload\t%s\t%s/a", filepath.Join(uploadDir, "a"), prefix),
+		fmt.Sprintf("upload\t%s\t%s/sub/b", filepath.Join(uploadDir, "sub", "b"), prefix),
+	))
+	Expect(syncOutput(uploadDir, prefix)).To(BeEmpty())
+
+	Expect(syncOutput("-download", prefix, downloadDir)).To(HaveLen(2))
+	for _, rel := range []string{"a", filepath.Join("sub", "b")} {
+		uploaded, err := os.ReadFile(filepath.Join(uploadDir, rel))
+		Expect(err).ToNot(HaveOccurred())
+		downloaded, err := os.ReadFile(filepath.Join(downloadDir, rel))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(downloaded).To(Equal(uploaded))
+	}
+
+	Expect(os.Remove(filepath.Join(uploadDir, "a"))).To(Succeed())
+	Expect(syncOutput("-delete", "-dry-run", uploadDir, prefix)).To(Equal([]string{"delete\t" + prefix + "/a"}))
+	Expect(syncOutput("-delete", uploadDir, prefix)).To(Equal([]string{"delete\t" + prefix + "/a"}))
+	Expect(syncOutput("-download", "-delete", prefix, downloadDir)).To(Equal([]string{"delete\t" + filepath.Join(downloadDir, "a")}))
+}
+
+// AssertCopyAndMoveWork asserts that `s3cli copy` duplicates and `s3cli move`
+// relocates a blob without downloading it
+func AssertCopyAndMoveWork(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString()
+	s3Filename := GenerateRandomString()
+	copyFilename := s3Filename + "_copy"
+	moveFilename := s3Filename + "_move"
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "copy", s3Filename, copyFilename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", copyFilename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "move", copyFilename, moveFilename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", moveFilename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", copyFilename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(3))
+
+	tmpLocalFile, err := os.CreateTemp("", "s3cli-download")
+	Expect(err).ToNot(HaveOccurred())
+	err = tmpLocalFile.Close()
+	Expect(err).ToNot(HaveOccurred())
+	defer os.Remove(tmpLocalFile.Name()) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", moveFilename, tmpLocalFile.Name())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	gottenBytes, err := os.ReadFile(tmpLocalFile.Name())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(gottenBytes)).To(Equal(expectedString))
+}
+
+// AssertStreamingWorks asserts that `s3cli put` reads from stdin and
+// `s3cli get` writes to stdout when the path is '-'
+func AssertStreamingWorks(s3CLIPath string, cfg *config.S3Cli, content string) {
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	command := exec.Command(s3CLIPath, "-c", configPath, "put", "-", s3Filename)
+	command.Stdin = strings.NewReader(content)
+	s3CLISession, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+	Expect(err).ToNot(HaveOccurred())
+	s3CLISession.Wait(1 * time.Minute)
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents()) == content).To(BeTrue(), "streamed content differs from the uploaded content")
+}
+
+// AssertBatchWorks asserts that `s3cli batch` executes the JSON-lines
+// requests read from stdin and prints a JSON-lines result for each of them
+func AssertBatchWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString()
+	prefix := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	downloadDir, err := os.MkdirTemp("", "s3cli-batch")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(downloadDir) //nolint:errcheck
+
+	type result struct {
+		ID       string `json:"id"`
+		Command  string `json:"command"`
+		Key      string `json:"key"`
+		Exists   *bool  `json:"exists"`
+		Error    string `json:"error"`
+		ExitCode int    `json:"exit_code"`
+	}
+	runBatch := func(requests ...map[string]any) (map[string]result, int) {
+		var stdin strings.Builder
+		for _, request := range requests {
+			line, err := json.Marshal(request)
+			Expect(err).ToNot(HaveOccurred())
+			stdin.Write(line)       //nolint:errcheck
+			stdin.WriteString("\n") //nolint:errcheck
+		}
+
+		command := exec.Command(s3CLIPath, "-c", configPath, "batch", "-parallelism", "2")
+		command.Stdin = strings.NewReader(stdin.String())
+		s3CLISession, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).ToNot(HaveOccurred())
+		s3CLISession.Wait(1 * time.Minute)
+
+		results := map[string]result{}
+		for _, line := range strings.Split(strings.TrimSpace(string(s3CLISession.Out.Contents())), "\n") {
+			var r result
+			Expect(json.Unmarshal([]byte(line), &r)).To(Succeed())
+			results[r.ID] = r
+		}
+		Expect(results).To(HaveLen(len(requests)))
+		return results, s3CLISession.ExitCode()
+	}
+
+	var putRequests []map[string]any
+	for i := 0; i < 3; i++ {
+		putRequests = append(putRequests, map[string]any{
+			"id": fmt.Sprintf("put-%d", i), "op": "put", "src": contentFile, "dst": fmt.Sprintf("%s/%d", prefix, i),
+		})
+	}
+	results, exitCode := runBatch(putRequests...)
+	Expect(exitCode).To(BeZero())
+	for _, r := range results {
+		Expect(r.ExitCode).To(BeZero())
+		Expect(r.Error).To(BeEmpty())
+	}
+
+	results, exitCode = runBatch(
+		map[string]any{"id": "get", "op": "get", "src": prefix + "/0", "dst": filepath.Join(downloadDir, "0")},
+		map[string]any{"id": "exists", "op": "exists", "src": prefix + "/1"},
+		map[string]any{"id": "missing", "op": "exists", "src": prefix + "/missing"},
+		map[string]any{"id": "invalid", "op": "unknown", "src": prefix + "/2"},
+	)
+	Expect(exitCode).To(Equal(1))
+	Expect(results["get"].ExitCode).To(BeZero())
+	Expect(results["get"].Key).To(Equal(prefix + "/0"))
+	Expect(*results["exists"].Exists).To(BeTrue())
+	Expect(*results["missing"].Exists).To(BeFalse())
+	Expect(results["missing"].ExitCode).To(Equal(3))
+	Expect(results["invalid"].ExitCode).To(Equal(2))
+	Expect(results["invalid"].Error).To(ContainSubstring("unknown op"))
+
+	gottenBytes, err := os.ReadFile(filepath.Join(downloadDir, "0"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(gottenBytes)).To(Equal(expectedString))
+
+	var deleteRequests []map[string]any
+	for i := 0; i < 3; i++ {
+		deleteRequests = append(deleteRequests, map[string]any{
+			"id": fmt.Sprintf("delete-%d", i), "op": "delete", "src": fmt.Sprintf("%s/%d", prefix, i),
+		})
+	}
+	_, exitCode = runBatch(deleteRequests...)
+	Expect(exitCode).To(BeZero())
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "list", prefix+"/")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.Out.Contents()).To(BeEmpty())
+}
+
+// AssertServeWorks asserts that `s3cli serve` exposes GET, HEAD, PUT and
+// DELETE of the blobs over HTTP, also to the DAV blobstore client
+func AssertServeWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	authFile := MakeContentFile("user:secret\n")
+	defer os.Remove(authFile) //nolint:errcheck
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	address := listener.Addr().String()
+	Expect(listener.Close()).To(Succeed())
+
+	command := exec.Command(s3CLIPath, "-c", configPath, "serve", "-listen", address, "-basic-auth-file", authFile, "-dav")
+	s3CLISession, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+	Expect(err).ToNot(HaveOccurred())
+	defer s3CLISession.Kill()
+	Eventually(s3CLISession.Err, 10*time.Second).Should(gbytes.Say("Serving blobs"))
+
+	digest := sha1.Sum([]byte(s3Filename)) //nolint:gosec
+	blobURL := fmt.Sprintf("http://%s/%02x/%s", address, digest[0], s3Filename)
+	request := func(method string, body io.Reader, header ...string) *http.Response {
+		req, err := http.NewRequest(method, blobURL, body)
+		Expect(err).ToNot(HaveOccurred())
+		req.SetBasicAuth("user", "secret")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		return resp
+	}
+	readBody := func(resp *http.Response) string {
+		defer resp.Body.Close() //nolint:errcheck
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	resp, err := http.Get(blobURL)
+	Expect(err).ToNot(HaveOccurred())
+	readBody(resp)
+	Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+	resp = request(http.MethodPut, strings.NewReader(expectedString))
+	readBody(resp)
+	Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+	s3CLIGetSession, err := RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(s3CLIGetSession.Out.Contents())).To(Equal(expectedString))
+
+	resp = request(http.MethodHead, nil)
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	resp = request(http.MethodGet, nil)
+	Expect(readBody(resp)).To(Equal(expectedString))
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	resp = request(http.MethodGet, nil, "Range", "bytes=10-19")
+	Expect(readBody(resp)).To(Equal(expectedString[10:20]))
+	Expect(resp.StatusCode).To(Equal(http.StatusPartialContent))
+	Expect(resp.Header.Get("Content-Range")).To(Equal("bytes 10-19/1024"))
+
+	resp = request(http.MethodDelete, nil)
+	readBody(resp)
+	Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+	resp = request(http.MethodGet, nil)
+	readBody(resp)
+	Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+	s3CLISession.Interrupt()
+	Eventually(s3CLISession, 10*time.Second).Should(gexec.Exit(0))
+}
+
+// AssertResumableUploadWorks asserts that a put interrupted by failing parts
+// only uploads the missing parts when it is run again
+func AssertResumableUploadWorks(s3CLIPath string, cfg *config.S3Cli, content string) {
+	s3Filename := GenerateRandomString()
+
+	stateDir, err := os.MkdirTemp("", "s3cli-upload-state")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(stateDir) //nolint:errcheck
+	cfg.UploadStateDir = stateDir
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(content)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	configFile, err := os.Open(configPath)
+	Expect(err).ToNot(HaveOccurred())
+
+	s3Config, err := config.NewFromReader(configFile)
+	Expect(err).ToNot(HaveOccurred())
+
+	// Every second part fails, the first one makes it
+	failingS3Client, err := CreateS3ClientWithFailureInjection(&s3Config)
+	Expect(err).ToNot(HaveOccurred())
+
+	sourceFile, err := os.Open(contentFile)
+	Expect(err).ToNot(HaveOccurred())
+	err = client.New(failingS3Client, &s3Config).Put(sourceFile, s3Filename)
+	Expect(err).To(HaveOccurred())
+	Expect(sourceFile.Close()).To(Succeed())
+
+	stateFiles, err := os.ReadDir(stateDir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(stateFiles).To(HaveLen(1))
+
+	calls := []string{}
+	tracingS3Client, err := CreateTracingS3Client(&s3Config, &calls)
+	Expect(err).ToNot(HaveOccurred())
+
+	sourceFile, err = os.Open(contentFile)
+	Expect(err).ToNot(HaveOccurred())
+	defer sourceFile.Close() //nolint:errcheck
+	err = client.New(tracingS3Client, &s3Config).Put(sourceFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	Expect(calls).To(Equal([]string{"ListParts", "UploadPart", "CompleteMultipart"}))
+
+	stateFiles, err = os.ReadDir(stateDir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(stateFiles).To(BeEmpty())
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents()) == content).To(BeTrue(), "downloaded content differs from the uploaded content")
+}
+
+// AssertGetIsAtomic asserts that a failed `s3cli get` leaves the destination
+// untouched and that `s3cli get -resume` continues a partial download
+func AssertGetIsAtomic(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	downloadDir, err := os.MkdirTemp("", "s3cli-download")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(downloadDir) //nolint:errcheck
+	downloadPath := filepath.Join(downloadDir, "blob")
+
+	err = os.WriteFile(downloadPath, []byte("previous content"), 0644)
+	Expect(err).ToNot(HaveOccurred())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "non-existent-file", downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+	Expect(os.ReadFile(downloadPath)).To(Equal([]byte("previous content")))
+	Expect(os.ReadDir(downloadDir)).To(HaveLen(1))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "list", "-long", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	etag := strings.Split(string(s3CLISession.Out.Contents()), "\t")[2]
+
+	partialPath := filepath.Join(downloadDir, fmt.Sprintf(".blob.%s.s3cli-partial", etag))
+	err = os.WriteFile(partialPath, []byte(expectedString[:100]), 0644)
+	Expect(err).ToNot(HaveOccurred())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-resume", s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Err.Contents()).To(ContainSubstring("Resuming download"))
+	Expect(os.ReadFile(downloadPath)).To(Equal([]byte(expectedString)))
+	Expect(partialPath).ToNot(BeAnExistingFile())
+}
+
+func AssertDigestVerificationWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+	sha1Digest := fmt.Sprintf("%x", sha1.Sum([]byte(expectedString))) //nolint:gosec
+	sha256Digest := fmt.Sprintf("%x", sha256.Sum256([]byte(expectedString)))
+	wrongSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte("other content")))
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", "-sha256", wrongSHA256, contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+	Expect(s3CLISession.Err.Contents()).To(ContainSubstring("expected sha256 digest"))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(3))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "put", "-digest", fmt.Sprintf("sha1:%s;sha256:%s", sha1Digest, sha256Digest), contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	downloadDir, err := os.MkdirTemp("", "s3cli-download")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(downloadDir) //nolint:errcheck
+	downloadPath := filepath.Join(downloadDir, "blob")
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-sha256", wrongSHA256, s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+	Expect(os.ReadDir(downloadDir)).To(BeEmpty())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-sha1", sha1Digest, s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(os.ReadFile(downloadPath)).To(Equal([]byte(expectedString)))
+}
+
+func AssertJSONOutputWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	var result struct {
+		Command          string `json:"command"`
+		Key              string `json:"key"`
+		Bucket           string `json:"bucket"`
+		Size             int64  `json:"size"`
+		ETag             string `json:"etag"`
+		BytesTransferred int64  `json:"bytes_transferred"`
+		Exists           bool   `json:"exists"`
+		ErrorCode        string `json:"error_code"`
+	}
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "-output", "json", "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.Command).To(Equal("put"))
+	Expect(result.Key).To(Equal(s3Filename))
+	Expect(result.Bucket).To(Equal(cfg.BucketName))
+	Expect(result.Size).To(Equal(int64(len(expectedString))))
+	Expect(result.BytesTransferred).To(Equal(int64(len(expectedString))))
+	Expect(result.ETag).ToNot(BeEmpty())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "-output", "json", "exists", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.Exists).To(BeTrue())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "-output", "json", "get", "non-existent-file", "/dev/null")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.ErrorCode).To(Equal("NoSuchKey"))
+}
+
+// AssertVersionsWork asserts that the versions of a blob can be listed,
+// fetched and purged. In a bucket without versioning the blob has a single
+// version with the ID "null".
+func AssertVersionsWork(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	var result struct {
+		Versions []struct {
+			VersionID string `json:"version_id"`
+			IsLatest  bool   `json:"is_latest"`
+		} `json:"versions"`
+	}
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "-output", "json", "versions", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.Versions).To(HaveLen(1))
+	Expect(result.Versions[0].IsLatest).To(BeTrue())
+
+	tmpLocalFile, err := os.CreateTemp("", "s3cli-download")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(tmpLocalFile.Close()).To(Succeed())
+	defer os.Remove(tmpLocalFile.Name()) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-version-id", result.Versions[0].VersionID, s3Filename, tmpLocalFile.Name())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	gottenBytes, err := os.ReadFile(tmpLocalFile.Name())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(gottenBytes)).To(Equal(expectedString))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "delete", "-purge", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "versions", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(BeEmpty())
+}
+
+func AssertConditionalWritesWork(s3CLIPath string, cfg *config.S3Cli) {
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(GenerateRandomString(1024))
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+	var result struct {
+		ETag string `json:"etag"`
+	}
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "-output", "json", "put", "-no-overwrite", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "put", "-no-overwrite", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(10))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "put", "-if-match", "not-the-etag", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(10))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "put", "-if-match", result.ETag, contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+}
+
+func AssertConditionalGetWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	var result struct {
+		ETag string `json:"etag"`
+	}
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "-output", "json", "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-range", "bytes=100-199", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString[100:200]))
+
+	downloadPath := filepath.Join(GinkgoT().TempDir(), "blob")
+	Expect(os.WriteFile(downloadPath, []byte("cached content"), 0644)).To(Succeed())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-if-none-match", result.ETag, s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(11))
+	Expect(os.ReadFile(downloadPath)).To(Equal([]byte("cached content")))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-if-modified-since", time.Now().Add(time.Hour).UTC().Format(time.RFC3339), s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(11))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-if-none-match", "other-etag", s3Filename, downloadPath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(os.ReadFile(downloadPath)).To(Equal([]byte(expectedString)))
+}
+
+func AssertStatWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", "-store-digest", "-sha256", fmt.Sprintf("%x", sha256.Sum256([]byte(expectedString))), contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	var result struct {
+		Key  string `json:"key"`
+		Size int64  `json:"size"`
+		ETag string `json:"etag"`
+		Stat struct {
+			LastModified time.Time         `json:"last_modified"`
+			Metadata     map[string]string `json:"metadata"`
+		} `json:"stat"`
+	}
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "-output", "json", "stat", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.Key).To(Equal(s3Filename))
+	Expect(result.Size).To(Equal(int64(len(expectedString))))
+	Expect(result.ETag).ToNot(BeEmpty())
+	Expect(result.Stat.LastModified).ToNot(BeZero())
+	Expect(result.Stat.Metadata).To(HaveKeyWithValue("sha256", fmt.Sprintf("%x", sha256.Sum256([]byte(expectedString)))))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "stat", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring(fmt.Sprintf("size: %d\n", len(expectedString))))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "stat", "non-existent-file")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(3))
+}
+
+func AssertObjectHeadersWork(s3CLIPath string, cfg *config.S3Cli) {
+	s3Filename := GenerateRandomString() + ".json"
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(`{}`)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", "-metadata", "owner=integration", "-content-disposition", "attachment", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "stat", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("content_type: application/json\n"))
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("content_disposition: attachment\n"))
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("metadata.owner: integration\n"))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "tag", s3Filename, "a=1", "b=2")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "untag", s3Filename, "a")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "tags", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal("b=2\n"))
+}
+
+func AssertUploadTagsWork(s3CLIPath string, cfg *config.S3Cli) {
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(GenerateRandomString())
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", "-tag", "a=1", "-tag", "b=2", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "tags", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal("a=1\nb=2\n"))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "untag", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "tags", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(BeEmpty())
+}
+
+func AssertClientSideEncryptionWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(200 * 1024)
+	s3Filename := GenerateRandomString()
+
+	plainConfigPath := MakeConfigFile(cfg)
+	defer os.Remove(plainConfigPath) //nolint:errcheck
+
+	keyFile := MakeContentFile(GenerateRandomString(32))
+	defer os.Remove(keyFile) //nolint:errcheck
+	encryptingCfg := *cfg
+	encryptingCfg.ClientSideEncryptionKeyFile = keyFile
+	configPath := MakeConfigFile(&encryptingCfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-range", "bytes=65000-70000", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString[65000:70001]))
+
+	// The stored blob is of no use without the key
+	s3CLISession, err = RunS3CLI(s3CLIPath, plainConfigPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(Equal(8))
+	Expect(s3CLISession.Out.Contents()).To(BeEmpty())
+}
+
+func AssertCompressionWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := strings.Repeat(GenerateRandomString(100), 2048)
+	s3Filename := GenerateRandomString()
+
+	compressingCfg := *cfg
+	compressingCfg.Compression = config.CompressionZstd
+	configPath := MakeConfigFile(&compressingCfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-range", "bytes=150000-150099", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString[150000:150100]))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", "-raw", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(len(s3CLISession.Out.Contents())).To(BeNumerically("<", len(expectedString)/10))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "stat", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("compression: zstd\n"))
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring(fmt.Sprintf("original_size: %d\n", len(expectedString))))
+}
+
+func AssertSSECustomerKeyWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString(1024)
+	s3Filename := GenerateRandomString()
+
+	plainConfigPath := MakeConfigFile(cfg)
+	defer os.Remove(plainConfigPath) //nolint:errcheck
+
+	keyFile := MakeContentFile(GenerateRandomString(32))
+	defer os.Remove(keyFile) //nolint:errcheck
+	sseCfg := *cfg
+	sseCfg.ServerSideEncryption = ""
+	sseCfg.SSEKMSKeyID = ""
+	sseCfg.SSECustomerKeyFile = keyFile
+	configPath := MakeConfigFile(&sseCfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	copyFilename := GenerateRandomString()
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "copy", s3Filename, copyFilename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", copyFilename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", copyFilename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString))
+
+	// The signed URL only works with the SSE-C headers it was signed with
+	var result struct {
+		SignedURL     string            `json:"signed_url"`
+		SignedHeaders map[string]string `json:"signed_headers"`
+	}
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "-output", "json", "sign", s3Filename, "get", "1h")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(json.Unmarshal(s3CLISession.Out.Contents(), &result)).To(Succeed())
+	Expect(result.SignedHeaders).To(HaveKey("x-amz-server-side-encryption-customer-key"))
+
+	req, err := http.NewRequest(http.MethodGet, result.SignedURL, nil)
+	Expect(err).ToNot(HaveOccurred())
+	for name, value := range result.SignedHeaders {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close() //nolint:errcheck
+	Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(body)).To(Equal(expectedString))
+
+	// Without the key the blob cannot be read
+	s3CLISession, err = RunS3CLI(s3CLIPath, plainConfigPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).ToNot(BeZero())
+}
+
+func AssertStorageClassWorks(s3CLIPath string, cfg *config.S3Cli) {
+	expectedString := GenerateRandomString()
+	s3Filename := GenerateRandomString()
+
+	configPath := MakeConfigFile(cfg)
+	defer os.Remove(configPath) //nolint:errcheck
+
+	contentFile := MakeContentFile(expectedString)
+	defer os.Remove(contentFile) //nolint:errcheck
+
+	s3CLISession, err := RunS3CLI(s3CLIPath, configPath, "put", "-storage-class", "STANDARD_IA", contentFile, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	defer RunS3CLI(s3CLIPath, configPath, "delete", s3Filename) //nolint:errcheck
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "stat", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("storage_class: STANDARD_IA\n"))
+
+	// Copying a blob onto itself changes its storage class
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "copy", "-storage-class", "STANDARD", s3Filename, s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "restore-status", s3Filename)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(s3CLISession.Out.Contents()).To(ContainSubstring("restore: not-archived\n"))
+
+	s3CLISession, err = RunS3CLI(s3CLIPath, configPath, "get", s3Filename, "-")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s3CLISession.ExitCode()).To(BeZero())
+	Expect(string(s3CLISession.Out.Contents())).To(Equal(expectedString))
+}
@@ -70,7 +70,7 @@ var _ = Describe("Testing gets against a public AWS S3 bucket", func() {
 			s3CLISession, err = integration.RunS3CLI(s3CLIPath, configPath, "exists", s3Filename)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(s3CLISession.ExitCode()).To(BeZero())
-			Expect(s3CLISession.Err.Contents()).To(MatchRegexp("File '.*' exists in bucket '.*'"))
+			Expect(s3CLISession.Err.Contents()).To(MatchRegexp("msg=\"File exists in bucket\" key=.* bucket=.*"))
 
 			// Clean up the uploaded file
 			_, err = s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
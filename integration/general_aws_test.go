@@ -62,6 +62,34 @@ var _ = Describe("General testing for all AWS regions", func() {
 			func(cfg *config.S3Cli) { integration.AssertGetNonexistentFails(s3CLIPath, cfg) },
 			configurations,
 		)
+		DescribeTable("Invoking `s3cli get` replaces the destination atomically",
+			func(cfg *config.S3Cli) { integration.AssertGetIsAtomic(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli get` with -range, -if-none-match and -if-modified-since reads conditionally",
+			func(cfg *config.S3Cli) { integration.AssertConditionalGetWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli stat` reports the metadata of a blob",
+			func(cfg *config.S3Cli) { integration.AssertStatWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` with content headers and metadata, and `s3cli tag` and `untag` work",
+			func(cfg *config.S3Cli) { integration.AssertObjectHeadersWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with client-side encryption works",
+			func(cfg *config.S3Cli) { integration.AssertClientSideEncryptionWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with compression works",
+			func(cfg *config.S3Cli) { integration.AssertCompressionWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put -tag` tags the blob and `s3cli untag` removes all tags",
+			func(cfg *config.S3Cli) { integration.AssertUploadTagsWork(s3CLIPath, cfg) },
+			configurations,
+		)
 		DescribeTable("Invoking `s3cli delete` on a non-existent-key does not fail",
 			func(cfg *config.S3Cli) { integration.AssertDeleteNonexistentWorks(s3CLIPath, cfg) },
 			configurations,
@@ -70,6 +98,58 @@ var _ = Describe("General testing for all AWS regions", func() {
 			func(cfg *config.S3Cli) { integration.AssertOnSignedURLs(s3CLIPath, cfg) },
 			configurations,
 		)
+		DescribeTable("Invoking `s3cli list` lists the keys below a prefix",
+			func(cfg *config.S3Cli) { integration.AssertListWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli delete -prefix` and `delete -from-file` deletes several blobs",
+			func(cfg *config.S3Cli) { integration.AssertBatchDeleteWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli sync` transfers the files which differ",
+			func(cfg *config.S3Cli) { integration.AssertSyncWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli copy` and `s3cli move` works server-side",
+			func(cfg *config.S3Cli) { integration.AssertCopyAndMoveWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with '-' streams through stdin and stdout",
+			func(cfg *config.S3Cli) { integration.AssertStreamingWorks(s3CLIPath, cfg, largeContent) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli batch` executes JSON-lines requests",
+			func(cfg *config.S3Cli) { integration.AssertBatchWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli serve` exposes the blobs over HTTP",
+			func(cfg *config.S3Cli) { integration.AssertServeWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with digests verifies the content",
+			func(cfg *config.S3Cli) { integration.AssertDigestVerificationWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking s3cli with `-output json` prints a JSON document",
+			func(cfg *config.S3Cli) { integration.AssertJSONOutputWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli versions`, `get -version-id` and `delete -purge` works",
+			func(cfg *config.S3Cli) { integration.AssertVersionsWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put -no-overwrite` and `put -if-match` writes conditionally",
+			func(cfg *config.S3Cli) { integration.AssertConditionalWritesWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put`, `get`, `copy` and `sign` with an SSE-C key works",
+			func(cfg *config.S3Cli) { integration.AssertSSECustomerKeyWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put -storage-class`, `copy -storage-class` and `restore-status` works",
+			func(cfg *config.S3Cli) { integration.AssertStorageClassWorks(s3CLIPath, cfg) },
+			configurations,
+		)
 
 		configurations = []TableEntry{
 			Entry("with encryption", &config.S3Cli{
@@ -113,9 +193,22 @@ var _ = Describe("General testing for all AWS regions", func() {
 					Region:          region,
 					MultipartUpload: true,
 				}
-				msg := "upload retry limit exceeded"
+				msg := "upload failure"
 				integration.AssertOnPutFailures(cfg, largeContent, msg)
 			})
 		})
+
+		Describe("Invoking `s3cli put` again after multipart upload failures", func() {
+			It("only uploads the missing parts", func() {
+				cfg := &config.S3Cli{
+					AccessKeyID:     accessKeyID,
+					SecretAccessKey: secretAccessKey,
+					BucketName:      bucketName,
+					Region:          region,
+					MultipartUpload: true,
+				}
+				integration.AssertResumableUploadWorks(s3CLIPath, cfg, largeContent)
+			})
+		})
 	})
 })
@@ -90,6 +90,8 @@ func (m *S3TracingMiddleware) HandleInitialize(
 		*m.calls = append(*m.calls, "UploadPart")
 	case *s3.CompleteMultipartUploadInput:
 		*m.calls = append(*m.calls, "CompleteMultipart")
+	case *s3.ListPartsInput:
+		*m.calls = append(*m.calls, "ListParts")
 	case *s3.PutObjectInput:
 		*m.calls = append(*m.calls, "PutObject")
 	case *s3.GetObjectInput:
@@ -76,10 +76,70 @@ var _ = Describe("Testing in any non-AWS, S3 compatible storage service", func()
 			func(cfg *config.S3Cli) { integration.AssertGetNonexistentFails(s3CLIPath, cfg) },
 			configurations,
 		)
+		DescribeTable("Invoking `s3cli get` replaces the destination atomically",
+			func(cfg *config.S3Cli) { integration.AssertGetIsAtomic(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli get` with -range, -if-none-match and -if-modified-since reads conditionally",
+			func(cfg *config.S3Cli) { integration.AssertConditionalGetWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli stat` reports the metadata of a blob",
+			func(cfg *config.S3Cli) { integration.AssertStatWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` with content headers and metadata, and `s3cli tag` and `untag` work",
+			func(cfg *config.S3Cli) { integration.AssertObjectHeadersWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with client-side encryption works",
+			func(cfg *config.S3Cli) { integration.AssertClientSideEncryptionWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with compression works",
+			func(cfg *config.S3Cli) { integration.AssertCompressionWorks(s3CLIPath, cfg) },
+			configurations,
+		)
 		DescribeTable("Invoking `s3cli delete` on a non-existent-key does not fail",
 			func(cfg *config.S3Cli) { integration.AssertDeleteNonexistentWorks(s3CLIPath, cfg) },
 			configurations,
 		)
+		DescribeTable("Invoking `s3cli list` lists the keys below a prefix",
+			func(cfg *config.S3Cli) { integration.AssertListWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli delete -prefix` and `delete -from-file` deletes several blobs",
+			func(cfg *config.S3Cli) { integration.AssertBatchDeleteWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli sync` transfers the files which differ",
+			func(cfg *config.S3Cli) { integration.AssertSyncWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli copy` and `s3cli move` works server-side",
+			func(cfg *config.S3Cli) { integration.AssertCopyAndMoveWork(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with '-' streams through stdin and stdout",
+			func(cfg *config.S3Cli) { integration.AssertStreamingWorks(s3CLIPath, cfg, largeContent) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli batch` executes JSON-lines requests",
+			func(cfg *config.S3Cli) { integration.AssertBatchWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli serve` exposes the blobs over HTTP",
+			func(cfg *config.S3Cli) { integration.AssertServeWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking `s3cli put` and `s3cli get` with digests verifies the content",
+			func(cfg *config.S3Cli) { integration.AssertDigestVerificationWorks(s3CLIPath, cfg) },
+			configurations,
+		)
+		DescribeTable("Invoking s3cli with `-output json` prints a JSON document",
+			func(cfg *config.S3Cli) { integration.AssertJSONOutputWorks(s3CLIPath, cfg) },
+			configurations,
+		)
 		DescribeTable("Invoking `s3cli put` handling of multipart uploads",
 			func(cfg *config.S3Cli) { integration.AssertOnMultipartUploads(s3CLIPath, cfg, largeContent) },
 			configurations,
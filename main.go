@@ -1,10 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cloudfoundry/bosh-s3cli/client"
@@ -16,6 +27,9 @@ var version string
 func main() {
 	configPath := flag.String("c", "", "configuration path")
 	showVer := flag.Bool("v", false, "version")
+	output := flag.String("output", "text", "output format, 'text' or 'json' to print one JSON document per command")
+	logLevel := flag.String("log-level", "", "log level, one of debug, info, warn or error; overrides log_level")
+	logFormat := flag.String("log-format", "", "log format, 'text' or 'json'; overrides log_format")
 	flag.Parse()
 
 	if *showVer {
@@ -23,108 +37,780 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *output != "text" && *output != "json" {
+		log.Printf("Output format should be 'text' or 'json'. Got: %s\n", *output)
+		os.Exit(exitCodeUsage)
+	}
+	jsonOutput := *output == "json"
+
 	nonFlagArgs := flag.Args()
-	if len(nonFlagArgs) < 2 {
-		log.Fatalf("Expected at least two arguments got %d\n", len(nonFlagArgs))
+	if len(nonFlagArgs) < 1 {
+		newCommandResult("").exit(jsonOutput, usageError(fmt.Errorf("expected at least one argument got %d", len(nonFlagArgs))))
 	}
 
+	cmd := nonFlagArgs[0]
+	result := newCommandResult(cmd)
+
 	configFile, err := os.Open(*configPath)
 	if err != nil {
-		log.Fatalln(err)
+		result.exit(jsonOutput, invalidConfig(err))
 	}
 
 	s3Config, err := config.NewFromReader(configFile)
 	if err != nil {
-		log.Fatalln(err)
+		result.exit(jsonOutput, invalidConfig(err))
+	}
+
+	if *logLevel != "" {
+		s3Config.LogLevel = *logLevel
 	}
+	if *logFormat != "" {
+		s3Config.LogFormat = *logFormat
+	}
+	if err = s3Config.ValidateLogging(); err != nil {
+		result.exit(jsonOutput, invalidConfig(err))
+	}
+	logger := client.NewLogger(os.Stderr, &s3Config)
 
 	s3Client, err := client.NewAwsS3Client(&s3Config)
 	if err != nil {
-		log.Fatalln(err)
+		result.exit(jsonOutput, invalidConfig(err))
 	}
 
 	blobstoreClient := client.New(s3Client, &s3Config)
 
-	cmd := nonFlagArgs[0]
+	// The first signal cancels the operation, which aborts open multipart
+	// uploads; a second one kills s3cli right away
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		cancel(&signalError{signal: received.(syscall.Signal)})
+		signal.Stop(signals)
+	}()
+
+	runner := &commandRunner{blobstoreClient: blobstoreClient, bucket: s3Config.BucketName, logger: logger, result: result}
+	out := bufio.NewWriter(os.Stdout)
+	if !jsonOutput {
+		runner.out = out
+	}
 
 	switch cmd {
 	case "put":
-		if len(nonFlagArgs) != 3 {
-			log.Fatalf("Put method expected 3 arguments got %d\n", len(nonFlagArgs))
+		putFlags := flag.NewFlagSet("put", flag.ContinueOnError)
+		digests := addDigestFlags(putFlags)
+		storeDigest := putFlags.Bool("store-digest", false, "store the verified digests as object metadata")
+		noOverwrite := putFlags.Bool("no-overwrite", false, "fail if the blob already exists")
+		ifMatch := putFlags.String("if-match", "", "fail unless the blob exists with this ETag")
+		contentType := putFlags.String("content-type", "", "Content-Type of the blob, detected from its extension by default")
+		cacheControl := putFlags.String("cache-control", "", "Cache-Control of the blob")
+		contentDisposition := putFlags.String("content-disposition", "", "Content-Disposition of the blob")
+		contentEncoding := putFlags.String("content-encoding", "", "Content-Encoding of the blob")
+		metadata := keyValueFlag{}
+		putFlags.Var(metadata, "metadata", "user metadata 'name=value' stored as x-amz-meta-<name>, repeatable")
+		tags := keyValueFlag{}
+		putFlags.Var(tags, "tag", "tag 'name=value' of the blob, repeatable")
+		storageClass := putFlags.String("storage-class", "", "storage class of the blob, e.g. STANDARD_IA or GLACIER")
+		lockMode := putFlags.String("lock-mode", "", "Object Lock retention mode of the blob, GOVERNANCE or COMPLIANCE")
+		retainUntil := putFlags.String("retain-until", "", "date until which Object Lock retains the blob, e.g. '2030-01-02'")
+		parseFlags(putFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if putFlags.NArg() != 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("put method expected 2 arguments got %d", putFlags.NArg())))
 		}
-		src, dst := nonFlagArgs[1], nonFlagArgs[2]
 
-		var sourceFile *os.File
-		sourceFile, err = os.Open(src)
-		if err != nil {
-			log.Fatalln(err)
+		var expected []client.Digest
+		if expected, err = digests(); err != nil {
+			result.exit(jsonOutput, usageError(err))
+		}
+		var retainUntilDate time.Time
+		if retainUntilDate, err = parseRetainUntil(*retainUntil); err != nil {
+			result.exit(jsonOutput, usageError(err))
 		}
 
-		defer sourceFile.Close() //nolint:errcheck
-		err = blobstoreClient.Put(sourceFile, dst)
+		err = runner.put(ctx, putOptions{
+			Src:                putFlags.Arg(0),
+			Dst:                putFlags.Arg(1),
+			Digests:            expected,
+			StoreDigest:        *storeDigest,
+			NoOverwrite:        *noOverwrite,
+			IfMatch:            *ifMatch,
+			ContentType:        *contentType,
+			CacheControl:       *cacheControl,
+			ContentDisposition: *contentDisposition,
+			ContentEncoding:    *contentEncoding,
+			Metadata:           metadata,
+			Tags:               tags,
+			StorageClass:       *storageClass,
+			Retention:          client.Retention{Mode: *lockMode, RetainUntilDate: retainUntilDate},
+		})
 	case "get":
-		if len(nonFlagArgs) != 3 {
-			log.Fatalf("Get method expected 3 arguments got %d\n", len(nonFlagArgs))
+		getFlags := flag.NewFlagSet("get", flag.ContinueOnError)
+		resume := getFlags.Bool("resume", false, "keep a failed download and continue it on the next get")
+		versionID := getFlags.String("version-id", "", "fetch this version of the blob instead of the latest one")
+		byteRange := getFlags.String("range", "", "fetch only this byte range, e.g. 'bytes=0-99' or 'bytes=-100'")
+		ifNoneMatch := getFlags.String("if-none-match", "", "skip the download if the blob still has this ETag")
+		ifModifiedSince := getFlags.String("if-modified-since", "", "skip the download unless the blob was modified after this time")
+		raw := getFlags.Bool("raw", false, "write a compressed blob as it is stored instead of decompressing it")
+		digests := addDigestFlags(getFlags)
+		parseFlags(getFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if getFlags.NArg() != 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("get method expected 2 arguments got %d", getFlags.NArg())))
+		}
+		if getFlags.Arg(1) == "-" && jsonOutput {
+			result.exit(jsonOutput, usageError(errors.New("cannot print JSON output while writing the blob to stdout")))
 		}
-		src, dst := nonFlagArgs[1], nonFlagArgs[2]
 
-		var dstFile *os.File
-		dstFile, err = os.Create(dst)
-		if err != nil {
-			log.Fatalln(err)
+		var expected []client.Digest
+		if expected, err = digests(); err != nil {
+			result.exit(jsonOutput, usageError(err))
+		}
+		var rangeHeader string
+		if rangeHeader, err = parseRange(*byteRange); err != nil {
+			result.exit(jsonOutput, usageError(err))
+		}
+		var modifiedSince time.Time
+		if modifiedSince, err = parseModifiedSince(*ifModifiedSince); err != nil {
+			result.exit(jsonOutput, usageError(err))
 		}
 
-		defer dstFile.Close() //nolint:errcheck
-		err = blobstoreClient.Get(src, dstFile)
+		err = runner.get(ctx, getOptions{
+			Src:             getFlags.Arg(0),
+			Dst:             getFlags.Arg(1),
+			Digests:         expected,
+			Resume:          *resume,
+			VersionID:       *versionID,
+			Range:           rangeHeader,
+			IfNoneMatch:     *ifNoneMatch,
+			IfModifiedSince: modifiedSince,
+			Raw:             *raw,
+		})
 	case "delete":
-		if len(nonFlagArgs) != 2 {
-			log.Fatalf("Delete method expected 2 arguments got %d\n", len(nonFlagArgs))
+		deleteFlags := flag.NewFlagSet("delete", flag.ContinueOnError)
+		versionID := deleteFlags.String("version-id", "", "permanently delete this version of the blob")
+		purge := deleteFlags.Bool("purge", false, "permanently delete every version and delete marker of the blob")
+		prefix := deleteFlags.String("prefix", "", "delete every blob below this prefix")
+		fromFile := deleteFlags.String("from-file", "", "delete the blobs listed in this file, one key per line, '-' reads stdin")
+		dryRun := deleteFlags.Bool("dry-run", false, "only print the keys -prefix or -from-file would delete")
+		concurrency := deleteFlags.Int("concurrency", 0, "number of DeleteObjects requests of up to 1000 keys sent at once")
+		bypassGovernance := deleteFlags.Bool("bypass-governance", false, "delete versions under a GOVERNANCE retention")
+		parseFlags(deleteFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if *prefix != "" || *fromFile != "" {
+			if deleteFlags.NArg() != 0 {
+				result.exit(jsonOutput, usageError(fmt.Errorf("delete with -prefix or -from-file expected no arguments got %d", deleteFlags.NArg())))
+			}
+			if *prefix != "" && *fromFile != "" {
+				result.exit(jsonOutput, usageError(errors.New("delete accepts either -prefix or -from-file")))
+			}
+			if *purge || *versionID != "" || *bypassGovernance {
+				result.exit(jsonOutput, usageError(errors.New("delete with -prefix or -from-file does not accept -version-id, -purge or -bypass-governance")))
+			}
+
+			var keys []string
+			if *fromFile != "" {
+				if keys, err = readKeys(*fromFile); err != nil {
+					result.exit(jsonOutput, err)
+				}
+			}
+
+			err = runner.deleteMany(ctx, deleteManyOptions{
+				Prefix:      *prefix,
+				Keys:        keys,
+				DryRun:      *dryRun,
+				Concurrency: *concurrency,
+			})
+			break
+		}
+
+		if deleteFlags.NArg() != 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("delete method expected 2 arguments got %d", deleteFlags.NArg()+1)))
+		}
+		if *purge && *versionID != "" {
+			result.exit(jsonOutput, usageError(errors.New("delete accepts either -version-id or -purge")))
 		}
 
-		err = blobstoreClient.Delete(nonFlagArgs[1])
+		err = runner.delete(ctx, deleteOptions{
+			Key:              deleteFlags.Arg(0),
+			VersionID:        *versionID,
+			Purge:            *purge,
+			BypassGovernance: *bypassGovernance,
+		})
 	case "exists":
-		if len(nonFlagArgs) != 2 {
-			log.Fatalf("Exists method expected 2 arguments got %d\n", len(nonFlagArgs))
+		existsFlags := flag.NewFlagSet("exists", flag.ContinueOnError)
+		versionID := existsFlags.String("version-id", "", "check this version of the blob instead of the latest one")
+		parseFlags(existsFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if existsFlags.NArg() != 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("exists method expected 2 arguments got %d", existsFlags.NArg()+1)))
 		}
 
-		var exists bool
-		exists, err = blobstoreClient.Exists(nonFlagArgs[1])
+		err = runner.exists(ctx, blobOptions{Key: existsFlags.Arg(0), VersionID: *versionID})
+	case "stat":
+		statFlags := flag.NewFlagSet("stat", flag.ContinueOnError)
+		versionID := statFlags.String("version-id", "", "describe this version of the blob instead of the latest one")
+		parseFlags(statFlags, nonFlagArgs[1:], result, jsonOutput)
 
-		// If the object exists the exit status is 0, otherwise it is 3
-		// We are using `3` since `1` and `2` have special meanings
-		if err == nil && !exists {
-			os.Exit(3)
+		if statFlags.NArg() != 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("stat method expected 1 argument got %d", statFlags.NArg())))
 		}
-	case "sign":
-		if len(nonFlagArgs) != 4 {
-			log.Fatalf("Sign method expects 3 arguments got %d\n", len(nonFlagArgs)-1)
+
+		err = runner.stat(ctx, blobOptions{Key: statFlags.Arg(0), VersionID: *versionID})
+	case "tags", "tag", "untag":
+		tagFlags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+		versionID := tagFlags.String("version-id", "", "operate on the tags of this version of the blob instead of the latest one")
+		parseFlags(tagFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		switch {
+		case tagFlags.NArg() < 1:
+			result.exit(jsonOutput, usageError(fmt.Errorf("%s method expected at least 1 argument got 0", cmd)))
+		case cmd == "tags" && tagFlags.NArg() != 1:
+			result.exit(jsonOutput, usageError(fmt.Errorf("tags method expected 1 argument got %d", tagFlags.NArg())))
+		case cmd == "tag" && tagFlags.NArg() < 2:
+			result.exit(jsonOutput, usageError(errors.New("tag method expected at least one 'name=value' tag")))
 		}
+		key := tagFlags.Arg(0)
 
-		objectID, action := nonFlagArgs[1], nonFlagArgs[2]
+		switch cmd {
+		case "tags":
+			err = runner.tags(ctx, blobOptions{Key: key, VersionID: *versionID})
+		case "tag":
+			tags := keyValueFlag{}
+			for _, arg := range tagFlags.Args()[1:] {
+				if err = tags.Set(arg); err != nil {
+					result.exit(jsonOutput, usageError(err))
+				}
+			}
+			err = runner.tag(ctx, tagOptions{Key: key, VersionID: *versionID, Tags: tags})
+		case "untag":
+			err = runner.untag(ctx, tagOptions{Key: key, VersionID: *versionID, Names: tagFlags.Args()[1:]})
+		}
+	case "sign":
+		signFlags := flag.NewFlagSet("sign", flag.ContinueOnError)
+		versionID := signFlags.String("version-id", "", "sign the GET of this version of the blob")
+		parseFlags(signFlags, nonFlagArgs[1:], result, jsonOutput)
 
+		if signFlags.NArg() != 3 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("sign method expects 3 arguments got %d", signFlags.NArg())))
+		}
+
+		action := signFlags.Arg(1)
 		if action != "get" && action != "put" {
-			log.Fatalf("Action not implemented: %s. Available actions are 'get' and 'put'", action)
+			result.exit(jsonOutput, usageError(fmt.Errorf("action not implemented: %s. Available actions are 'get' and 'put'", action)))
 		}
 
-		expiration, err := time.ParseDuration(nonFlagArgs[3])
-		if err != nil {
-			log.Fatalf("Expiration should be in the format of a duration i.e. 1h, 60m, 3600s. Got: %s", nonFlagArgs[3])
+		expiration, parseErr := time.ParseDuration(signFlags.Arg(2))
+		if parseErr != nil {
+			result.exit(jsonOutput, usageError(fmt.Errorf("expiration should be in the format of a duration i.e. 1h, 60m, 3600s. Got: %s", signFlags.Arg(2))))
 		}
 
-		signedURL, err := blobstoreClient.Sign(objectID, action, expiration)
+		err = runner.sign(ctx, signOptions{Key: signFlags.Arg(0), Action: action, Expiration: expiration, VersionID: *versionID})
+		if err != nil && !jsonOutput {
+			log.Printf("Failed to sign request: %s", err)
+			os.Exit(exitCode(err))
+		}
+	case "copy", "move":
+		copyFlags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+		destBucket := copyFlags.String("dest-bucket", "", "bucket to copy into, defaults to the configured bucket")
+		storageClass := copyFlags.String("storage-class", "", "storage class of the copy, e.g. STANDARD_IA or GLACIER")
+		parseFlags(copyFlags, nonFlagArgs[1:], result, jsonOutput)
 
-		if err != nil {
-			log.Fatalf("Failed to sign request: %s", err)
-			os.Exit(1)
+		if copyFlags.NArg() != 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("copy and move methods expected 2 arguments got %d", copyFlags.NArg())))
+		}
+
+		err = runner.copy(ctx, copyOptions{
+			Src:          copyFlags.Arg(0),
+			Dst:          copyFlags.Arg(1),
+			DestBucket:   *destBucket,
+			StorageClass: *storageClass,
+			Move:         cmd == "move",
+		})
+	case "versions":
+		if len(nonFlagArgs) != 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("versions method expected 2 arguments got %d", len(nonFlagArgs))))
+		}
+
+		err = runner.versions(ctx, blobOptions{Key: nonFlagArgs[1]})
+	case "restore-version":
+		if len(nonFlagArgs) != 3 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("restore-version method expected 3 arguments got %d", len(nonFlagArgs))))
 		}
 
-		fmt.Print(signedURL)
+		err = runner.restoreVersion(ctx, blobOptions{Key: nonFlagArgs[1], VersionID: nonFlagArgs[2]})
+	case "restore", "restore-status":
+		restoreFlags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+		versionID := restoreFlags.String("version-id", "", "operate on this version of the blob instead of the latest one")
+		days := restoreFlags.Int("days", 1, "days the restored copy is kept, 0 for Intelligent-Tiering archive tiers")
+		tier := restoreFlags.String("tier", "", "retrieval tier: Standard (the default), Bulk or Expedited")
+		parseFlags(restoreFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if restoreFlags.NArg() != 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("restore and restore-status methods expected 1 argument got %d", restoreFlags.NArg())))
+		}
+
+		if cmd == "restore" {
+			err = runner.restore(ctx, restoreOptions{Key: restoreFlags.Arg(0), VersionID: *versionID, Days: int32(*days), Tier: *tier})
+			break
+		}
+		err = runner.restoreStatus(ctx, blobOptions{Key: restoreFlags.Arg(0), VersionID: *versionID})
+	case "retention":
+		retentionFlags := flag.NewFlagSet("retention", flag.ContinueOnError)
+		versionID := retentionFlags.String("version-id", "", "operate on this version of the blob instead of the latest one")
+		mode := retentionFlags.String("mode", "", "set the retention mode, GOVERNANCE or COMPLIANCE")
+		retainUntil := retentionFlags.String("retain-until", "", "set the date until which the blob is retained, e.g. '2030-01-02'")
+		clearRetention := retentionFlags.Bool("clear", false, "remove a GOVERNANCE retention, requires -bypass-governance")
+		bypassGovernance := retentionFlags.Bool("bypass-governance", false, "shorten or remove a GOVERNANCE retention")
+		parseFlags(retentionFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if retentionFlags.NArg() != 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("retention method expected 1 argument got %d", retentionFlags.NArg())))
+		}
+		if *clearRetention && (*mode != "" || *retainUntil != "") {
+			result.exit(jsonOutput, usageError(errors.New("retention accepts either -clear or -mode and -retain-until")))
+		}
+
+		var retainUntilDate time.Time
+		if retainUntilDate, err = parseRetainUntil(*retainUntil); err != nil {
+			result.exit(jsonOutput, usageError(err))
+		}
+
+		err = runner.retention(ctx, retentionOptions{
+			Key:              retentionFlags.Arg(0),
+			VersionID:        *versionID,
+			Set:              *clearRetention || *mode != "" || *retainUntil != "",
+			Retention:        client.Retention{Mode: *mode, RetainUntilDate: retainUntilDate},
+			BypassGovernance: *bypassGovernance,
+		})
+	case "legal-hold":
+		legalHoldFlags := flag.NewFlagSet("legal-hold", flag.ContinueOnError)
+		versionID := legalHoldFlags.String("version-id", "", "operate on this version of the blob instead of the latest one")
+		parseFlags(legalHoldFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if legalHoldFlags.NArg() < 1 || legalHoldFlags.NArg() > 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("legal-hold method expected 1 or 2 arguments got %d", legalHoldFlags.NArg())))
+		}
+
+		var on bool
+		if legalHoldFlags.NArg() == 2 {
+			if on, err = parseOnOff(legalHoldFlags.Arg(1)); err != nil {
+				result.exit(jsonOutput, usageError(err))
+			}
+		}
+
+		err = runner.legalHold(ctx, legalHoldOptions{
+			Key:       legalHoldFlags.Arg(0),
+			VersionID: *versionID,
+			Set:       legalHoldFlags.NArg() == 2,
+			On:        on,
+		})
+	case "batch":
+		batchFlags := flag.NewFlagSet("batch", flag.ContinueOnError)
+		parallelism := batchFlags.Int("parallelism", 4, "number of requests executed at once")
+		parseFlags(batchFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if batchFlags.NArg() != 0 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("batch method expected no arguments got %d", batchFlags.NArg())))
+		}
+
+		// Every request prints its own result, -output does not apply
+		os.Exit(runBatch(ctx, blobstoreClient, s3Config.BucketName, logger, os.Stdin, os.Stdout, *parallelism))
+	case "sync":
+		syncFlags := flag.NewFlagSet("sync", flag.ContinueOnError)
+		download := syncFlags.Bool("download", false, "download <prefix> into <local-dir> instead of uploading <local-dir> to <prefix>")
+		deleteExtra := syncFlags.Bool("delete", false, "delete the files or keys missing on the source side")
+		dryRun := syncFlags.Bool("dry-run", false, "only print what would be transferred and deleted")
+		sizeOnly := syncFlags.Bool("size-only", false, "compare files by size only, not by ETag")
+		concurrency := syncFlags.Int("concurrency", 0, "number of files transferred at once")
+		parseFlags(syncFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if syncFlags.NArg() != 2 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("sync method expected 2 arguments got %d", syncFlags.NArg())))
+		}
+
+		err = runner.sync(ctx, syncOptions{
+			Src:         syncFlags.Arg(0),
+			Dst:         syncFlags.Arg(1),
+			Download:    *download,
+			Delete:      *deleteExtra,
+			DryRun:      *dryRun,
+			SizeOnly:    *sizeOnly,
+			Concurrency: *concurrency,
+		})
+	case "serve":
+		serveFlags := flag.NewFlagSet("serve", flag.ContinueOnError)
+		listen := serveFlags.String("listen", "127.0.0.1:8080", "address to serve the blobs on")
+		basicAuthFile := serveFlags.String("basic-auth-file", "", "file holding 'username:password' which requests must authenticate with")
+		dav := serveFlags.Bool("dav", false, "expect the /<xx>/<key> paths of the DAV blobstore client")
+		parseFlags(serveFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if serveFlags.NArg() != 0 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("serve method expected no arguments got %d", serveFlags.NArg())))
+		}
+
+		blobs := &blobServer{blobstoreClient: blobstoreClient, logger: logger, dav: *dav}
+		if *basicAuthFile != "" {
+			if blobs.username, blobs.password, err = readBasicAuth(*basicAuthFile); err != nil {
+				result.exit(jsonOutput, err)
+			}
+		}
+
+		result.setKey("", s3Config.BucketName)
+		err = serveBlobs(ctx, *listen, blobs)
+	case "list":
+		listFlags := flag.NewFlagSet("list", flag.ContinueOnError)
+		delimiter := listFlags.String("delimiter", "", "group keys sharing a prefix up to the delimiter, e.g. '/'")
+		long := listFlags.Bool("long", false, "print size, ETag, last-modified and storage class of each entry")
+		pageSize := listFlags.Int("page-size", 0, "number of keys requested per API call")
+		maxEntries := listFlags.Int("max", 0, "stop after listing this many entries")
+		startAfter := listFlags.String("start-after", "", "only list keys sorting after this key")
+		parseFlags(listFlags, nonFlagArgs[1:], result, jsonOutput)
+
+		if listFlags.NArg() > 1 {
+			result.exit(jsonOutput, usageError(fmt.Errorf("list method expected at most 1 argument got %d", listFlags.NArg())))
+		}
+
+		err = runner.list(ctx, listOptions{
+			Prefix:     listFlags.Arg(0),
+			Delimiter:  *delimiter,
+			Long:       *long,
+			PageSize:   int32(*pageSize),
+			MaxEntries: *maxEntries,
+			StartAfter: *startAfter,
+		})
+	default:
+		result.exit(jsonOutput, usageError(fmt.Errorf("unknown command: '%s'", cmd)))
+	}
+
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+
+	if err != nil && ctx.Err() != nil {
+		logger.Error("Operation interrupted", "command", cmd, "error", err)
+		result.exitWithCode(jsonOutput, err, interruptedExitCode(ctx))
+	}
+	switch {
+	case errors.Is(err, client.ErrNotModified):
+		// The caller's copy is current, which is no failure
+		logger.Info("Blob not modified", "command", cmd)
+	case err != nil:
+		logger.Error("Operation failed", "command", cmd, "error", err)
+	}
+	result.exitWithCode(jsonOutput, err, runner.successCode)
+}
+
+// parseFlags parses the flags of a command. The flag package already printed
+// an invalid flag along with the usage of the command, which then fails with
+// a usage error; -h only prints the usage.
+func parseFlags(fs *flag.FlagSet, args []string, result *commandResult, jsonOutput bool) {
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
 		os.Exit(0)
+	case err != nil:
+		result.exitWithCode(jsonOutput, usageError(err), 0)
+	}
+}
+
+func printListEntry(w io.Writer, entry client.ListEntry, long bool) error {
+	var err error
+	switch {
+	case !long:
+		_, err = fmt.Fprintln(w, entry.Key)
+	case entry.IsPrefix:
+		_, err = fmt.Fprintf(w, "PRE\t%s\n", entry.Key)
 	default:
-		log.Fatalf("unknown command: '%s'\n", cmd)
+		_, err = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			entry.LastModified.UTC().Format(time.RFC3339), entry.Size, entry.ETag, entry.StorageClass, entry.Key)
 	}
 
-	if err != nil {
-		log.Fatalf("performing operation %s: %s\n", cmd, err)
+	return err
+}
+
+func printVersion(w io.Writer, version client.ObjectVersion) error {
+	var flags []string
+	if version.IsLatest {
+		flags = append(flags, "latest")
+	}
+	if version.IsDeleteMarker {
+		flags = append(flags, "delete-marker")
+	}
+	if len(flags) == 0 {
+		flags = append(flags, "-")
+	}
+
+	_, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+		version.VersionID, version.LastModified.UTC().Format(time.RFC3339), version.Size, version.ETag, strings.Join(flags, ","))
+	return err
+}
+
+// printMetadata prints one 'name: value' line per field stat reports, leaving
+// out those the blobstore did not report
+func printMetadata(w io.Writer, metadata client.ObjectMetadata) error {
+	lines := [][2]string{
+		{"key", metadata.Key},
+		{"bucket", metadata.Bucket},
+		{"version_id", metadata.VersionID},
+		{"size", strconv.FormatInt(metadata.Size, 10)},
+		{"etag", metadata.ETag},
+		{"content_type", metadata.ContentType},
+		{"last_modified", formatTime(metadata.LastModified)},
+		{"storage_class", metadata.StorageClass},
+		{"cache_control", metadata.CacheControl},
+		{"content_disposition", metadata.ContentDisposition},
+		{"content_encoding", metadata.ContentEncoding},
+		{"server_side_encryption", metadata.ServerSideEncryption},
+		{"sse_kms_key_id", metadata.SSEKMSKeyID},
+		{"tag_count", strconv.Itoa(metadata.TagCount)},
+		{"checksum_type", metadata.ChecksumType},
+		{"object_lock_mode", metadata.ObjectLockMode},
+		{"object_lock_retain_until", formatTime(metadata.ObjectLockRetainUntilDate)},
+		{"object_lock_legal_hold", metadata.ObjectLockLegalHold},
+		{"compression", metadata.Compression},
+		{"encryption", metadata.Encryption},
+	}
+	if metadata.Compression != "" || metadata.Encryption != "" {
+		lines = append(lines, [2]string{"original_size", strconv.FormatInt(metadata.OriginalSize, 10)})
+	}
+	for _, algorithm := range slices.Sorted(maps.Keys(metadata.Checksums)) {
+		lines = append(lines, [2]string{"checksum." + strings.ToLower(algorithm), metadata.Checksums[algorithm]})
+	}
+	for _, name := range slices.Sorted(maps.Keys(metadata.Metadata)) {
+		lines = append(lines, [2]string{"metadata." + name, metadata.Metadata[name]})
+	}
+
+	for _, line := range lines {
+		if line[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", line[0], line[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printRestoreStatus prints one 'name: value' line per field restore-status
+// reports, leaving out those without a value
+func printRestoreStatus(w io.Writer, status client.RestoreStatus) error {
+	return printLines(w, [][2]string{
+		{"key", status.Key},
+		{"bucket", status.Bucket},
+		{"version_id", status.VersionID},
+		{"storage_class", status.StorageClass},
+		{"archive_status", status.ArchiveStatus},
+		{"restore", status.State},
+		{"restore_expiry", formatTime(status.ExpiryDate)},
+	})
+}
+
+// printLines prints one 'name: value' line per pair, leaving out those
+// without a value
+func printLines(w io.Writer, lines [][2]string) error {
+	for _, line := range lines {
+		if line[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", line[0], line[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printTags prints one 'name=value' line per tag, as tag expects them
+func printTags(w io.Writer, tags map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(tags)) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", name, tags[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatTime formats t as list -l does, the zero time as empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func printSyncAction(w io.Writer, action client.SyncAction) error {
+	var err error
+	switch action.Op {
+	case client.SyncUpload:
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", action.Op, action.Path, action.Key)
+	case client.SyncDownload:
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", action.Op, action.Key, action.Path)
+	default:
+		_, err = fmt.Fprintf(w, "%s\t%s%s\n", action.Op, action.Key, action.Path)
+	}
+
+	return err
+}
+
+// readKeys reads one key per line from path, or from stdin if path is '-'.
+// Empty lines are skipped.
+func readKeys(path string) ([]string, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close() //nolint:errcheck
+		in = file
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if key := strings.TrimSuffix(scanner.Text(), "\r"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// parseRange checks the byte range of get -range, a single range of the
+// HTTP Range header. The 'bytes=' unit may be left out.
+func parseRange(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	value = "bytes=" + strings.TrimPrefix(value, "bytes=")
+	first, last, ok := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+	valid := ok && (first != "" || last != "") && isDigits(first) && isDigits(last)
+	if valid && first != "" && last != "" {
+		start, startErr := strconv.ParseInt(first, 10, 64)
+		end, endErr := strconv.ParseInt(last, 10, 64)
+		valid = startErr == nil && endErr == nil && start <= end
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid range '%s', expected e.g. 'bytes=0-99', 'bytes=100-' or 'bytes=-100'", value)
+	}
+
+	return value, nil
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// parseModifiedSince reads the time of get -if-modified-since, in RFC 3339 as
+// list -l prints it or an HTTP date as in a Last-Modified header
+func parseModifiedSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected e.g. '2006-01-02T15:04:05Z' or 'Mon, 02 Jan 2006 15:04:05 GMT'", value)
+}
+
+// parseRetainUntil reads a retain-until date, in RFC 3339 or a plain date
+// meaning its start in UTC
+func parseRetainUntil(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid retain-until date '%s', expected e.g. '2030-01-02' or '2030-01-02T15:04:05Z'", value)
+}
+
+// parseOnOff reads the on or off of legal-hold
+func parseOnOff(value string) (bool, error) {
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected 'on' or 'off', got '%s'", value)
+	}
+}
+
+func formatOnOff(on bool) string {
+	if on {
+		return "on"
+	}
+
+	return "off"
+}
+
+// keyValueFlag collects repeated 'name=value' flags such as put -metadata
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for _, name := range slices.Sorted(maps.Keys(f)) {
+		pairs = append(pairs, name+"="+f[name])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected 'name=value', got '%s'", value)
+	}
+	f[name] = v
+
+	return nil
+}
+
+// addDigestFlags defines the -sha1, -sha256 and -digest flags of put and get.
+// The returned function collects the digests given once the flags are parsed.
+func addDigestFlags(fs *flag.FlagSet) func() ([]client.Digest, error) {
+	sha1 := fs.String("sha1", "", "expected hex encoded SHA1 of the content")
+	sha256 := fs.String("sha256", "", "expected hex encoded SHA256 of the content")
+	multipleDigest := fs.String("digest", "", "expected BOSH multiple digest of the content, e.g. 'sha1:...;sha256:...'")
+
+	return func() ([]client.Digest, error) {
+		var digests []client.Digest
+		if *multipleDigest != "" {
+			parsed, err := client.ParseDigests(*multipleDigest)
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, parsed...)
+		}
+
+		for _, flagDigest := range []client.Digest{{Algorithm: "sha1", Value: *sha1}, {Algorithm: "sha256", Value: *sha256}} {
+			if flagDigest.Value == "" {
+				continue
+			}
+			digest, err := client.NewDigest(flagDigest.Algorithm, flagDigest.Value)
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, digest)
+		}
+
+		return digests, nil
 	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+	"github.com/cloudfoundry/bosh-s3cli/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestS3cli(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "S3cli Suite")
+}
+
+// newTestClient returns a client of some-bucket sending its requests to the
+// httptest server at serverURL, without retries
+func newTestClient(serverURL string) client.S3CompatibleClient {
+	s3Client := s3.NewFromConfig(aws.Config{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("id", "key", ""),
+		RetryMaxAttempts: 1,
+	}, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(serverURL)
+		o.UsePathStyle = true
+	})
+
+	s3Config := &config.S3Cli{
+		AccessKeyID:     "id",
+		SecretAccessKey: "key",
+		BucketName:      "some-bucket",
+		LogLevel:        config.LogLevelError,
+	}
+	return client.New(s3Client, s3Config)
+}
+
+// newTestLogger discards the logs of the command under test
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+)
+
+// commandResult is the document a command prints to stdout with -output json.
+// Log lines keep going to stderr.
+type commandResult struct {
+	Command          string            `json:"command"`
+	Key              string            `json:"key,omitempty"`
+	Bucket           string            `json:"bucket,omitempty"`
+	Size             *int64            `json:"size,omitempty"`
+	ETag             string            `json:"etag,omitempty"`
+	VersionID        string            `json:"version_id,omitempty"`
+	Location         string            `json:"location,omitempty"`
+	DurationSeconds  float64           `json:"duration_seconds"`
+	BytesTransferred int64             `json:"bytes_transferred,omitempty"`
+	SignedURL        string            `json:"signed_url,omitempty"`
+	SignedHeaders    map[string]string `json:"signed_headers,omitempty"`
+	Exists           *bool             `json:"exists,omitempty"`
+	Entries          []listEntry       `json:"entries,omitempty"`
+	Versions         []versionEntry    `json:"versions,omitempty"`
+	Deleted          []string          `json:"deleted,omitempty"`
+	Failures         []deleteFailure   `json:"failures,omitempty"`
+	Synced           []syncEntry       `json:"synced,omitempty"`
+	Stat             *statEntry        `json:"stat,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Restore          *restoreEntry     `json:"restore,omitempty"`
+	Retention        *retentionEntry   `json:"retention,omitempty"`
+	LegalHold        *bool             `json:"legal_hold,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	ErrorCode        string            `json:"error_code,omitempty"`
+
+	start time.Time
+}
+
+func newCommandResult(cmd string) *commandResult {
+	return &commandResult{Command: cmd, start: time.Now()}
+}
+
+type listEntry struct {
+	Key          string     `json:"key"`
+	IsPrefix     bool       `json:"is_prefix,omitempty"`
+	Size         *int64     `json:"size,omitempty"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	StorageClass string     `json:"storage_class,omitempty"`
+}
+
+type versionEntry struct {
+	VersionID      string    `json:"version_id"`
+	IsLatest       bool      `json:"is_latest"`
+	IsDeleteMarker bool      `json:"is_delete_marker"`
+	Size           int64     `json:"size"`
+	ETag           string    `json:"etag,omitempty"`
+	LastModified   time.Time `json:"last_modified"`
+	StorageClass   string    `json:"storage_class,omitempty"`
+}
+
+type deleteFailure struct {
+	Key     string `json:"key"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type syncEntry struct {
+	Op   string `json:"op"`
+	Key  string `json:"key,omitempty"`
+	Path string `json:"path,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// statEntry is the metadata of stat beyond the fields every command reports
+type statEntry struct {
+	ContentType           string            `json:"content_type,omitempty"`
+	LastModified          time.Time         `json:"last_modified"`
+	StorageClass          string            `json:"storage_class,omitempty"`
+	CacheControl          string            `json:"cache_control,omitempty"`
+	ContentDisposition    string            `json:"content_disposition,omitempty"`
+	ContentEncoding       string            `json:"content_encoding,omitempty"`
+	ServerSideEncryption  string            `json:"server_side_encryption,omitempty"`
+	SSEKMSKeyID           string            `json:"sse_kms_key_id,omitempty"`
+	Metadata              map[string]string `json:"metadata"`
+	TagCount              int               `json:"tag_count"`
+	Checksums             map[string]string `json:"checksums"`
+	ChecksumType          string            `json:"checksum_type,omitempty"`
+	ObjectLockMode        string            `json:"object_lock_mode,omitempty"`
+	ObjectLockRetainUntil *time.Time        `json:"object_lock_retain_until,omitempty"`
+	ObjectLockLegalHold   string            `json:"object_lock_legal_hold,omitempty"`
+	Compression           string            `json:"compression,omitempty"`
+	Encryption            string            `json:"encryption,omitempty"`
+	OriginalSize          *int64            `json:"original_size,omitempty"`
+}
+
+// restoreEntry is what restore-status reports beyond the fields every
+// command reports
+type restoreEntry struct {
+	StorageClass  string     `json:"storage_class,omitempty"`
+	ArchiveStatus string     `json:"archive_status,omitempty"`
+	State         string     `json:"state"`
+	ExpiryDate    *time.Time `json:"expiry_date,omitempty"`
+}
+
+// retentionEntry is the Object Lock retention retention reports, empty if
+// the blob has none
+type retentionEntry struct {
+	Mode        string     `json:"mode,omitempty"`
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+}
+
+func (r *commandResult) setKey(key string, bucket string) {
+	r.Key = key
+	r.Bucket = bucket
+}
+
+// setObject records the object a put, get, copy or move transferred
+func (r *commandResult) setObject(object client.ObjectInfo) {
+	r.setKey(object.Key, object.Bucket)
+	r.Size = &object.Size
+	r.ETag = object.ETag
+	r.VersionID = object.VersionID
+	r.Location = object.Location
+	if r.Command != "copy" && r.Command != "move" && r.Command != "restore-version" {
+		r.BytesTransferred = transferredSize(object)
+	}
+}
+
+// transferredSize is the number of bytes a get or put transferred, only
+// those of the range of a ranged download and those missing from the
+// partial file of a resumed one
+func transferredSize(object client.ObjectInfo) int64 {
+	if object.ContentRange != "" {
+		return contentRangeLength(object.ContentRange, object.Size)
+	}
+
+	return object.Size - object.ResumedFrom
+}
+
+// setMetadata records the object stat describes
+func (r *commandResult) setMetadata(metadata client.ObjectMetadata) {
+	r.setKey(metadata.Key, metadata.Bucket)
+	r.Size = &metadata.Size
+	r.ETag = metadata.ETag
+	r.VersionID = metadata.VersionID
+
+	r.Stat = &statEntry{
+		ContentType:          metadata.ContentType,
+		LastModified:         metadata.LastModified,
+		StorageClass:         metadata.StorageClass,
+		CacheControl:         metadata.CacheControl,
+		ContentDisposition:   metadata.ContentDisposition,
+		ContentEncoding:      metadata.ContentEncoding,
+		ServerSideEncryption: metadata.ServerSideEncryption,
+		SSEKMSKeyID:          metadata.SSEKMSKeyID,
+		Metadata:             metadata.Metadata,
+		TagCount:             metadata.TagCount,
+		Checksums:            metadata.Checksums,
+		ChecksumType:         metadata.ChecksumType,
+		ObjectLockMode:       metadata.ObjectLockMode,
+		ObjectLockLegalHold:  metadata.ObjectLockLegalHold,
+		Compression:          metadata.Compression,
+		Encryption:           metadata.Encryption,
+	}
+	if metadata.Compression != "" || metadata.Encryption != "" {
+		r.Stat.OriginalSize = &metadata.OriginalSize
+	}
+	if !metadata.ObjectLockRetainUntilDate.IsZero() {
+		r.Stat.ObjectLockRetainUntil = &metadata.ObjectLockRetainUntilDate
+	}
+	if r.Stat.Metadata == nil {
+		r.Stat.Metadata = map[string]string{}
+	}
+}
+
+// setRestoreStatus records the restore state restore-status reports
+func (r *commandResult) setRestoreStatus(status client.RestoreStatus) {
+	r.setKey(status.Key, status.Bucket)
+	r.VersionID = status.VersionID
+
+	r.Restore = &restoreEntry{
+		StorageClass:  status.StorageClass,
+		ArchiveStatus: status.ArchiveStatus,
+		State:         status.State,
+	}
+	if !status.ExpiryDate.IsZero() {
+		r.Restore.ExpiryDate = &status.ExpiryDate
+	}
+}
+
+// setRetention records the Object Lock retention retention reports
+func (r *commandResult) setRetention(retention client.Retention) {
+	r.Retention = &retentionEntry{Mode: retention.Mode}
+	if !retention.RetainUntilDate.IsZero() {
+		r.Retention.RetainUntil = &retention.RetainUntilDate
+	}
+}
+
+// setSignedURL records a signed URL and the headers its requests have to send
+func (r *commandResult) setSignedURL(signedURL string, headers http.Header) {
+	r.SignedURL = signedURL
+	for name := range headers {
+		if r.SignedHeaders == nil {
+			r.SignedHeaders = map[string]string{}
+		}
+		r.SignedHeaders[strings.ToLower(name)] = headers.Get(name)
+	}
+}
+
+func (r *commandResult) addListEntry(entry client.ListEntry) {
+	if entry.IsPrefix {
+		r.Entries = append(r.Entries, listEntry{Key: entry.Key, IsPrefix: true})
+		return
+	}
+
+	r.Entries = append(r.Entries, listEntry{
+		Key:          entry.Key,
+		Size:         &entry.Size,
+		ETag:         entry.ETag,
+		LastModified: &entry.LastModified,
+		StorageClass: entry.StorageClass,
+	})
+}
+
+func (r *commandResult) addVersion(objectVersion client.ObjectVersion) {
+	r.Versions = append(r.Versions, versionEntry{
+		VersionID:      objectVersion.VersionID,
+		IsLatest:       objectVersion.IsLatest,
+		IsDeleteMarker: objectVersion.IsDeleteMarker,
+		Size:           objectVersion.Size,
+		ETag:           objectVersion.ETag,
+		LastModified:   objectVersion.LastModified,
+		StorageClass:   objectVersion.StorageClass,
+	})
+}
+
+func (r *commandResult) addDeleteFailure(failure client.DeleteFailure) {
+	r.Failures = append(r.Failures, deleteFailure{Key: failure.Key, Code: failure.Code, Message: failure.Message})
+}
+
+func (r *commandResult) addSyncAction(action client.SyncAction) {
+	r.Synced = append(r.Synced, syncEntry{Op: action.Op, Key: action.Key, Path: action.Path, Size: action.Size})
+}
+
+// exit logs err, if any, and exits like exitWithCode
+func (r *commandResult) exit(jsonOutput bool, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+
+	r.exitWithCode(jsonOutput, err, 0)
+}
+
+// exitWithCode prints the result in JSON mode and exits with code, or with
+// the exit code of err if the command failed
+func (r *commandResult) exitWithCode(jsonOutput bool, err error, code int) {
+	code = r.finish(err, code)
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		// Keep the query strings of signed URLs readable
+		encoder.SetEscapeHTML(false)
+		if encodeErr := encoder.Encode(r); encodeErr != nil {
+			log.Println(encodeErr)
+		}
+	}
+
+	os.Exit(code)
+}
+
+// finish records the duration and the error of the command and returns its
+// exit status: code, or the exit status of err if the command failed
+func (r *commandResult) finish(err error, code int) int {
+	r.DurationSeconds = time.Since(r.start).Seconds()
+	if err != nil {
+		r.Error = err.Error()
+		r.ErrorCode = errorCode(err)
+		if code == 0 {
+			code = exitCode(err)
+		}
+	}
+
+	return code
+}
+
+// errorCode is the error code the blobstore returned, if any
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+
+	return ""
+}
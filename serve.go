@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-s3cli/client"
+)
+
+// serveShutdownTimeout is how long open requests may complete once serve stops
+const serveShutdownTimeout = 30 * time.Second
+
+// blobServer exposes the blobs of the configured bucket over plain HTTP:
+// GET, HEAD, PUT and DELETE of /<key>
+type blobServer struct {
+	blobstoreClient client.S3CompatibleClient
+	logger          *slog.Logger
+	// username and password enable basic authentication if set
+	username string
+	password string
+	// dav expects the paths of the DAV blobstore client, /<xx>/<key> where xx
+	// is the first byte of the SHA1 of the key in hex
+	dav bool
+}
+
+// serveBlobs serves the blobs on listen until ctx is cancelled
+func serveBlobs(ctx context.Context, listen string, blobs *blobServer) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           blobs,
+		ReadHeaderTimeout: 30 * time.Second,
+		// Requests are cancelled with serve, which aborts their uploads
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	blobs.logger.Info("Serving blobs", "address", listener.Addr().String())
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	blobs.logger.Info("Stopped serving blobs")
+
+	return nil
+}
+
+// readBasicAuth reads the credentials of serve from path, a single line
+// 'username:password'
+func readBasicAuth(path string) (string, string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	username, password, ok := strings.Cut(strings.TrimSpace(string(content)), ":")
+	if !ok || username == "" || password == "" {
+		return "", "", fmt.Errorf("basic auth file '%s' must contain 'username:password'", path)
+	}
+
+	return username, password, nil
+}
+
+func (s *blobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := s.serve(w, r)
+	s.logger.InfoContext(r.Context(), "Served request",
+		"method", r.Method, "path", r.URL.Path, "status", status, "duration", time.Since(start))
+}
+
+// serve handles r and returns the status of the response
+func (s *blobServer) serve(w http.ResponseWriter, r *http.Request) int {
+	if s.username != "" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="s3cli"`)
+		return writeStatus(w, http.StatusUnauthorized)
+	}
+
+	key, ok := s.key(r.URL.Path)
+	if !ok {
+		return writeStatus(w, http.StatusNotFound)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return s.get(w, r, key)
+	case http.MethodHead:
+		return s.head(w, r, key)
+	case http.MethodPut:
+		err := s.blobstoreClient.PutWithContext(r.Context(), r.Body, key, func(o *client.PutOptions) {
+			o.NoOverwrite = r.Header.Get("If-None-Match") == "*"
+			o.IfMatch = r.Header.Get("If-Match")
+		})
+		if err != nil {
+			return s.writeError(w, r, err)
+		}
+		return writeStatus(w, http.StatusCreated)
+	case http.MethodDelete:
+		if err := s.blobstoreClient.DeleteWithContext(r.Context(), key); err != nil {
+			return s.writeError(w, r, err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return http.StatusNoContent
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		return writeStatus(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// get streams the blob, or the single byte range the request asks for
+func (s *blobServer) get(w http.ResponseWriter, r *http.Request, key string) int {
+	out := &blobResponseWriter{w: w}
+	err := s.blobstoreClient.GetStreamWithContext(r.Context(), key, out, func(o *client.GetOptions) {
+		// Several ranges would need a multipart response, the whole blob is
+		// a valid answer to them as well
+		if rangeHeader := r.Header.Get("Range"); strings.HasPrefix(rangeHeader, "bytes=") && !strings.Contains(rangeHeader, ",") {
+			o.Range = rangeHeader
+		}
+		// So is the whole blob for a list of ETags
+		if ifNoneMatch := r.Header.Get("If-None-Match"); !strings.Contains(ifNoneMatch, ",") {
+			o.IfNoneMatch = ifNoneMatch
+		}
+		if ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+			o.IfModifiedSince = ifModifiedSince
+		}
+		o.Result = &out.info
+	})
+
+	if err != nil && out.status == 0 {
+		return s.writeError(w, r, err)
+	}
+	if err != nil {
+		// The status is sent, only an incomplete response tells the client
+		s.logger.ErrorContext(r.Context(), "Operation failed", "command", "serve", "key", key, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	// Empty blobs are never written
+	out.writeHeader()
+	return out.status
+}
+
+// head answers with the headers a GET of the whole blob would send
+func (s *blobServer) head(w http.ResponseWriter, r *http.Request, key string) int {
+	metadata, err := s.blobstoreClient.StatWithContext(r.Context(), key)
+	if err != nil {
+		return s.writeError(w, r, err)
+	}
+
+	// GET sends the content of compressed and encrypted blobs decompressed
+	// and decrypted
+	size := metadata.Size
+	if metadata.Compression != "" || metadata.Encryption != "" {
+		size = metadata.OriginalSize
+	}
+
+	header := w.Header()
+	setBlobHeaders(header, metadata.ETag)
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	if !metadata.LastModified.IsZero() {
+		header.Set("Last-Modified", metadata.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return http.StatusOK
+}
+
+// authorized checks the basic auth credentials of r
+func (s *blobServer) authorized(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+	return usernameMatches && passwordMatches
+}
+
+// key is the blob a request path refers to
+func (s *blobServer) key(path string) (string, bool) {
+	key := strings.TrimPrefix(path, "/")
+	if !s.dav {
+		return key, key != ""
+	}
+
+	prefix, key, ok := strings.Cut(key, "/")
+	if !ok || key == "" {
+		return "", false
+	}
+	digest := sha1.Sum([]byte(key)) //nolint:gosec
+	return key, prefix == fmt.Sprintf("%02x", digest[0])
+}
+
+// writeError answers a failed operation with the status matching its kind
+func (s *blobServer) writeError(w http.ResponseWriter, r *http.Request, err error) int {
+	if errors.Is(err, client.ErrNotModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified
+	}
+
+	status := http.StatusBadGateway
+	switch {
+	case errorCode(err) == "InvalidRange":
+		status = http.StatusRequestedRangeNotSatisfiable
+	case errors.Is(err, client.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, client.ErrAccessDenied), errors.Is(err, client.ErrObjectLocked), errors.Is(err, client.ErrReadOnlyMode):
+		status = http.StatusForbidden
+	case errors.Is(err, client.ErrThrottled):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, client.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, client.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, client.ErrArchived):
+		status = http.StatusConflict
+	case errors.Is(err, client.ErrInvalidArgument):
+		status = http.StatusBadRequest
+	}
+
+	// Missing blobs, ranges, failed preconditions and invalid requests are
+	// the client's business
+	if status != http.StatusNotFound && status != http.StatusRequestedRangeNotSatisfiable &&
+		status != http.StatusPreconditionFailed && status != http.StatusBadRequest {
+		s.logger.ErrorContext(r.Context(), "Operation failed", "command", "serve", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	return writeStatus(w, status)
+}
+
+// writeStatus answers with status and its text
+func writeStatus(w http.ResponseWriter, status int) int {
+	http.Error(w, http.StatusText(status), status)
+	return status
+}
+
+// blobResponseWriter sends the headers of a downloaded blob with its first
+// bytes, once the download received the object's details
+type blobResponseWriter struct {
+	w      http.ResponseWriter
+	info   client.ObjectInfo
+	status int
+}
+
+func (b *blobResponseWriter) Write(p []byte) (int, error) {
+	b.writeHeader()
+	return b.w.Write(p)
+}
+
+func (b *blobResponseWriter) writeHeader() {
+	if b.status != 0 {
+		return
+	}
+
+	header := b.w.Header()
+	setBlobHeaders(header, b.info.ETag)
+
+	b.status = http.StatusOK
+	length := b.info.Size
+	if b.info.ContentRange != "" {
+		b.status = http.StatusPartialContent
+		header.Set("Content-Range", b.info.ContentRange)
+		length = contentRangeLength(b.info.ContentRange, length)
+	}
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+
+	b.w.WriteHeader(b.status)
+}
+
+// setBlobHeaders sets the headers GET and HEAD send for every blob
+func setBlobHeaders(header http.Header, etag string) {
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Type", "application/octet-stream")
+	if etag != "" {
+		header.Set("ETag", `"`+etag+`"`)
+	}
+}
+
+// contentRangeLength is the number of bytes a Content-Range such as
+// 'bytes 0-99/1000' covers, or size if it cannot be parsed
+func contentRangeLength(contentRange string, size int64) int64 {
+	byteRange, _, _ := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "/")
+	first, last, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return size
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return size
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return size
+	}
+
+	return end - start + 1
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("serve", func() {
+	const content = "some content"
+	lastModified := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var s3Server *httptest.Server
+	var objects map[string]string
+	var failures map[string][2]string
+	var requestsMutex sync.Mutex
+	var blobs *blobServer
+
+	BeforeEach(func() {
+		objects = map[string]string{"some-key": content}
+		failures = map[string][2]string{}
+		s3Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+
+			key := strings.TrimPrefix(r.URL.Path, "/some-bucket/")
+			body, _ := io.ReadAll(r.Body) //nolint:errcheck
+			writeError := func(status int, code string) {
+				w.WriteHeader(status)
+				fmt.Fprintf(w, `<Error><Code>%s</Code><Message>some message</Message></Error>`, code) //nolint:errcheck
+			}
+			if failure, ok := failures[key]; ok {
+				status, _ := strconv.Atoi(failure[0]) //nolint:errcheck
+				writeError(status, failure[1])
+				return
+			}
+
+			object, exists := objects[key]
+			switch r.Method {
+			case http.MethodPut:
+				if exists && r.Header.Get("If-None-Match") == "*" {
+					writeError(http.StatusPreconditionFailed, "PreconditionFailed")
+					return
+				}
+				objects[key] = string(body)
+				w.Header().Set("ETag", `"some-etag"`)
+				return
+			case http.MethodDelete:
+				delete(objects, key)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if !exists {
+				writeError(http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			w.Header().Set("ETag", `"some-etag"`)
+			w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+			if r.Method == http.MethodHead {
+				return
+			}
+
+			rangeHeader, ranged := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+			if !ranged {
+				w.Write([]byte(object)) //nolint:errcheck
+				return
+			}
+			first, last, _ := strings.Cut(rangeHeader, "-")
+			start, _ := strconv.Atoi(first) //nolint:errcheck
+			end := len(object) - 1
+			if last != "" {
+				end, _ = strconv.Atoi(last) //nolint:errcheck
+				end = min(end, len(object)-1)
+			}
+			if start >= len(object) {
+				w.Header().Del("Content-Length")
+				writeError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(object)))
+			w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(object[start : end+1])) //nolint:errcheck
+		}))
+
+		blobs = &blobServer{blobstoreClient: newTestClient(s3Server.URL), logger: newTestLogger()}
+	})
+
+	AfterEach(func() {
+		s3Server.Close()
+	})
+
+	serve := func(method string, path string, body string, header ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			request.Header.Set(header[i], header[i+1])
+		}
+
+		recorder := httptest.NewRecorder()
+		blobs.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	Describe("basic auth", func() {
+		BeforeEach(func() {
+			blobs.username, blobs.password = "some-user", "some-password"
+		})
+
+		It("asks requests without credentials to authenticate", func() {
+			response := serve(http.MethodGet, "/some-key", "")
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			Expect(response.Header().Get("WWW-Authenticate")).To(Equal(`Basic realm="s3cli"`))
+		})
+
+		It("refuses wrong credentials", func() {
+			request := httptest.NewRequest(http.MethodGet, "/some-key", nil)
+			request.SetBasicAuth("some-user", "other-password")
+			recorder := httptest.NewRecorder()
+			blobs.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("serves requests with the credentials", func() {
+			request := httptest.NewRequest(http.MethodGet, "/some-key", nil)
+			request.SetBasicAuth("some-user", "some-password")
+			recorder := httptest.NewRecorder()
+			blobs.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal(content))
+		})
+	})
+
+	Describe("paths", func() {
+		It("maps the path to the key", func() {
+			objects["some-dir/other-key"] = "other content"
+
+			response := serve(http.MethodGet, "/some-dir/other-key", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal("other content"))
+
+			Expect(serve(http.MethodGet, "/", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("expects the prefix of the DAV blobstore client with -dav", func() {
+			blobs.dav = true
+			digest := sha1.Sum([]byte("some-key")) //nolint:gosec
+			prefix := fmt.Sprintf("%02x", digest[0])
+			otherPrefix := fmt.Sprintf("%02x", digest[0]+1)
+
+			response := serve(http.MethodGet, "/"+prefix+"/some-key", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal(content))
+
+			Expect(serve(http.MethodGet, "/"+otherPrefix+"/some-key", "").Code).To(Equal(http.StatusNotFound))
+			Expect(serve(http.MethodGet, "/some-key", "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("GET", func() {
+		It("answers a range with 206 Partial Content", func() {
+			response := serve(http.MethodGet, "/some-key", "", "Range", "bytes=0-3")
+
+			Expect(response.Code).To(Equal(http.StatusPartialContent))
+			Expect(response.Header().Get("Content-Range")).To(Equal(fmt.Sprintf("bytes 0-3/%d", len(content))))
+			Expect(response.Header().Get("Content-Length")).To(Equal("4"))
+			Expect(response.Body.String()).To(Equal("some"))
+		})
+
+		It("answers a range beyond the blob with 416", func() {
+			response := serve(http.MethodGet, "/some-key", "", "Range", "bytes=100-")
+			Expect(response.Code).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+		})
+
+		It("answers several ranges with the whole blob", func() {
+			response := serve(http.MethodGet, "/some-key", "", "Range", "bytes=0-1,3-4")
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(Equal(content))
+		})
+	})
+
+	Describe("HEAD", func() {
+		It("sends the headers of the blob", func() {
+			response := serve(http.MethodHead, "/some-key", "")
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Length")).To(Equal(strconv.Itoa(len(content))))
+			Expect(response.Header().Get("ETag")).To(Equal(`"some-etag"`))
+			Expect(response.Header().Get("Last-Modified")).To(Equal("Wed, 02 Jan 2030 03:04:05 GMT"))
+			Expect(response.Body.String()).To(BeEmpty())
+		})
+
+		It("answers a missing blob with 404", func() {
+			Expect(serve(http.MethodHead, "/missing-key", "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("PUT and DELETE", func() {
+		It("uploads and deletes blobs", func() {
+			Expect(serve(http.MethodPut, "/new-key", "new content").Code).To(Equal(http.StatusCreated))
+			Expect(objects).To(HaveKeyWithValue("new-key", "new content"))
+
+			Expect(serve(http.MethodDelete, "/new-key", "").Code).To(Equal(http.StatusNoContent))
+			Expect(objects).ToNot(HaveKey("new-key"))
+		})
+
+		It("honours If-None-Match: *", func() {
+			response := serve(http.MethodPut, "/some-key", "new content", "If-None-Match", "*")
+
+			Expect(response.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(objects).To(HaveKeyWithValue("some-key", content))
+		})
+
+		It("refuses conflicting conditions with 400", func() {
+			response := serve(http.MethodPut, "/some-key", "new content", "If-None-Match", "*", "If-Match", `"some-etag"`)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	DescribeTable("maps errors to statuses",
+		func(s3Status int, code string, status int) {
+			failures["failing-key"] = [2]string{strconv.Itoa(s3Status), code}
+			Expect(serve(http.MethodGet, "/failing-key", "").Code).To(Equal(status))
+		},
+		Entry("not found", http.StatusNotFound, "NoSuchKey", http.StatusNotFound),
+		Entry("access denied", http.StatusForbidden, "AccessDenied", http.StatusForbidden),
+		Entry("throttled", http.StatusServiceUnavailable, "SlowDown", http.StatusServiceUnavailable),
+		Entry("archived", http.StatusForbidden, "InvalidObjectState", http.StatusConflict),
+		Entry("any other failure", http.StatusInternalServerError, "InternalError", http.StatusBadGateway),
+	)
+
+	It("refuses other methods", func() {
+		response := serve(http.MethodPost, "/some-key", "")
+
+		Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(response.Header().Get("Allow")).To(Equal("GET, HEAD, PUT, DELETE"))
+	})
+})